@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+// patternList is a repeatable flag of OSC address patterns.
+type patternList []*osc.Pattern
+
+func (l *patternList) String() string {
+	s := make([]string, len(*l))
+	for i, p := range *l {
+		s[i] = p.String()
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *patternList) Set(v string) error {
+	p, err := osc.CompilePattern(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, p)
+	return nil
+}
+
+// match reports whether a pattern of the list matches `addr` or one of its
+// parent containers, so that "/eos/out" matches "/eos/out/ping" as well.
+func (l patternList) match(addr string) bool {
+	for _, p := range l {
+		for a := addr; ; {
+			if p.Match(a) {
+				return true
+			}
+			i := strings.LastIndexByte(a, '/')
+			if i <= 0 {
+				break
+			}
+			a = a[:i]
+		}
+	}
+	return false
+}
+
+// addressFilter selects messages by address. Bundles are shown if any of
+// their messages is.
+type addressFilter struct {
+	include, exclude patternList
+}
+
+func (f *addressFilter) register(fs *flag.FlagSet) {
+	fs.Var(&f.include, "f", "only show addresses matching this OSC `pattern` or below it (repeatable)")
+	fs.Var(&f.exclude, "x", "hide addresses matching this OSC `pattern` or below it (repeatable)")
+}
+
+func (f *addressFilter) show(packet osc.Packet) bool {
+	switch p := packet.(type) {
+	case *osc.Message:
+		if len(f.include) > 0 && !f.include.match(p.Address) {
+			return false
+		}
+		return !f.exclude.match(p.Address)
+	case *osc.Bundle:
+		for _, m := range p.Messages {
+			if f.show(m) {
+				return true
+			}
+		}
+		for _, b := range p.Bundles {
+			if f.show(b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runDump listens for OSC packets and prints them.
+func runDump(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: oscutil dump [flags]\n\n")
+		fs.PrintDefaults()
+	}
+	port := fs.Int("port", 8001, "local UDP `port` to listen on")
+	group := fs.String("group", "", "also join this multicast `group`")
+	record := fs.String("record", "", "record all packets to `file` (.jsonl for JSON lines)")
+	var filter addressFilter
+	filter.register(fs)
+	fs.Parse(args)
+
+	conn, err := localConnection(*port, "")
+	if err != nil {
+		return err
+	}
+	if *group != "" {
+		if err := conn.SetMulticast(osc.MulticastConfig{Groups: []string{*group}}); err != nil {
+			return err
+		}
+	}
+	rec, closeRec, err := openRecorder(*record)
+	if err != nil {
+		return err
+	}
+	defer closeRec()
+	conn.Recorder = rec
+	conn.DispatchOptions = osc.DispatchOptions{Mode: osc.DispatchSerial}
+	conn.Dispatcher = dispatchFunc(func(packet osc.Packet, addr net.Addr) {
+		if filter.show(packet) {
+			printPacket(addr.String(), packet)
+		}
+	})
+	if err := conn.Open(); err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	fmt.Printf("listening on UDP port %d\n", *port)
+	if err := conn.Serve(ctx); err != ctx.Err() {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+// newPatternList returns a patternList of the given patterns.
+func newPatternList(t *testing.T, patterns ...string) patternList {
+	t.Helper()
+	var l patternList
+	for _, p := range patterns {
+		if err := l.Set(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+func TestPatternListMatch(t *testing.T) {
+	l := newPatternList(t, "/eos/out", "/eos/fader/*/[1-3]")
+	if got := l.String(); got != "/eos/out,/eos/fader/*/[1-3]" {
+		t.Errorf("String = %q", got)
+	}
+
+	for addr, want := range map[string]bool{
+		"/eos/out":                true,
+		"/eos/out/ping":           true,
+		"/eos/out/active/wheel/1": true,
+		"/eos/output":             false,
+		"/eos":                    false,
+		"/eos/fader/1/2":          true,
+		"/eos/fader/1/2/name":     true,
+		"/eos/fader/1/4":          false,
+		"/eos/fader/1":            false,
+		"/":                       false,
+	} {
+		if got := l.match(addr); got != want {
+			t.Errorf("match(%s) = %v; want %v", addr, got, want)
+		}
+	}
+
+	if (patternList{}).match("/eos/out") {
+		t.Error("an empty list matches")
+	}
+	if err := new(patternList).Set("/eos/[out"); err == nil {
+		t.Error("Set of an invalid pattern: no error")
+	}
+}
+
+func TestAddressFilterShow(t *testing.T) {
+	var f addressFilter
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	f.register(fs)
+	if err := fs.Parse([]string{"-f", "/eos/out", "-x", "/eos/out/ping", "-x", "/eos/out/*/wheel"}); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := func(addrs ...string) *osc.Bundle {
+		b := osc.NewBundle(time.Time{})
+		for _, addr := range addrs {
+			b.Append(osc.NewMessage(addr))
+		}
+		return b
+	}
+	nested := bundle("/eos/out/ping")
+	nested.Append(bundle("/eos/out/fader/1/1"))
+
+	for _, tt := range []struct {
+		packet osc.Packet
+		want   bool
+	}{
+		{packet: osc.NewMessage("/eos/out/fader/1/1"), want: true},
+		{packet: osc.NewMessage("/eos/fader/1/1")},
+		{packet: osc.NewMessage("/eos/out/ping")},
+		{packet: osc.NewMessage("/eos/out/active/wheel/1")},
+		{packet: osc.NewMessage("/eos/out/active"), want: true},
+		// A bundle is shown if any of its messages is
+		{packet: bundle("/eos/out/ping", "/eos/out/cmd"), want: true},
+		{packet: bundle("/eos/out/ping", "/eos/cmd")},
+		{packet: nested, want: true},
+		{packet: bundle()},
+	} {
+		if got := f.show(tt.packet); got != tt.want {
+			t.Errorf("show(%v) = %v; want %v", tt.packet, got, tt.want)
+		}
+	}
+
+	// Without -f, everything not excluded is shown
+	f = addressFilter{exclude: newPatternList(t, "/eos/out/ping")}
+	if !f.show(osc.NewMessage("/eos/fader/1/1")) || f.show(osc.NewMessage("/eos/out/ping")) {
+		t.Error("exclude only filter")
+	}
+}
@@ -0,0 +1,125 @@
+// Command oscutil sends, dumps and proxies OSC traffic, e.g. to debug the OSC
+// settings of an Eos console.
+//
+// Usage:
+//
+//	oscutil send [flags] host:port /address [,tags] [args...]
+//	oscutil dump [flags]
+//	oscutil proxy [flags] host:port
+//
+// Run a subcommand with -h to list its flags.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+const usage = `usage:
+  oscutil send [flags] host:port /address [,tags] [args...]
+  oscutil dump [flags]
+  oscutil proxy [flags] host:port
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "send":
+		err = runSend(ctx, os.Args[2:])
+	case "dump":
+		err = runDump(ctx, os.Args[2:])
+	case "proxy":
+		err = runProxy(ctx, os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "oscutil %v: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// dispatchFunc adapts a function to the osc.Dispatcher interface.
+type dispatchFunc func(packet osc.Packet, addr net.Addr)
+
+func (f dispatchFunc) Dispatch(packet osc.Packet, addr net.Addr) {
+	f(packet, addr)
+}
+
+// printPacket prints a packet with a timestamp and a label, such as the peer
+// address. Bundle elements are indented below the bundle.
+func printPacket(label string, packet osc.Packet) {
+	fmt.Printf("%v %v %v\n", time.Now().Format("15:04:05.000"), label, formatPacket(packet, ""))
+}
+
+func formatPacket(packet osc.Packet, indent string) string {
+	switch p := packet.(type) {
+	case *osc.Message:
+		return p.String()
+	case *osc.Bundle:
+		var b strings.Builder
+		if p.Timetag.TimeTag() == 1 {
+			b.WriteString("#bundle immediate")
+		} else {
+			fmt.Fprintf(&b, "#bundle %v", p.Timetag.Time().Format("15:04:05.000"))
+		}
+		for _, m := range p.Messages {
+			fmt.Fprintf(&b, "\n%v    %v", indent, m)
+		}
+		for _, e := range p.Bundles {
+			fmt.Fprintf(&b, "\n%v    %v", indent, formatPacket(e, indent+"    "))
+		}
+		return b.String()
+	}
+	return fmt.Sprintf("%v", packet)
+}
+
+// openRecorder creates a recording file. Files ending in .jsonl or .json are
+// written as JSON lines, others in the binary format.
+func openRecorder(path string) (*osc.Recorder, func() error, error) {
+	if path == "" {
+		return nil, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	format := osc.RecordBinary
+	if strings.HasSuffix(path, ".jsonl") || strings.HasSuffix(path, ".json") {
+		format = osc.RecordJSON
+	}
+	return osc.NewRecorder(f, format), f.Close, nil
+}
+
+// localConnection returns a UDP connection on the local port `port` sending
+// to `raddr`.
+func localConnection(port int, raddr string) (*osc.Connection, error) {
+	conn, err := osc.NewConnection(port, raddr)
+	if err != nil {
+		return nil, err
+	}
+	conn.ErrorHandler = func(err error, addr net.Addr) {
+		fmt.Fprintf(os.Stderr, "%v bad packet from %v: %v\n", time.Now().Format("15:04:05.000"), addr, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+func TestFormatPacket(t *testing.T) {
+	b := osc.NewBundle(time.Time{})
+	b.Timetag = *osc.NewTimetagFromTimetag(1)
+	b.Append(osc.NewMessage("/eos/ping"))
+	inner := osc.NewBundle(time.Time{})
+	inner.Timetag = *osc.NewTimetagFromTimetag(1)
+	inner.Append(osc.NewMessage("/eos/cmd", "Go"))
+	b.Append(inner)
+
+	want := strings.Join([]string{
+		"#bundle immediate",
+		"    /eos/ping ,",
+		"    #bundle immediate",
+		`        /eos/cmd ,s Go`,
+	}, "\n")
+	if got := formatPacket(b, ""); got != want {
+		t.Errorf("formatPacket =\n%s\nwant\n%s", got, want)
+	}
+}
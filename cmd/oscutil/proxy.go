@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+// runProxy forwards packets between a client and a console and prints both
+// directions.
+func runProxy(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("proxy", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: oscutil proxy [flags] host:port\n\n"+
+			"Clients send to -listen; packets go on to host:port from -port, and the\n"+
+			"replies go back to the client that sent last, or to -client.\n\n")
+		fs.PrintDefaults()
+	}
+	listen := fs.Int("listen", 8000, "local UDP `port` that clients send to")
+	port := fs.Int("port", 8001, "local UDP `port` the console sends replies to")
+	client := fs.String("client", "", "send replies to this `host:port` instead of the last client")
+	record := fs.String("record", "", "record all packets to `file` (.jsonl for JSON lines)")
+	var filter addressFilter
+	filter.register(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("missing console address")
+	}
+
+	rec, closeRec, err := openRecorder(*record)
+	if err != nil {
+		return err
+	}
+	defer closeRec()
+
+	toConsole, err := localConnection(*port, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	toClient, err := localConnection(*listen, *client)
+	if err != nil {
+		return err
+	}
+	toConsole.Recorder = rec
+	toClient.Recorder = rec
+
+	// Both directions keep the order of their packets
+	toConsole.DispatchOptions = osc.DispatchOptions{Mode: osc.DispatchSerial}
+	toClient.DispatchOptions = osc.DispatchOptions{Mode: osc.DispatchSerial}
+
+	var mu sync.Mutex
+	var lastClient net.Addr
+	toClient.Dispatcher = dispatchFunc(func(packet osc.Packet, addr net.Addr) {
+		mu.Lock()
+		lastClient = addr
+		mu.Unlock()
+		if filter.show(packet) {
+			printPacket(fmt.Sprintf("%v ->", addr), packet)
+		}
+		if err := toConsole.Send(packet); err != nil {
+			fmt.Printf("error forwarding to console: %v\n", err)
+		}
+	})
+	toConsole.Dispatcher = dispatchFunc(func(packet osc.Packet, addr net.Addr) {
+		if filter.show(packet) {
+			printPacket(fmt.Sprintf("%v <-", addr), packet)
+		}
+		var err error
+		if *client != "" {
+			err = toClient.Send(packet)
+		} else {
+			mu.Lock()
+			to := lastClient
+			mu.Unlock()
+			if to == nil {
+				return
+			}
+			err = toClient.SendTo(packet, to)
+		}
+		if err != nil {
+			fmt.Printf("error forwarding to client: %v\n", err)
+		}
+	})
+
+	if err := toConsole.Open(); err != nil {
+		return err
+	}
+	defer toConsole.Close()
+	if err := toClient.Open(); err != nil {
+		return err
+	}
+	defer toClient.Close()
+
+	fmt.Printf("proxying UDP port %d to %v, replies on port %d\n", *listen, fs.Arg(0), *port)
+	errc := make(chan error, 2)
+	go func() { errc <- toConsole.Serve(ctx) }()
+	go func() { errc <- toClient.Serve(ctx) }()
+	if err := <-errc; err != ctx.Err() {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+// runSend sends one message or bundle and optionally prints the replies.
+func runSend(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: oscutil send [flags] host:port /address [,tags] [args...]\n"+
+			"       oscutil send [flags] host:port 'packet in text notation'\n\n")
+		fs.PrintDefaults()
+	}
+	port := fs.Int("port", 0, "local UDP `port` to send from and receive replies on")
+	wait := fs.Duration("wait", 0, "print replies received for this long after sending")
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return fmt.Errorf("missing destination or message")
+	}
+	packet, err := parseSendArgs(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	conn, err := localConnection(*port, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	conn.Dispatcher = dispatchFunc(func(packet osc.Packet, addr net.Addr) {
+		printPacket(addr.String(), packet)
+	})
+	if err := conn.Open(); err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if *wait > 0 {
+		go conn.Serve(ctx)
+	}
+	if err := conn.Send(packet); err != nil {
+		return err
+	}
+	if *wait > 0 {
+		select {
+		case <-time.After(*wait):
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
+// parseSendArgs turns the command line into a packet. A single argument is
+// parsed as text notation as is. Otherwise each argument is one word of the
+// message, and arguments that would not survive as a single word are quoted.
+func parseSendArgs(args []string) (osc.Packet, error) {
+	if len(args) == 1 {
+		return osc.ParseText(args[0])
+	}
+	words := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\r\n;\"'") {
+			a = strconv.Quote(a)
+		}
+		words[i] = a
+	}
+	return osc.ParseText(strings.Join(words, " "))
+}
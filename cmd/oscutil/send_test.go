@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+func TestParseSendArgs(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		want *osc.Message
+	}{
+		{name: "text notation", args: []string{`/eos/cmd "Chan 1" 5`}, want: osc.NewMessage("/eos/cmd", "Chan 1", int32(5))},
+		{name: "words", args: []string{"/eos/fader/1/1", "0.5"}, want: osc.NewMessage("/eos/fader/1/1", float32(0.5))},
+		{name: "spaces", args: []string{"/eos/cmd", "Chan 1 Full#"}, want: osc.NewMessage("/eos/cmd", "Chan 1 Full#")},
+		{name: "tab and newline", args: []string{"/eos/cmd", "a\tb\nc"}, want: osc.NewMessage("/eos/cmd", "a\tb\nc")},
+		{name: "double quotes", args: []string{"/eos/cmd", `Label "Front"`}, want: osc.NewMessage("/eos/cmd", `Label "Front"`)},
+		{name: "single quote", args: []string{"/eos/cmd", "it's"}, want: osc.NewMessage("/eos/cmd", "it's")},
+		{name: "semicolon", args: []string{"/eos/cmd", "a;b"}, want: osc.NewMessage("/eos/cmd", "a;b")},
+		{name: "empty", args: []string{"/eos/cmd", ""}, want: osc.NewMessage("/eos/cmd", "")},
+		{name: "type tags", args: []string{"/t", ",is", "1", "2"}, want: osc.NewMessage("/t", int32(1), "2")},
+		{name: "array", args: []string{"/t", "[", "1", "a b", "]"}, want: osc.NewMessage("/t", []interface{}{int32(1), "a b"})},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSendArgs(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg, ok := got.(*osc.Message); !ok || !msg.Equals(tt.want) {
+				t.Errorf("parseSendArgs(%q) = %v; want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+
+	if p, err := parseSendArgs([]string{"#bundle immediate { /eos/ping; /eos/get/version }"}); err != nil {
+		t.Error(err)
+	} else if b, ok := p.(*osc.Bundle); !ok || len(b.Messages) != 2 {
+		t.Errorf("parseSendArgs of a bundle = %v", p)
+	}
+
+	for _, args := range [][]string{{"eos/ping"}, {"/t", "[", "1"}} {
+		if _, err := parseSendArgs(args); err == nil {
+			t.Errorf("parseSendArgs(%q): no error", args)
+		}
+	}
+}
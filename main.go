@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,13 +13,27 @@ import (
 
 	//"github.com/hypebeast/go-osc/osc"
 	"github.com/tmshort/xtouch-eos/pkg/eos"
+	"github.com/tmshort/xtouch-eos/pkg/eoswing"
 	"github.com/tmshort/xtouch-eos/pkg/osc"
+	"github.com/tmshort/xtouch-eos/pkg/oscquery"
 	"github.com/tmshort/xtouch-eos/pkg/xtouch"
 	"gitlab.com/gomidi/midi/v2"
 	_ "gitlab.com/gomidi/midi/v2/drivers/rtmididrv"
 )
 
+const (
+	// oscPort is the local UDP port receiving OSC, from the console or for
+	// the OSC device
+	oscPort = 9000
+	// oscQueryAddr is where the OSCQuery description of the OSC device is
+	// served
+	oscQueryAddr = "localhost:8009"
+)
+
 func main() {
+	device := flag.String("device", "", "expose the X-Touch as a generic OSC device sending its input to `host:port`, instead of as an Eos wing")
+	flag.Parse()
+
 	defer midi.CloseDriver()
 
 	xt, err := xtouch.NewXTouch()
@@ -33,19 +50,6 @@ func main() {
 	fmt.Printf("Listening...\n")
 
 	//xt.SetLCDRaw("Hello World", 0)
-	xt.LcdDisplay(4).SetPanel("Hello", "World44444444")
-	xt.LedDisplay.SetAll("  E0S 3.14.0")
-
-	encoderHandler := func(i byte, v byte, d int8) {
-		fmt.Printf("index=%v value=%v delta=%v\n", i, v, d)
-	}
-
-	xt.Encoder(1).ModeSingle().Handler(encoderHandler).Set(4)
-	xt.Encoder(2).ModeBalance().Handler(encoderHandler).Set(4)
-	xt.Encoder(3).ModeFill().Handler(encoderHandler).Set(4)
-	xt.Encoder(4).ModeWide().Handler(encoderHandler).Set(4)
-	xt.Encoder(5).ModeContinuous().Handler(encoderHandler)
-	xt.Encoder(9).Handler(encoderHandler)
 
 	buttonHandler := func(name string, note byte, value bool) {
 		fmt.Printf("Button %v (%v) = %v\n", name, note, value)
@@ -54,17 +58,69 @@ func main() {
 	xt.Button("MARKER").PressBehavior().Handler(buttonHandler)
 	xt.Button("NUDGE").ToggleBehavior().Handler(buttonHandler)
 
-	xt.Fader(2).Handler(func(f byte, v uint16) {
-		fmt.Printf("fader %v at %v\n", f, v)
-	})
+	if *device != "" {
+		stop, err := startDevice(xt, *device)
+		if err != nil {
+			fmt.Printf("error creating OSC device: %v\n", err)
+			os.Exit(1)
+		}
+		defer stop()
+	} else {
+		stop, err := startEos(xt)
+		if err != nil {
+			fmt.Printf("error creating Eos: %v\n", err)
+			os.Exit(1)
+		}
+		defer stop()
+	}
+
+	func() {
+		select {
+		case <-timeChan:
+			return
+		case <-sigChan:
+			return
+		}
+	}()
+
+	fmt.Printf("\nCleaning up...\n")
+}
+
+// startDevice exposes the X-Touch as a generic OSC device sending its input
+// to `raddr`, and publishes its controls with OSCQuery. The returned function
+// stops it.
+func startDevice(xt *xtouch.XTouch, raddr string) (func(), error) {
+	d, err := xtouch.NewOSCDevice(xt, oscPort, raddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Start(); err != nil {
+		return nil, err
+	}
 
+	query := oscquery.NewServer("xtouch-eos", oscPort, d.Dispatcher())
+	d.Describe(query)
+	server := &http.Server{Addr: oscQueryAddr, Handler: query}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("error serving OSCQuery: %v\n", err)
+		}
+	}()
+
+	return func() {
+		server.Close()
+		d.Close()
+	}, nil
+}
+
+// startEos binds the X-Touch to the console as an Eos wing. The returned
+// function stops it.
+func startEos(xt *xtouch.XTouch) (func(), error) {
 	//e, err := eos.NewEos("0.0.0.0", "192.168.1.222")
-	e, err := eos.NewEos("0.0.0.0", "127.0.0.1")
+	e, err := eos.NewEos(fmt.Sprintf("0.0.0.0:%d", oscPort), "127.0.0.1")
 	if err != nil {
-		fmt.Printf("error creating Eos: %v\n", err)
-		os.Exit(1)
+		return nil, err
 	}
-	defer e.Close()
 
 	fmt.Printf("listening for OSC\n")
 	err = e.Handler("/eos/out/get/version", func(msg *osc.Message, addr net.Addr) {
@@ -72,28 +128,62 @@ func main() {
 		for i, a := range msg.Arguments {
 			fmt.Printf("Arg[%d]=%v\n", i, a)
 		}
-		xt.LedDisplay.SetAll(fmt.Sprintf("  E0S %v", msg.Arguments[0]))
+		version, err := msg.StringArg(0)
+		if err != nil {
+			fmt.Printf("invalid version: %v\n", err)
+			return
+		}
+		fmt.Printf("Eos version %v\n", version)
 	})
 	if err != nil {
 		fmt.Printf("error adding handler: %v\n", err)
 	}
 
+	// The assignment digits show at a glance whether the console is there
+	xt.LedDisplay.SetAll(fmt.Sprintf("%v E0S", connectionStatus(eos.StateUnknown)))
+	e.OnStateChange(func(state eos.State, rtt time.Duration) {
+		fmt.Printf("Eos %v (rtt %v)\n", state, rtt)
+		if state == eos.StateConnected {
+			xt.LedDisplay.SetAssignment(connectionStatus(state))
+		} else {
+			xt.LedDisplay.SetAll(fmt.Sprintf("%v E0S L0St", connectionStatus(state)))
+		}
+	})
+
 	e.StartServer()
 
+	wing := eoswing.New(xt, e)
+	if _, err := wing.FaderBank(1); err != nil {
+		fmt.Printf("error creating fader bank: %v\n", err)
+	}
+	if _, err := wing.Wheels(); err != nil {
+		fmt.Printf("error creating wheels: %v\n", err)
+	}
+	wing.Jog()
+	if _, err := wing.Playback(2); err != nil {
+		fmt.Printf("error creating playback: %v\n", err)
+	}
+
 	fmt.Printf("sending OSC\n")
-	err = e.SendMessage(osc.NewMessage("/eos/get/version"))
+	err = e.Subscribe(osc.NewMessage("/eos/get/version"))
 	if err != nil {
 		fmt.Printf("error sending message: %v\n", err)
 	}
 
-	func() {
-		select {
-		case <-timeChan:
-			return
-		case <-sigChan:
-			return
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := e.Shutdown(ctx); err != nil {
+			fmt.Printf("error shutting down Eos: %v\n", err)
 		}
-	}()
+	}, nil
+}
 
-	fmt.Printf("\nCleaning up...\n")
+// connectionStatus returns the two characters shown on the assignment display
+// for the state of the connection to the console.
+func connectionStatus(state eos.State) string {
+	if state == eos.StateConnected {
+		return "On"
+	}
+	return "--"
 }
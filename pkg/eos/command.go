@@ -0,0 +1,245 @@
+package eos
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+// Key is the name of an Eos key, as sent to /eos/key/<name>.
+type Key string
+
+// Keys of the Eos facepanel. Any other key name can be sent as Key("name").
+const (
+	KeyLive             Key = "live"
+	KeyBlind            Key = "blind"
+	KeyChan             Key = "chan"
+	KeyGroup            Key = "group"
+	KeyAt               Key = "at"
+	KeyThru             Key = "thru"
+	KeyPlus             Key = "+"
+	KeyMinus            Key = "-"
+	KeyFull             Key = "full"
+	KeyOut              Key = "out"
+	KeyLevel            Key = "level"
+	KeyHome             Key = "home"
+	KeySneak            Key = "sneak"
+	KeyEnter            Key = "enter"
+	KeyClear            Key = "clear_cmd"
+	KeyClearCmdLine     Key = "clear_cmdline"
+	KeyCue              Key = "cue"
+	KeyRecord           Key = "record"
+	KeyRecordOnly       Key = "record_only"
+	KeyUpdate           Key = "update"
+	KeyDelete           Key = "delete"
+	KeyTime             Key = "time"
+	KeyDelay            Key = "delay"
+	KeyPart             Key = "part"
+	KeyLabel            Key = "label"
+	KeyCopyTo           Key = "copy_to"
+	KeyRecallFrom       Key = "recall_from"
+	KeySelectLast       Key = "select_last"
+	KeySelectActive     Key = "select_active"
+	KeyHighlight        Key = "highlight"
+	KeyLast             Key = "last"
+	KeyNext             Key = "next"
+	KeyMacro            Key = "macro"
+	KeyPreset           Key = "preset"
+	KeyIntensityPalette Key = "intensity_palette"
+	KeyFocusPalette     Key = "focus_palette"
+	KeyColorPalette     Key = "color_palette"
+	KeyBeamPalette      Key = "beam_palette"
+	KeyGo               Key = "go_0"
+	KeyStopBack         Key = "stop"
+	KeyShift            Key = "shift"
+	KeyUndo             Key = "undo"
+	Key0                Key = "0"
+	Key1                Key = "1"
+	Key2                Key = "2"
+	Key3                Key = "3"
+	Key4                Key = "4"
+	Key5                Key = "5"
+	Key6                Key = "6"
+	Key7                Key = "7"
+	Key8                Key = "8"
+	Key9                Key = "9"
+	KeyPoint            Key = "."
+)
+
+// BackgroundUser is the Eos user whose commands run in the background,
+// without touching the command line of the users at the console.
+const BackgroundUser = 0
+
+// User sends commands to the console as a given Eos user, which has its own
+// command line.
+type User struct {
+	e *Eos
+	// prefix is prepended to the addresses, empty for the current user
+	prefix string
+}
+
+// AsUser returns a User sending commands as Eos user `n`, without changing
+// the user of this connection.
+func (e *Eos) AsUser(n int) (*User, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid Eos user %d", n)
+	}
+	return &User{e: e, prefix: "/eos/user/" + strconv.Itoa(n)}, nil
+}
+
+// SetUser switches the user this connection sends commands as.
+func (e *Eos) SetUser(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid Eos user %d", n)
+	}
+	return e.SendMessage(osc.NewMessage("/eos/user", int32(n)))
+}
+
+// Command adds `text` to the command line, see User.Command.
+func (e *Eos) Command(text string, args ...interface{}) error {
+	return e.user().Command(text, args...)
+}
+
+// NewCommand replaces the command line with `text`, see User.NewCommand.
+func (e *Eos) NewCommand(text string, args ...interface{}) error {
+	return e.user().NewCommand(text, args...)
+}
+
+// Submit replaces the command line with `text` and executes it, see
+// User.Submit.
+func (e *Eos) Submit(text string, args ...interface{}) error {
+	return e.user().Submit(text, args...)
+}
+
+// Key presses and releases `key`.
+func (e *Eos) Key(key Key) error {
+	return e.user().Key(key)
+}
+
+// KeyPress presses `key` if `down`, and releases it otherwise.
+func (e *Eos) KeyPress(key Key, down bool) error {
+	return e.user().KeyPress(key, down)
+}
+
+func (e *Eos) user() *User {
+	return &User{e: e}
+}
+
+// Command adds `text` to the command line. Eos replaces "%1", "%2", ... in
+// the text with the given arguments, which must be numbers or strings, and a
+// '#' executes the command line up to that point.
+func (u *User) Command(text string, args ...interface{}) error {
+	return u.command("/eos/cmd", text, args)
+}
+
+// NewCommand clears the command line and then adds `text` like Command.
+func (u *User) NewCommand(text string, args ...interface{}) error {
+	return u.command("/eos/newcmd", text, args)
+}
+
+// Submit clears the command line, adds `text` like Command and executes it.
+// A trailing '#' is added unless `text` already ends with one.
+func (u *User) Submit(text string, args ...interface{}) error {
+	if !strings.HasSuffix(strings.TrimSpace(text), "#") {
+		text += "#"
+	}
+	return u.command("/eos/newcmd", text, args)
+}
+
+// Key presses and releases `key`.
+func (u *User) Key(key Key) error {
+	if err := u.KeyPress(key, true); err != nil {
+		return err
+	}
+	return u.KeyPress(key, false)
+}
+
+// KeyPress presses `key` if `down`, and releases it otherwise.
+func (u *User) KeyPress(key Key, down bool) error {
+	if key == "" || strings.ContainsAny(string(key), "/ #,*?[]{}") {
+		return fmt.Errorf("invalid Eos key %q", key)
+	}
+	var state float32
+	if down {
+		state = 1
+	}
+	return u.e.SendMessage(osc.NewMessage(u.address("/eos/key/"+string(key)), state))
+}
+
+// SendMessage sends `msg` as the user, e.g. a fader button whose effect
+// depends on the user's command line. The address must start with "/eos".
+func (u *User) SendMessage(msg *osc.Message) error {
+	if !strings.HasPrefix(msg.Address, "/eos/") {
+		return fmt.Errorf("invalid Eos address %q", msg.Address)
+	}
+	return u.e.SendMessage(osc.NewMessage(u.address(msg.Address), msg.Arguments...))
+}
+
+// command checks the arguments against the "%N" references of `text` and
+// sends them to `addr`.
+func (u *User) command(addr, text string, args []interface{}) error {
+	if max := maxCommandArg(text); max != len(args) {
+		return fmt.Errorf("Eos command %q takes %d arguments, got %d", text, max, len(args))
+	}
+	values := make([]interface{}, 0, len(args)+1)
+	values = append(values, text)
+	for i, arg := range args {
+		v, err := commandArg(arg)
+		if err != nil {
+			return fmt.Errorf("Eos command %q: argument %d: %v", text, i+1, err)
+		}
+		values = append(values, v)
+	}
+	return u.e.SendMessage(osc.NewMessage(u.address(addr), values...))
+}
+
+// address returns `addr` for the user, whose prefix replaces "/eos".
+func (u *User) address(addr string) string {
+	if u.prefix == "" {
+		return addr
+	}
+	return u.prefix + strings.TrimPrefix(addr, "/eos")
+}
+
+// maxCommandArg returns the highest "%N" reference in `text`.
+func maxCommandArg(text string) int {
+	max := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '%' {
+			continue
+		}
+		j := i + 1
+		for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+			j++
+		}
+		if n, err := strconv.Atoi(text[i+1 : j]); err == nil && n > max {
+			max = n
+		}
+		i = j - 1
+	}
+	return max
+}
+
+// commandArg converts a command argument to an OSC argument.
+func commandArg(arg interface{}) (interface{}, error) {
+	switch v := arg.(type) {
+	case string, int32, float32:
+		return v, nil
+	case int:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, fmt.Errorf("%d is out of range", v)
+		}
+		return int32(v), nil
+	case int64:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, fmt.Errorf("%d is out of range", v)
+		}
+		return int32(v), nil
+	case float64:
+		return float32(v), nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", arg)
+}
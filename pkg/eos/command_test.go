@@ -0,0 +1,150 @@
+package eos
+
+import (
+	"testing"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+func TestCommand(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		send func(e *Eos) error
+		want []*osc.Message
+	}{
+		{
+			name: "command",
+			send: func(e *Eos) error { return e.Command("Chan 1") },
+			want: []*osc.Message{osc.NewMessage("/eos/cmd", "Chan 1")},
+		},
+		{
+			name: "new command with arguments",
+			send: func(e *Eos) error { return e.NewCommand("Chan %1 At %2", 5, 0.5) },
+			want: []*osc.Message{osc.NewMessage("/eos/newcmd", "Chan %1 At %2", int32(5), float32(0.5))},
+		},
+		{
+			name: "repeated reference",
+			send: func(e *Eos) error { return e.Command("Chan %1 Thru %1", int64(3)) },
+			want: []*osc.Message{osc.NewMessage("/eos/cmd", "Chan %1 Thru %1", int32(3))},
+		},
+		{
+			name: "submit",
+			send: func(e *Eos) error { return e.Submit("Go To Cue %1", "2.5") },
+			want: []*osc.Message{osc.NewMessage("/eos/newcmd", "Go To Cue %1#", "2.5")},
+		},
+		{
+			name: "submit terminated",
+			send: func(e *Eos) error { return e.Submit("Chan 1 Full# ") },
+			want: []*osc.Message{osc.NewMessage("/eos/newcmd", "Chan 1 Full# ")},
+		},
+		{
+			name: "key",
+			send: func(e *Eos) error { return e.Key(KeyGo) },
+			want: []*osc.Message{
+				osc.NewMessage("/eos/key/go_0", float32(1)),
+				osc.NewMessage("/eos/key/go_0", float32(0)),
+			},
+		},
+		{
+			name: "other user",
+			send: func(e *Eos) error {
+				u, err := e.AsUser(3)
+				if err != nil {
+					return err
+				}
+				if err := u.Submit("Chan 1"); err != nil {
+					return err
+				}
+				return u.KeyPress(KeyNext, true)
+			},
+			want: []*osc.Message{
+				osc.NewMessage("/eos/user/3/newcmd", "Chan 1#"),
+				osc.NewMessage("/eos/user/3/key/next", float32(1)),
+			},
+		},
+		{
+			name: "background user",
+			send: func(e *Eos) error {
+				u, err := e.AsUser(BackgroundUser)
+				if err != nil {
+					return err
+				}
+				if err := u.Submit("Cue Next"); err != nil {
+					return err
+				}
+				return u.SendMessage(osc.NewMessage("/eos/fader/2/1/load", float32(1)))
+			},
+			want: []*osc.Message{
+				osc.NewMessage("/eos/user/0/newcmd", "Cue Next#"),
+				osc.NewMessage("/eos/user/0/fader/2/1/load", float32(1)),
+			},
+		},
+		{
+			name: "set user",
+			send: func(e *Eos) error { return e.SetUser(2) },
+			want: []*osc.Message{osc.NewMessage("/eos/user", int32(2))},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newFakeConn()
+			e, _ := newTestEos(t, conn, osc.SystemClock(), 0)
+			if err := tt.send(e); err != nil {
+				t.Fatal(err)
+			}
+			if len(conn.sent) != len(tt.want) {
+				t.Fatalf("sent %v; want %v", conn.sent, tt.want)
+			}
+			for i, msg := range conn.sent {
+				if !msg.Equals(tt.want[i]) {
+					t.Errorf("sent %v; want %v", msg, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCommandInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		send func(e *Eos) error
+	}{
+		{name: "missing argument", send: func(e *Eos) error { return e.Command("Chan %1 At %2", 1) }},
+		{name: "extra argument", send: func(e *Eos) error { return e.Command("Chan 1", 1) }},
+		{name: "int out of range", send: func(e *Eos) error { return e.Command("Chan %1", 1<<40) }},
+		{name: "int64 out of range", send: func(e *Eos) error { return e.Command("Chan %1", int64(-1)<<40) }},
+		{name: "unsupported type", send: func(e *Eos) error { return e.Command("Chan %1", true) }},
+		{name: "empty key", send: func(e *Eos) error { return e.Key("") }},
+		{name: "key with slash", send: func(e *Eos) error { return e.Key("go/0") }},
+		{name: "key with wildcard", send: func(e *Eos) error { return e.KeyPress("*", true) }},
+		{name: "negative user", send: func(e *Eos) error { return e.SetUser(-1) }},
+		{name: "negative as user", send: func(e *Eos) error { _, err := e.AsUser(-1); return err }},
+		{name: "user message outside /eos", send: func(e *Eos) error { return e.user().SendMessage(osc.NewMessage("/qlab/go")) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newFakeConn()
+			e, _ := newTestEos(t, conn, osc.SystemClock(), 0)
+			if err := tt.send(e); err == nil {
+				t.Error("no error")
+			}
+			if len(conn.sent) != 0 {
+				t.Errorf("sent %v", conn.sent)
+			}
+		})
+	}
+}
+
+func TestMaxCommandArg(t *testing.T) {
+	for text, want := range map[string]int{
+		"":                0,
+		"Chan 1":          0,
+		"Chan %1":         1,
+		"Chan %2 At %1":   2,
+		"Chan %10":        10,
+		"50% At %":        0,
+		"Chan %1%2 At %3": 3,
+	} {
+		if got := maxCommandArg(text); got != want {
+			t.Errorf("maxCommandArg(%q) = %d; want %d", text, got, want)
+		}
+	}
+}
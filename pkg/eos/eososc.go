@@ -1,10 +1,12 @@
 package eos
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tmshort/xtouch-eos/pkg/osc"
 	//"github.com/hypebeast/go-osc/osc"
@@ -15,19 +17,137 @@ const (
 	QLabLocalPort     = 53001
 	EosRemotePort     = 8000
 	EosLocalPort      = 8001
+	EosTCPPort        = 3032 // OSC 1.0, length-prefixed packets
+	EosTCPSLIPPort    = 3037 // OSC 1.1, SLIP framed packets
 	defaultRemotePort = 8000
 	defaultLocalPort  = 9000
 )
 
+// connection is the transport used to talk to the console, either an
+// *osc.Connection (UDP) or an *osc.TCPConnection.
+type connection interface {
+	Open() error
+	Serve(ctx context.Context) error
+	Send(osc.Packet) error
+	Close() error
+	Shutdown(ctx context.Context) error
+}
+
 type Eos struct {
-	conn       *osc.Connection
-	dispatcher *osc.StandardDispatcher
+	conn       connection
+	dispatcher *osc.TrieDispatcher
+	health     health
+	cancel     context.CancelFunc
+}
+
+type options struct {
+	tcp              bool
+	framing          osc.Framing
+	errorHandler     osc.ErrorHandlerFunc
+	dispatch         osc.DispatchOptions
+	decode           osc.DecodeOptions
+	multicast        *osc.MulticastConfig
+	broadcast        string
+	mirrors          map[string]string
+	keepalive        time.Duration
+	keepaliveTimeout time.Duration
+	clock            osc.Clock
+}
+
+// Option configures optional behavior of NewEos.
+type Option func(*options)
+
+// WithTCP connects to the console over TCP using the given framing instead of
+// UDP. The local address is ignored, and the remote port defaults to
+// EosTCPSLIPPort or EosTCPPort depending on the framing.
+func WithTCP(framing osc.Framing) Option {
+	return func(o *options) {
+		o.tcp = true
+		o.framing = framing
+	}
+}
+
+// WithErrorHandler reports received packets that cannot be decoded. They are
+// dropped either way.
+func WithErrorHandler(handler osc.ErrorHandlerFunc) Option {
+	return func(o *options) {
+		o.errorHandler = handler
+	}
+}
+
+// WithDispatchOptions changes how messages from the console are handed to the
+// handlers. By default, messages for the same address are handled in the
+// order they arrived, so that older levels never overwrite newer ones.
+func WithDispatchOptions(dispatch osc.DispatchOptions) Option {
+	return func(o *options) {
+		o.dispatch = dispatch
+	}
+}
+
+// WithDecodeOptions changes how packets from the console are decoded, e.g. to
+// reject malformed packets from other senders on a shared network.
+func WithDecodeOptions(decode osc.DecodeOptions) Option {
+	return func(o *options) {
+		o.decode = decode
+	}
 }
 
-func NewEos(laddr, raddr string) (*Eos, error) {
+// WithMulticast joins the given multicast groups to receive the console's
+// output, e.g. when Eos sends OSC to a multicast address so that several
+// tools can listen to it. Messages are still sent to the remote address,
+// which may be a multicast group itself. UDP only.
+func WithMulticast(cfg osc.MulticastConfig) Option {
+	return func(o *options) {
+		o.multicast = &cfg
+	}
+}
+
+// WithBroadcast sends messages to the broadcast address of the named network
+// interface instead of a single console. Only the port of the remote address
+// is used. UDP only.
+func WithBroadcast(iface string) Option {
+	return func(o *options) {
+		o.broadcast = iface
+	}
+}
+
+// WithMirror also sends every message to `addr`, e.g. a backup console or a
+// logging tool, under the given name. It may be given several times. UDP only.
+func WithMirror(name, addr string) Option {
+	return func(o *options) {
+		if o.mirrors == nil {
+			o.mirrors = map[string]string{}
+		}
+		o.mirrors[name] = addr
+	}
+}
+
+func NewEos(laddr, raddr string, opts ...Option) (*Eos, error) {
 	var port int
 	var err error
 
+	o := options{
+		dispatch:         osc.DispatchOptions{Mode: osc.DispatchPerAddress},
+		keepalive:        DefaultKeepaliveInterval,
+		keepaliveTimeout: DefaultKeepaliveTimeout,
+		clock:            osc.SystemClock(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.tcp && (o.multicast != nil || o.broadcast != "" || len(o.mirrors) > 0) {
+		return nil, fmt.Errorf("multicast, broadcast and mirrors need UDP")
+	}
+
+	remotePort := defaultRemotePort
+	if o.tcp {
+		remotePort = EosTCPSLIPPort
+		if o.framing == osc.FramingLengthPrefix {
+			remotePort = EosTCPPort
+		}
+	}
+
 	args := strings.Split(raddr, ":")
 	switch {
 	case len(args) == 2:
@@ -35,11 +155,35 @@ func NewEos(laddr, raddr string) (*Eos, error) {
 			return nil, err
 		}
 	case len(args) == 1:
-		raddr = fmt.Sprintf("%s:%d", args[0], defaultRemotePort)
+		raddr = fmt.Sprintf("%s:%d", args[0], remotePort)
 	default:
 		return nil, fmt.Errorf("invalid raddr: %v", raddr)
 	}
 
+	if o.broadcast != "" {
+		_, rport, err := net.SplitHostPort(raddr)
+		if err != nil {
+			return nil, err
+		}
+		port, _ := strconv.Atoi(rport)
+		if raddr, err = osc.BroadcastAddress(o.broadcast, port); err != nil {
+			return nil, err
+		}
+	}
+
+	dispatcher := osc.NewTrieDispatcher()
+	if o.tcp {
+		conn, err := osc.NewTCPConnection(raddr, o.framing)
+		if err != nil {
+			return nil, err
+		}
+		conn.Dispatcher = dispatcher
+		conn.ErrorHandler = o.errorHandler
+		conn.DispatchOptions = o.dispatch
+		conn.DecodeOptions = o.decode
+		return newEos(conn, dispatcher, o)
+	}
+
 	args = strings.Split(laddr, ":")
 	switch {
 	case len(args) == 2:
@@ -51,32 +195,89 @@ func NewEos(laddr, raddr string) (*Eos, error) {
 	default:
 		return nil, fmt.Errorf("invalid laddr: %v", laddr)
 	}
-	dispatcher := osc.NewStandardDispatcher()
 	conn, err := osc.NewConnection(port, raddr)
 	if err != nil {
 		return nil, err
 	}
 	conn.Dispatcher = dispatcher
+	conn.ErrorHandler = o.errorHandler
+	conn.DispatchOptions = o.dispatch
+	conn.DecodeOptions = o.decode
+	if o.multicast != nil {
+		if err = conn.SetMulticast(*o.multicast); err != nil {
+			return nil, err
+		}
+	}
+	for name, addr := range o.mirrors {
+		if err = conn.AddDestination(name, addr); err != nil {
+			return nil, err
+		}
+	}
+
+	return newEos(conn, dispatcher, o)
+}
 
-	return &Eos{conn: conn, dispatcher: dispatcher}, nil
+func newEos(conn connection, dispatcher *osc.TrieDispatcher, o options) (*Eos, error) {
+	e := &Eos{conn: conn, dispatcher: dispatcher}
+	e.health = health{
+		interval: o.keepalive,
+		timeout:  o.keepaliveTimeout,
+		clock:    o.clock,
+		sent:     map[int32]time.Time{},
+	}
+	if err := dispatcher.AddMsgHandler("/eos/out/ping", e.pong); err != nil {
+		return nil, err
+	}
+	return e, nil
 }
 
 func (e *Eos) Handler(prefix string, handler func(msg *osc.Message, addr net.Addr)) error {
 	return e.dispatcher.AddMsgHandler(prefix, handler)
 }
 
+// SetHandler installs the handler for the given OSC address, replacing any
+// handler already installed for it.
+func (e *Eos) SetHandler(addr string, handler func(msg *osc.Message, addr net.Addr)) error {
+	return e.dispatcher.SetMsgHandler(addr, handler)
+}
+
+// RemoveHandler removes the handler installed for the given OSC address.
+func (e *Eos) RemoveHandler(addr string) bool {
+	return e.dispatcher.RemoveMsgHandler(addr)
+}
+
 func (e *Eos) Close() error {
+	e.stop()
 	return e.conn.Close()
 }
 
+// Shutdown stops receiving from the console and waits for the handlers still
+// running to return, or for `ctx` to expire.
+func (e *Eos) Shutdown(ctx context.Context) error {
+	e.stop()
+	return e.conn.Shutdown(ctx)
+}
+
+// StartServer connects to the console, starts receiving from it and starts
+// pinging it. A failed TCP connection is reopened until Close or Shutdown.
 func (e *Eos) StartServer() error {
 	if err := e.conn.Open(); err != nil {
 		return err
 	}
-	go e.conn.Serve()
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	go e.serve(ctx)
+	e.startKeepalive()
 	return nil
 }
 
+func (e *Eos) stop() {
+	e.stopKeepalive()
+	if e.cancel != nil {
+		e.cancel()
+	}
+}
+
 func (e *Eos) SendMessage(msg *osc.Message) error {
 	return e.conn.Send(msg)
 }
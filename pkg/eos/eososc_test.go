@@ -0,0 +1,61 @@
+package eos
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+func TestNewEosUDPOnly(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"multicast": WithMulticast(osc.MulticastConfig{Groups: []string{"239.1.1.1"}}),
+		"broadcast": WithBroadcast("eth0"),
+		"mirror":    WithMirror("backup", "10.101.100.102:8000"),
+	} {
+		if _, err := NewEos("", "10.101.100.101", WithTCP(osc.FramingSLIP), opt); err == nil {
+			t.Errorf("%s over TCP: no error", name)
+		}
+		// The interface may not exist; see TestNewEosBroadcast
+		if name == "broadcast" {
+			continue
+		}
+		e, err := NewEos(":0", "10.101.100.101", opt)
+		if err != nil {
+			t.Errorf("%s over UDP: %v", name, err)
+			continue
+		}
+		e.Close()
+	}
+
+	if _, err := NewEos(":0", "10.101.100.101", WithMulticast(osc.MulticastConfig{Groups: []string{"10.101.100.101"}})); err == nil {
+		t.Error("WithMulticast of a unicast address: no error")
+	}
+}
+
+func TestNewEosBroadcast(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface named lo")
+	}
+	want, err := osc.BroadcastAddress(lo.Name, 8000)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// Only the port of the remote address is used
+	e, err := NewEos(":0", "10.101.100.101:8000", WithBroadcast(lo.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	conn := e.conn.(*osc.Connection)
+	if got := fmt.Sprintf("%s:%d", conn.RemoteAddress(), conn.RemotePort()); got != want {
+		t.Errorf("remote address %s; want %s", got, want)
+	}
+
+	if _, err := NewEos(":0", "10.101.100.101", WithBroadcast("no-such-interface")); err == nil {
+		t.Error("WithBroadcast of an unknown interface: no error")
+	}
+}
@@ -0,0 +1,333 @@
+package eos
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+const (
+	// DefaultKeepaliveInterval is how often the console is pinged.
+	DefaultKeepaliveInterval = 2 * time.Second
+	// DefaultKeepaliveTimeout is how long the console may stay silent before
+	// it is considered disconnected.
+	DefaultKeepaliveTimeout = 5 * time.Second
+
+	// pingTag is the first argument of our pings, so that replies to other
+	// applications' pings are ignored.
+	pingTag = "xtouch-eos"
+)
+
+// State is the state of the connection to the console.
+type State int
+
+const (
+	// StateUnknown means the console has not replied yet.
+	StateUnknown State = iota
+	// StateConnected means the console replies to pings.
+	StateConnected
+	// StateDisconnected means the console has not replied within the
+	// keepalive timeout.
+	StateDisconnected
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+// StateHandler is called when the connection to the console changes state.
+// The round-trip time is that of the ping that reconnected, or zero when
+// disconnected.
+type StateHandler func(state State, rtt time.Duration)
+
+// reopener is implemented by connections that can reconnect after the stream
+// failed, i.e. *osc.TCPConnection.
+type reopener interface {
+	Reopen() error
+}
+
+// health tracks the console with /eos/ping keepalives.
+type health struct {
+	interval time.Duration
+	timeout  time.Duration
+	clock    osc.Clock
+
+	mu            sync.Mutex
+	state         State
+	rtt           time.Duration
+	lastSeen      time.Time
+	seq           int32
+	sent          map[int32]time.Time
+	handlers      []StateHandler
+	subscriptions []*osc.Message
+	timer         osc.ClockTimer
+	stopped       bool
+
+	// notifyMu keeps the handlers called in the order of the state changes
+	notifyMu sync.Mutex
+}
+
+// WithKeepalive changes how often the console is pinged and how long it may
+// stay silent before it is considered disconnected. An interval of zero
+// disables the keepalives, so that only a failed TCP stream is noticed.
+func WithKeepalive(interval, timeout time.Duration) Option {
+	return func(o *options) {
+		o.keepalive = interval
+		o.keepaliveTimeout = timeout
+	}
+}
+
+// WithClock replaces the system clock used for keepalives, e.g. with an
+// osc.FakeClock.
+func WithClock(clock osc.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// Clock returns the clock of the connection, see WithClock.
+func (e *Eos) Clock() osc.Clock {
+	return e.health.clock
+}
+
+// State returns the state of the connection to the console.
+func (e *Eos) State() State {
+	e.health.mu.Lock()
+	defer e.health.mu.Unlock()
+	return e.health.state
+}
+
+// RTT returns the round-trip time of the last answered ping.
+func (e *Eos) RTT() time.Duration {
+	e.health.mu.Lock()
+	defer e.health.mu.Unlock()
+	return e.health.rtt
+}
+
+// OnStateChange adds a handler called whenever the console connects or
+// disconnects. Handlers must not block.
+func (e *Eos) OnStateChange(handler StateHandler) {
+	e.health.mu.Lock()
+	defer e.health.mu.Unlock()
+	e.health.handlers = append(e.health.handlers, handler)
+}
+
+// Subscribe sends `msg` to the console now and again every time the console
+// reconnects. Use it for requests whose effect is lost when the console
+// restarts, such as /eos/subscribe or /eos/get/version.
+func (e *Eos) Subscribe(msg *osc.Message) error {
+	e.health.mu.Lock()
+	e.health.subscriptions = append(e.health.subscriptions, msg)
+	e.health.mu.Unlock()
+	return e.conn.Send(msg)
+}
+
+// startKeepalive sends the first ping and schedules the following ones.
+func (e *Eos) startKeepalive() {
+	h := &e.health
+	if h.interval <= 0 {
+		return
+	}
+	h.mu.Lock()
+	h.lastSeen = h.clock.Now()
+	h.mu.Unlock()
+	e.keepalive()
+}
+
+// stopKeepalive stops pinging the console.
+func (e *Eos) stopKeepalive() {
+	h := &e.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopped = true
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+}
+
+// keepalive pings the console, and reports it disconnected if it has not
+// replied within the timeout.
+func (e *Eos) keepalive() {
+	h := &e.health
+	now := h.clock.Now()
+
+	h.mu.Lock()
+	if h.stopped {
+		h.mu.Unlock()
+		return
+	}
+	for seq, sent := range h.sent {
+		if now.Sub(sent) > h.timeout {
+			delete(h.sent, seq)
+		}
+	}
+	lost := h.state != StateDisconnected && now.Sub(h.lastSeen) > h.timeout
+	h.timer = h.clock.AfterFunc(h.interval, e.keepalive)
+	if lost {
+		e.setState(StateDisconnected, 0)
+	} else {
+		h.mu.Unlock()
+	}
+	e.ping()
+}
+
+// ping sends a ping numbered so that its reply gives the round-trip time.
+func (e *Eos) ping() {
+	h := &e.health
+	h.mu.Lock()
+	h.seq++
+	seq := h.seq
+	h.sent[seq] = h.clock.Now()
+	h.mu.Unlock()
+
+	// A failed send shows up as a missing reply
+	e.conn.Send(osc.NewMessage("/eos/ping", pingTag, seq))
+}
+
+// pong handles the console's replies to pings.
+func (e *Eos) pong(msg *osc.Message, addr net.Addr) {
+	h := &e.health
+	if tag, err := msg.StringArg(0); err != nil || tag != pingTag {
+		return
+	}
+	seq, err := msg.Int32(1)
+	if err != nil {
+		return
+	}
+	now := h.clock.Now()
+
+	h.mu.Lock()
+	sent, ok := h.sent[seq]
+	if !ok || h.stopped {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.sent, seq)
+	h.lastSeen = now
+	h.rtt = now.Sub(sent)
+	if h.state == StateConnected {
+		h.mu.Unlock()
+		return
+	}
+	e.connected(h.rtt)
+}
+
+// connected reports the console connected. If it was disconnected before, the
+// subscriptions are resent, as the console may have restarted; Subscribe sent
+// them for the first connection. It must be called with health.mu held, and
+// releases it.
+func (e *Eos) connected(rtt time.Duration) {
+	h := &e.health
+	var subscriptions []*osc.Message
+	if h.state == StateDisconnected {
+		subscriptions = append(subscriptions, h.subscriptions...)
+	}
+	e.setState(StateConnected, rtt)
+
+	for _, msg := range subscriptions {
+		e.conn.Send(msg)
+	}
+}
+
+// lost reports the console disconnected right away, e.g. when the TCP stream
+// failed.
+func (e *Eos) lost() {
+	h := &e.health
+	h.mu.Lock()
+	if h.state != StateDisconnected && !h.stopped {
+		e.setState(StateDisconnected, 0)
+	} else {
+		h.mu.Unlock()
+	}
+}
+
+// setState changes the state and calls the handlers. It must be called with
+// health.mu held, and releases it.
+func (e *Eos) setState(state State, rtt time.Duration) {
+	h := &e.health
+	h.state = state
+	if state != StateConnected {
+		h.rtt = 0
+	}
+	handlers := append([]StateHandler(nil), h.handlers...)
+	h.notifyMu.Lock()
+	defer h.notifyMu.Unlock()
+	h.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(state, rtt)
+	}
+}
+
+// serve receives from the console until the connection is closed. A failed
+// TCP stream is reopened, retrying every keepalive interval.
+func (e *Eos) serve(ctx context.Context) {
+	for {
+		err := e.conn.Serve(ctx)
+		if errors.Is(err, osc.ErrConnectionClosed) || ctx.Err() != nil {
+			return
+		}
+		r, ok := e.conn.(reopener)
+		if !ok {
+			return
+		}
+		e.lost()
+
+		delay := e.health.interval
+		if delay <= 0 {
+			delay = DefaultKeepaliveInterval
+		}
+		for {
+			if !e.sleep(ctx, delay) {
+				return
+			}
+			if err := r.Reopen(); err == nil {
+				break
+			} else if errors.Is(err, osc.ErrConnectionClosed) {
+				return
+			}
+		}
+		// Ping right away rather than waiting for the next keepalive. Without
+		// keepalives, the new stream is all there is to go by.
+		if e.health.interval > 0 {
+			e.ping()
+		} else {
+			e.reopened()
+		}
+	}
+}
+
+// reopened reports the console connected after the TCP stream was reopened.
+func (e *Eos) reopened() {
+	h := &e.health
+	h.mu.Lock()
+	if h.state != StateConnected && !h.stopped {
+		e.connected(0)
+	} else {
+		h.mu.Unlock()
+	}
+}
+
+// sleep waits for `d` on the keepalive clock. It returns false if `ctx` is
+// cancelled first.
+func (e *Eos) sleep(ctx context.Context, d time.Duration) bool {
+	done := make(chan struct{})
+	t := e.health.clock.AfterFunc(d, func() { close(done) })
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		t.Stop()
+		return false
+	}
+}
@@ -0,0 +1,233 @@
+package eos
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+// fakeConn is a connection that records the messages sent, and whose Serve
+// returns the errors sent on `serve`.
+type fakeConn struct {
+	mu    sync.Mutex
+	sent  []*osc.Message
+	serve chan error
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{serve: make(chan error)}
+}
+
+func (c *fakeConn) Open() error                        { return nil }
+func (c *fakeConn) Close() error                       { return nil }
+func (c *fakeConn) Shutdown(ctx context.Context) error { return nil }
+
+func (c *fakeConn) Serve(ctx context.Context) error {
+	select {
+	case err := <-c.serve:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *fakeConn) Send(p osc.Packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, p.(*osc.Message))
+	return nil
+}
+
+// sentTo returns the messages sent to `addr`.
+func (c *fakeConn) sentTo(addr string) []*osc.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var msgs []*osc.Message
+	for _, msg := range c.sent {
+		if msg.Address == addr {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// lastPing returns the sequence number of the last ping sent.
+func (c *fakeConn) lastPing(t *testing.T) int32 {
+	t.Helper()
+	pings := c.sentTo("/eos/ping")
+	if len(pings) == 0 {
+		t.Fatal("no ping sent")
+	}
+	seq, err := pings[len(pings)-1].Int32(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seq
+}
+
+// reopenConn is a fakeConn that can be reopened, like an
+// *osc.TCPConnection.
+type reopenConn struct {
+	*fakeConn
+}
+
+func (c reopenConn) Reopen() error { return nil }
+
+// newTestEos returns an Eos on `conn` whose state changes are sent on the
+// returned channel.
+func newTestEos(t *testing.T, conn connection, clock osc.Clock, interval time.Duration) (*Eos, chan State) {
+	t.Helper()
+	e, err := newEos(conn, osc.NewTrieDispatcher(), options{
+		keepalive:        interval,
+		keepaliveTimeout: DefaultKeepaliveTimeout,
+		clock:            clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make(chan State, 16)
+	e.OnStateChange(func(state State, _ time.Duration) {
+		states <- state
+	})
+	return e, states
+}
+
+// expectState waits for the next state change.
+func expectState(t *testing.T, states chan State, want State) {
+	t.Helper()
+	select {
+	case got := <-states:
+		if got != want {
+			t.Fatalf("state changed to %v; want %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no state change; want %v", want)
+	}
+}
+
+// expectNoState checks that the state has not changed.
+func expectNoState(t *testing.T, states chan State) {
+	t.Helper()
+	select {
+	case got := <-states:
+		t.Fatalf("state changed to %v", got)
+	default:
+	}
+}
+
+func pongMessage(tag string, seq int32) *osc.Message {
+	return osc.NewMessage("/eos/out/ping", tag, seq)
+}
+
+func TestKeepalive(t *testing.T) {
+	conn := newFakeConn()
+	clock := osc.NewFakeClock(time.Unix(0, 0))
+	e, states := newTestEos(t, conn, clock, DefaultKeepaliveInterval)
+	subscribe := osc.NewMessage("/eos/subscribe", int32(1))
+
+	if err := e.Subscribe(subscribe); err != nil {
+		t.Fatal(err)
+	}
+	e.startKeepalive()
+	if got := e.State(); got != StateUnknown {
+		t.Fatalf("State = %v; want %v", got, StateUnknown)
+	}
+
+	// Replies to other pings are ignored
+	clock.Advance(10 * time.Millisecond)
+	e.pong(pongMessage("other", conn.lastPing(t)), nil)
+	e.pong(pongMessage(pingTag, conn.lastPing(t)+1), nil)
+	expectNoState(t, states)
+
+	e.pong(pongMessage(pingTag, conn.lastPing(t)), nil)
+	expectState(t, states, StateConnected)
+	if got := e.RTT(); got != 10*time.Millisecond {
+		t.Errorf("RTT = %v; want %v", got, 10*time.Millisecond)
+	}
+	if got := len(conn.sentTo("/eos/subscribe")); got != 1 {
+		t.Errorf("subscription sent %d times on the first connection; want 1", got)
+	}
+
+	// The keepalives go unanswered
+	clock.Advance(DefaultKeepaliveTimeout)
+	expectNoState(t, states)
+	clock.Advance(DefaultKeepaliveInterval)
+	expectState(t, states, StateDisconnected)
+	if got := e.RTT(); got != 0 {
+		t.Errorf("RTT while disconnected = %v; want 0", got)
+	}
+	clock.Advance(DefaultKeepaliveTimeout)
+	expectNoState(t, states)
+
+	// The console comes back
+	e.pong(pongMessage(pingTag, conn.lastPing(t)), nil)
+	expectState(t, states, StateConnected)
+	if got := len(conn.sentTo("/eos/subscribe")); got != 2 {
+		t.Errorf("subscription sent %d times after reconnecting; want 2", got)
+	}
+
+	e.stopKeepalive()
+	if got := clock.PendingTimers(); got != 0 {
+		t.Errorf("%d timers pending after stopping", got)
+	}
+	pings := len(conn.sentTo("/eos/ping"))
+	clock.Advance(10 * DefaultKeepaliveInterval)
+	if got := len(conn.sentTo("/eos/ping")); got != pings {
+		t.Errorf("%d pings sent after stopping", got-pings)
+	}
+	expectNoState(t, states)
+}
+
+func TestReopen(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		interval time.Duration
+	}{
+		{name: "keepalive", interval: DefaultKeepaliveInterval},
+		{name: "no keepalive", interval: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newFakeConn()
+			clock := osc.NewFakeClock(time.Unix(0, 0))
+			e, states := newTestEos(t, reopenConn{conn}, clock, tt.interval)
+			if err := e.Subscribe(osc.NewMessage("/eos/subscribe", int32(1))); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				e.serve(ctx)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			conn.serve <- errors.New("connection reset")
+			expectState(t, states, StateDisconnected)
+
+			// serve waits a keepalive interval before reopening
+			for clock.PendingTimers() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			clock.Advance(DefaultKeepaliveInterval)
+			if tt.interval > 0 {
+				// The reopened stream is pinged right away
+				for len(conn.sentTo("/eos/ping")) == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				e.pong(pongMessage(pingTag, conn.lastPing(t)), nil)
+			}
+			expectState(t, states, StateConnected)
+			if got := len(conn.sentTo("/eos/subscribe")); got != 2 {
+				t.Errorf("subscription sent %d times; want 2", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package eoswing
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+var (
+	faderTemplate       = osc.MustTemplate("/eos/fader/{bank}/{fader}", ",f")
+	faderButtonTemplate = osc.MustTemplate("/eos/fader/{bank}/{fader}/{button}", ",f")
+	faderPageTemplate   = osc.MustTemplate("/eos/fader/{bank}/page/{delta}", "")
+	faderConfigTemplate = osc.MustTemplate("/eos/fader/{bank}/config/{count}", "")
+)
+
+// FaderBank binds the eight channel faders of an X-Touch to an Eos OSC fader
+// bank:
+//
+//	fader N           /eos/fader/<bank>/N, the motor follows the console
+//	LCD N             fader name and level
+//	MUTE/N            fire
+//	SELECT/N          stop, or load while SHIFT is held
+//	FADER BANK/LEFT   previous page
+//	FADER BANK/RIGHT  next page
+//
+// Levels from the console do not move a fader while it is touched, and
+// touching a fader shows the fader names on the LCDs.
+type FaderBank struct {
+	w    *Wing
+	bank int
+
+	mu     sync.Mutex
+	faders [Strips]bankFader
+}
+
+// bankFader is the state of a fader as last reported by the console.
+type bankFader struct {
+	name    string
+	level   float32
+	touched bool
+}
+
+// FaderBank configures the Eos fader bank `bank` with eight faders and binds
+// it to the X-Touch. The configuration is sent again whenever the console
+// reconnects.
+func (w *Wing) FaderBank(bank int) (*FaderBank, error) {
+	if bank < 1 {
+		return nil, fmt.Errorf("invalid Eos fader bank %d", bank)
+	}
+	b := &FaderBank{w: w, bank: bank}
+	x, e := w.x, w.e
+
+	level := osc.MustTemplate(fmt.Sprintf("/eos/out/fader/%d/{fader}", bank), ",f")
+	if err := e.Handler(level.Pattern(), level.Handler(b.handleLevel)); err != nil {
+		return nil, err
+	}
+	name := osc.MustTemplate(fmt.Sprintf("/eos/out/fader/%d/{fader}/name", bank), ",s")
+	if err := e.Handler(name.Pattern(), name.Handler(b.handleName)); err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= Strips; i++ {
+		n := i
+		x.Fader(byte(n)).Handler(func(_ byte, _ uint16) {
+			w.send(faderTemplate.MustMessage(b.bank, n, x.Fader(byte(n)).Level()))
+		})
+		x.Button(fmt.Sprintf("FADER/%d", n)).PressBehavior().Handler(func(_ string, _ byte, down bool) {
+			b.touch(n, down)
+		})
+		x.Button(fmt.Sprintf("MUTE/%d", n)).PressBehavior().Handler(func(_ string, _ byte, down bool) {
+			b.press(n, "fire", down)
+		})
+		x.Button(fmt.Sprintf("SELECT/%d", n)).PressBehavior().Handler(func(_ string, _ byte, down bool) {
+			button := "stop"
+			if w.shift() {
+				button = "load"
+			}
+			b.press(n, button, down)
+		})
+	}
+	x.Button("FADER BANK/LEFT").PressBehavior().Handler(func(_ string, _ byte, down bool) {
+		if down {
+			b.Page(-1)
+		}
+	})
+	x.Button("FADER BANK/RIGHT").PressBehavior().Handler(func(_ string, _ byte, down bool) {
+		if down {
+			b.Page(1)
+		}
+	})
+
+	if err := e.Subscribe(faderConfigTemplate.MustMessage(bank, Strips)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Bank returns the index of the Eos fader bank.
+func (b *FaderBank) Bank() int {
+	return b.bank
+}
+
+// Page pages the fader bank by `delta` pages.
+func (b *FaderBank) Page(delta int) error {
+	return b.w.e.SendMessage(faderPageTemplate.MustMessage(b.bank, delta))
+}
+
+// press sends a fader button press or release.
+func (b *FaderBank) press(n int, button string, down bool) {
+	var v float32
+	if down {
+		v = 1
+	}
+	b.w.send(faderButtonTemplate.MustMessage(b.bank, n, button, v))
+}
+
+// touch tracks whether fader `n` is touched. On release, the motor returns to
+// the level of the console, in case the fader was moved past it.
+func (b *FaderBank) touch(n int, down bool) {
+	b.mu.Lock()
+	f := &b.faders[n-1]
+	f.touched = down
+	level := f.level
+	b.mu.Unlock()
+
+	if down {
+		b.w.strips.show(layerFaders)
+	} else {
+		b.w.x.Fader(byte(n)).SetLevel(float64(level))
+	}
+}
+
+// fader returns the fader state for the parameters of a received address, or
+// nil if it is not one of the X-Touch faders. It must be called with mu held.
+func (b *FaderBank) fader(params osc.Params) (int, *bankFader) {
+	n, err := params.Int("fader")
+	if err != nil || n < 1 || n > Strips {
+		return 0, nil
+	}
+	return n, &b.faders[n-1]
+}
+
+func (b *FaderBank) handleLevel(msg *osc.Message, params osc.Params, _ net.Addr) {
+	v, err := msg.Float(0)
+	if err != nil {
+		return
+	}
+	b.mu.Lock()
+	n, f := b.fader(params)
+	if f == nil {
+		b.mu.Unlock()
+		return
+	}
+	f.level = float32(v)
+	touched := f.touched
+	name := f.name
+	b.mu.Unlock()
+
+	if !touched {
+		b.w.x.Fader(byte(n)).SetLevel(v)
+	}
+	b.display(n, name, float32(v))
+}
+
+func (b *FaderBank) handleName(msg *osc.Message, params osc.Params, _ net.Addr) {
+	name, err := msg.StringArg(0)
+	if err != nil {
+		return
+	}
+	b.mu.Lock()
+	n, f := b.fader(params)
+	if f == nil {
+		b.mu.Unlock()
+		return
+	}
+	f.name = name
+	level := f.level
+	b.mu.Unlock()
+
+	b.display(n, name, level)
+}
+
+// display shows the name and level of fader `n` on its LCD.
+func (b *FaderBank) display(n int, name string, level float32) {
+	b.w.strips.set(layerFaders, n, name, fmt.Sprintf("%5.0f%%", level*100))
+}
@@ -0,0 +1,114 @@
+package eoswing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+	"gitlab.com/gomidi/midi/v2"
+)
+
+// newTestFaderBank returns a FaderBank of Eos fader bank 1, with its
+// configuration received by the console.
+func newTestFaderBank(t *testing.T) (*Wing, *console) {
+	t.Helper()
+	w, c := newTestWing(t)
+	if _, err := w.FaderBank(1); err != nil {
+		t.Fatal(err)
+	}
+	c.expect(t, osc.NewMessage("/eos/fader/1/config/8"))
+	return w, c
+}
+
+// motorAt returns a condition for fader `n` being at `level`.
+func motorAt(w *Wing, n int, level float32) func() bool {
+	return func() bool {
+		return math.Abs(float64(w.x.Fader(byte(n)).Level()-level)) < 0.001
+	}
+}
+
+func TestFaderBankInvalid(t *testing.T) {
+	w, c := newTestWing(t)
+	if _, err := w.FaderBank(0); err == nil {
+		t.Error("FaderBank(0): no error")
+	}
+	c.expect(t)
+}
+
+func TestFaderBankFeedback(t *testing.T) {
+	w, c := newTestFaderBank(t)
+
+	c.send(t, osc.NewMessage("/eos/out/fader/1/2/name", "Front"))
+	c.send(t, osc.NewMessage("/eos/out/fader/1/2", float32(0.5)))
+	eventually(t, "fader 2 at 50%", motorAt(w, 2, 0.5))
+	eventually(t, "the name of fader 2", func() bool {
+		return w.lcd(layerFaders, 2) == [2]string{"Front", "   50%"}
+	})
+
+	// Faders of other banks, and past the eighth, are ignored
+	c.send(t, osc.NewMessage("/eos/out/fader/2/3", float32(1)))
+	c.send(t, osc.NewMessage("/eos/out/fader/1/9", float32(1)))
+	c.send(t, osc.NewMessage("/eos/out/fader/1/3", float32(0.25)))
+	eventually(t, "fader 3 at 25%", motorAt(w, 3, 0.25))
+	if got := w.x.Fader(9).Level(); got != 0 {
+		t.Errorf("main fader at %v; want 0", got)
+	}
+	c.expect(t)
+}
+
+func TestFaderBankTouch(t *testing.T) {
+	w, c := newTestFaderBank(t)
+	c.send(t, osc.NewMessage("/eos/out/fader/1/4", float32(0.5)))
+	eventually(t, "fader 4 at 50%", motorAt(w, 4, 0.5))
+
+	// The console does not move a touched fader, but its LCD follows
+	w.press(t, "FADER/4", true)
+	c.send(t, osc.NewMessage("/eos/out/fader/1/4", float32(0.75)))
+	eventually(t, "the level of fader 4", func() bool {
+		return w.lcd(layerFaders, 4)[1] == "   75%"
+	})
+	if !motorAt(w, 4, 0.5)() {
+		t.Errorf("touched fader 4 moved to %v", w.x.Fader(4).Level())
+	}
+
+	// Moving the fader sends its level
+	if err := w.x.Receive(midi.Pitchbend(3, 8191)); err != nil {
+		t.Fatal(err)
+	}
+	c.expect(t, osc.NewMessage("/eos/fader/1/4", float32(1)))
+
+	// On release, the motor returns to the level of the console
+	w.press(t, "FADER/4", false)
+	if !motorAt(w, 4, 0.75)() {
+		t.Errorf("released fader 4 at %v; want 0.75", w.x.Fader(4).Level())
+	}
+	c.expect(t)
+}
+
+func TestFaderBankButtons(t *testing.T) {
+	w, c := newTestFaderBank(t)
+
+	for _, tt := range []struct {
+		name   string
+		shift  bool
+		button string
+		want   string
+	}{
+		{button: "MUTE/3", want: "/eos/fader/1/3/fire"},
+		{button: "SELECT/5", want: "/eos/fader/1/5/stop"},
+		{button: "SELECT/5", shift: true, want: "/eos/fader/1/5/load"},
+		{button: "MUTE/8", shift: true, want: "/eos/fader/1/8/fire"},
+	} {
+		w.press(t, "SHIFT", tt.shift)
+		w.press(t, tt.button, true)
+		w.press(t, tt.button, false)
+		c.expect(t, osc.NewMessage(tt.want, float32(1)), osc.NewMessage(tt.want, float32(0)))
+	}
+	w.press(t, "SHIFT", false)
+
+	w.press(t, "FADER BANK/RIGHT", true)
+	w.press(t, "FADER BANK/RIGHT", false)
+	w.press(t, "FADER BANK/LEFT", true)
+	w.press(t, "FADER BANK/LEFT", false)
+	c.expect(t, osc.NewMessage("/eos/fader/1/page/1"), osc.NewMessage("/eos/fader/1/page/-1"))
+}
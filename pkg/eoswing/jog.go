@@ -0,0 +1,155 @@
+package eoswing
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/eos"
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+const (
+	// jogAccelWindow is the time between ticks below which the jog wheel
+	// accelerates, in proportion to the tick rate.
+	jogAccelWindow = 40 * time.Millisecond
+	// jogMaxAccel is the largest number of ticks sent per tick.
+	jogMaxAccel = 8
+	// jogStepTicks is the number of ticks per step in the stepping modes.
+	jogStepTicks = 4
+)
+
+// errNoPlayback is reported when the jog wheel steps through the cues without
+// a Playback.
+var errNoPlayback = errors.New("no playback")
+
+// JogMode is what the jog wheel controls.
+type JogMode int
+
+const (
+	// JogLevel turns the level wheel of the selected channels.
+	JogLevel JogMode = iota
+	// JogCue loads the next or previous cue as pending on the Playback, like
+	// FAST-FORWARD and REWIND.
+	JogCue
+	// JogChannel steps through the channels with Next and Last.
+	JogChannel
+	// JogRate turns the rate of the selected playback.
+	JogRate
+	jogModes
+)
+
+func (m JogMode) String() string {
+	switch m {
+	case JogLevel:
+		return "level"
+	case JogCue:
+		return "cue"
+	case JogChannel:
+		return "channel"
+	case JogRate:
+		return "rate"
+	}
+	return "unknown"
+}
+
+// Jog binds the jog wheel of an X-Touch to the console. It starts as the
+// level wheel, and the SCRUB button switches to the next mode; SCRUB is lit
+// in every mode but JogLevel. The faster the wheel turns, the more ticks
+// are sent in the level and rate modes, timed by the clock of the console
+// connection.
+type Jog struct {
+	w     *Wing
+	clock osc.Clock
+
+	mu    sync.Mutex
+	mode  JogMode
+	last  time.Time
+	steps int
+}
+
+// Jog binds the jog wheel.
+func (w *Wing) Jog() *Jog {
+	j := &Jog{w: w, clock: w.e.Clock()}
+	w.x.Encoder(9).ModeContinuous().Handler(func(_ byte, _ byte, delta int8) {
+		j.turn(delta)
+	})
+	w.x.Button("SCRUB").ReportBehavior().Handler(func(_ string, _ byte, down bool) {
+		if down {
+			j.SetMode((j.Mode() + 1) % jogModes)
+		}
+	})
+	return j
+}
+
+// Mode returns what the jog wheel controls.
+func (j *Jog) Mode() JogMode {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.mode
+}
+
+// SetMode changes what the jog wheel controls.
+func (j *Jog) SetMode(mode JogMode) {
+	j.mu.Lock()
+	j.mode = mode
+	j.steps = 0
+	j.mu.Unlock()
+
+	if mode == JogLevel {
+		j.w.x.Button("SCRUB").Off()
+	} else {
+		j.w.x.Button("SCRUB").On()
+	}
+}
+
+// turn handles `delta` ticks of the jog wheel.
+func (j *Jog) turn(delta int8) {
+	now := j.clock.Now()
+	j.mu.Lock()
+	mode := j.mode
+	accel := j.accel(now.Sub(j.last))
+	j.last = now
+	steps := 0
+	if mode == JogCue || mode == JogChannel {
+		j.steps += int(delta)
+		steps = j.steps / jogStepTicks
+		j.steps %= jogStepTicks
+	}
+	j.mu.Unlock()
+
+	switch mode {
+	case JogLevel:
+		j.w.send(osc.NewMessage("/eos/wheel/level", float32(delta)*accel))
+	case JogRate:
+		j.w.send(osc.NewMessage("/eos/wheel/rate", float32(delta)*accel))
+	case JogCue:
+		if steps == 0 {
+			break
+		}
+		if p := j.w.playback.Load(); p != nil {
+			j.w.report("cue step", p.Step(steps))
+		} else {
+			j.w.report("cue step", errNoPlayback)
+		}
+	case JogChannel:
+		for ; steps > 0; steps-- {
+			j.w.report("next", j.w.e.Key(eos.KeyNext))
+		}
+		for ; steps < 0; steps++ {
+			j.w.report("last", j.w.e.Key(eos.KeyLast))
+		}
+	}
+}
+
+// accel returns the acceleration for ticks `dt` apart.
+func (j *Jog) accel(dt time.Duration) float32 {
+	if dt <= 0 || dt >= jogAccelWindow {
+		return 1
+	}
+	accel := float32(jogAccelWindow) / float32(dt)
+	if accel > jogMaxAccel {
+		accel = jogMaxAccel
+	}
+	return accel
+}
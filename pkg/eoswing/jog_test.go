@@ -0,0 +1,83 @@
+package eoswing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/eos"
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+func TestJogAccel(t *testing.T) {
+	clock := osc.NewFakeClock(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	w, c := newTestWing(t, eos.WithClock(clock))
+	j := w.Jog()
+
+	for _, tt := range []struct {
+		name  string
+		after time.Duration
+		delta int8
+		want  float32
+	}{
+		{name: "first tick", delta: 1, want: 1},
+		{name: "slow", after: time.Second, delta: 1, want: 1},
+		{name: "at the window", after: jogAccelWindow, delta: -1, want: -1},
+		{name: "twice the window rate", after: jogAccelWindow / 2, delta: 1, want: 2},
+		{name: "several ticks", after: jogAccelWindow / 4, delta: -2, want: -8},
+		{name: "capped", after: time.Millisecond, delta: 1, want: jogMaxAccel},
+		{name: "slow again", after: 100 * time.Millisecond, delta: 1, want: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.Advance(tt.after)
+			j.turn(tt.delta)
+			c.expect(t, osc.NewMessage("/eos/wheel/level", tt.want))
+		})
+	}
+
+	j.SetMode(JogRate)
+	clock.Advance(jogAccelWindow / 2)
+	j.turn(1)
+	c.expect(t, osc.NewMessage("/eos/wheel/rate", float32(2)))
+}
+
+func TestJogStep(t *testing.T) {
+	clock := osc.NewFakeClock(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	w, c := newTestWing(t, eos.WithClock(clock))
+	j := w.Jog()
+
+	// Without a playback, there are no cues to step through
+	j.SetMode(JogCue)
+	j.turn(jogStepTicks)
+	c.expect(t)
+
+	// The stepping modes do not accelerate, and the cue mode loads the cues
+	// on the playback like FAST-FORWARD and REWIND
+	if _, err := w.Playback(2); err != nil {
+		t.Fatal(err)
+	}
+	c.expect(t, osc.NewMessage("/eos/fader/2/config/1"))
+	j.turn(jogStepTicks - 1)
+	c.expect(t)
+	j.turn(1)
+	var want []*osc.Message
+	for _, text := range []string{"Cue Next", "Cue Last", "Cue Last"} {
+		want = append(want,
+			osc.NewMessage("/eos/user/0/newcmd", text),
+			osc.NewMessage("/eos/user/0/fader/2/1/load", float32(1)),
+			osc.NewMessage("/eos/user/0/fader/2/1/load", float32(0)),
+		)
+	}
+	c.expect(t, want[:3]...)
+	j.turn(-2 * jogStepTicks)
+	c.expect(t, want[3:]...)
+
+	w.press(t, "FAST-FORWARD", true)
+	c.expect(t, want[:3]...)
+
+	j.SetMode(JogChannel)
+	j.turn(jogStepTicks)
+	c.expect(t,
+		osc.NewMessage("/eos/key/next", float32(1)),
+		osc.NewMessage("/eos/key/next", float32(0)),
+	)
+}
@@ -0,0 +1,269 @@
+package eoswing
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/eos"
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+const pendingCueAddr = "/eos/out/pending/cue"
+
+var (
+	activeCueTemplate  = osc.MustTemplate("/eos/out/active/cue/{list}/{cue}", "")
+	pendingCueTemplate = osc.MustTemplate(pendingCueAddr+"/{list}/{cue}", "")
+)
+
+// Playback binds the transport buttons and the main fader of an X-Touch to
+// the playback on the one fader of an Eos fader bank:
+//
+//	PLAY          /eos/fader/<bank>/1/fire, Go
+//	STOP          /eos/fader/<bank>/1/stop, Stop/Back
+//	REWIND        load the previous cue as pending
+//	FAST-FORWARD  load the next cue as pending
+//	main fader    /eos/fader/<bank>/1, the motor follows the console
+//
+// PLAY is lit while a cue is pending and STOP while a cue is fading. The
+// seven-segment display shows the active cue on the bars and beats digits,
+// and the pending cue on the subdivision and ticks digits.
+type Playback struct {
+	w    *Wing
+	bank int
+
+	mu      sync.Mutex
+	active  string
+	pending string
+	fading  bool
+	level   float32
+	touched bool
+}
+
+// Playback configures the Eos fader bank `bank` with the one fader of the
+// playback and binds it to the X-Touch. It must not be the bank of a
+// FaderBank.
+func (w *Wing) Playback(bank int) (*Playback, error) {
+	if bank < 1 {
+		return nil, fmt.Errorf("invalid Eos fader bank %d", bank)
+	}
+	p := &Playback{w: w, bank: bank}
+	x, e := w.x, w.e
+
+	if err := e.Handler(activeCueTemplate.Pattern(), activeCueTemplate.Handler(p.handleActive)); err != nil {
+		return nil, err
+	}
+	if err := e.Handler(pendingCueTemplate.Pattern(), pendingCueTemplate.Handler(p.handlePending)); err != nil {
+		return nil, err
+	}
+	// Without a pending cue, the console sends the address alone and an empty
+	// text
+	if err := e.Handler(pendingCueAddr, p.handleNoPending); err != nil {
+		return nil, err
+	}
+	if err := e.Handler(pendingCueAddr+"/text", p.handleNoPending); err != nil {
+		return nil, err
+	}
+	if err := e.Handler(fmt.Sprintf("/eos/out/fader/%d/1", bank), p.handleLevel); err != nil {
+		return nil, err
+	}
+
+	x.Button("PLAY").ReportBehavior().Handler(func(_ string, _ byte, down bool) {
+		p.press("fire", down)
+	})
+	x.Button("STOP").ReportBehavior().Handler(func(_ string, _ byte, down bool) {
+		p.press("stop", down)
+	})
+	x.Button("REWIND").PressBehavior().Handler(func(_ string, _ byte, down bool) {
+		if down {
+			w.report("cue last", p.Step(-1))
+		}
+	})
+	x.Button("FAST-FORWARD").PressBehavior().Handler(func(_ string, _ byte, down bool) {
+		if down {
+			w.report("cue next", p.Step(1))
+		}
+	})
+	x.Fader(9).Handler(func(_ byte, _ uint16) {
+		w.send(faderTemplate.MustMessage(p.bank, 1, x.Fader(9).Level()))
+	})
+	x.Button("FADER/MAIN").PressBehavior().Handler(func(_ string, _ byte, down bool) {
+		p.touch(down)
+	})
+
+	// The display is overwritten while the console is away
+	e.OnStateChange(func(state eos.State, _ time.Duration) {
+		if state == eos.StateConnected {
+			p.draw()
+		}
+	})
+
+	if err := e.Subscribe(faderConfigTemplate.MustMessage(bank, 1)); err != nil {
+		return nil, err
+	}
+	w.playback.Store(p)
+	return p, nil
+}
+
+// Step loads the cue `delta` cues after the pending cue, or before it if
+// `delta` is negative, as the pending cue of the playback. The cues are
+// loaded as the background user, so that the command line of the operator
+// is left alone.
+func (p *Playback) Step(delta int) error {
+	text := "Cue Next"
+	if delta < 0 {
+		text, delta = "Cue Last", -delta
+	}
+	for ; delta > 0; delta-- {
+		if err := p.w.bg.NewCommand(text); err != nil {
+			return err
+		}
+		for _, v := range []float32{1, 0} {
+			if err := p.w.bg.SendMessage(faderButtonTemplate.MustMessage(p.bank, 1, "load", v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// press presses or releases `button` of the playback fader.
+func (p *Playback) press(button string, down bool) {
+	var v float32
+	if down {
+		v = 1
+	}
+	p.w.send(faderButtonTemplate.MustMessage(p.bank, 1, button, v))
+}
+
+// touch tracks whether the main fader is touched. On release, the motor
+// returns to the level of the console.
+func (p *Playback) touch(down bool) {
+	p.mu.Lock()
+	p.touched = down
+	level := p.level
+	p.mu.Unlock()
+
+	if !down {
+		p.w.x.Fader(9).SetLevel(float64(level))
+	}
+}
+
+func (p *Playback) handleLevel(msg *osc.Message, _ net.Addr) {
+	v, err := msg.Float(0)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	p.level = float32(v)
+	touched := p.touched
+	p.mu.Unlock()
+
+	if !touched {
+		p.w.x.Fader(9).SetLevel(v)
+	}
+}
+
+// handleActive handles the active cue and its progress from 0 to 1.
+func (p *Playback) handleActive(msg *osc.Message, params osc.Params, _ net.Addr) {
+	progress, err := msg.Float(0)
+	if err != nil {
+		progress = 1
+	}
+	p.mu.Lock()
+	p.active = params["cue"]
+	p.fading = progress < 1
+	p.mu.Unlock()
+	p.draw()
+}
+
+// handlePending handles the pending cue.
+func (p *Playback) handlePending(_ *osc.Message, params osc.Params, _ net.Addr) {
+	p.setPending(params["cue"])
+}
+
+// handleNoPending handles the pending cue address without a cue, and its
+// text, which is empty without a pending cue.
+func (p *Playback) handleNoPending(msg *osc.Message, _ net.Addr) {
+	if text, err := msg.StringArg(0); err == nil && text != "" {
+		return
+	}
+	p.setPending("")
+}
+
+func (p *Playback) setPending(cue string) {
+	p.mu.Lock()
+	p.pending = cue
+	p.mu.Unlock()
+	p.draw()
+}
+
+// draw shows the cues on the seven-segment display and lights the buttons.
+func (p *Playback) draw() {
+	p.mu.Lock()
+	active, pending, fading := p.active, p.pending, p.fading
+	p.mu.Unlock()
+
+	x := p.w.x
+	digits := splitDigits(fitDigits(active, 5)+fitDigits(pending, 5), 3, 2, 2, 3)
+	x.LedDisplay.SetBars(digits[0])
+	x.LedDisplay.SetBeats(digits[1])
+	x.LedDisplay.SetSubdivision(digits[2])
+	x.LedDisplay.SetTicks(digits[3])
+
+	if pending != "" {
+		x.Button("PLAY").On()
+	} else {
+		x.Button("PLAY").Off()
+	}
+	if fading {
+		x.Button("STOP").On()
+	} else {
+		x.Button("STOP").Off()
+	}
+}
+
+// fitDigits returns the cue number `cue` left-padded to `width` digits of the
+// seven-segment display, where a '.' shares the digit before it. Longer cue
+// numbers keep their last digits.
+func fitDigits(cue string, width int) string {
+	n := 0
+	for i := len(cue) - 1; i >= 0; i-- {
+		if cue[i] == '.' {
+			continue
+		}
+		if n == width {
+			// A '.' cannot start the display
+			cue = strings.TrimLeft(cue[i+1:], ".")
+			break
+		}
+		n++
+	}
+	for ; n < width; n++ {
+		cue = " " + cue
+	}
+	return cue
+}
+
+// splitDigits splits `text` into parts of the given numbers of digits of the
+// seven-segment display.
+func splitDigits(text string, widths ...int) []string {
+	parts := make([]string, len(widths))
+	part, n := 0, 0
+	for i := 0; i < len(text) && part < len(widths); i++ {
+		if text[i] != '.' && n == widths[part] {
+			part++
+			n = 0
+			if part == len(widths) {
+				break
+			}
+		}
+		parts[part] += text[i : i+1]
+		if text[i] != '.' {
+			n++
+		}
+	}
+	return parts
+}
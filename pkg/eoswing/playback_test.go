@@ -0,0 +1,112 @@
+package eoswing
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+func TestFitDigits(t *testing.T) {
+	for _, tt := range []struct {
+		cue   string
+		width int
+		want  string
+	}{
+		{cue: "", width: 5, want: "     "},
+		{cue: "12", width: 5, want: "   12"},
+		{cue: "2.5", width: 5, want: "   2.5"},
+		{cue: "1234.5", width: 5, want: "1234.5"},
+		{cue: "123456", width: 5, want: "23456"},
+		{cue: "101234.5", width: 5, want: "1234.5"},
+		{cue: "1.23456", width: 5, want: "23456"},
+		{cue: "12", width: 2, want: "12"},
+	} {
+		if got := fitDigits(tt.cue, tt.width); got != tt.want {
+			t.Errorf("fitDigits(%q, %d) = %q; want %q", tt.cue, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestSplitDigits(t *testing.T) {
+	for _, tt := range []struct {
+		text   string
+		widths []int
+		want   []string
+	}{
+		{text: "   2.5   12", widths: []int{3, 2, 2, 3}, want: []string{"   ", "2.5", "  ", " 12"}},
+		{text: "1.2", widths: []int{1, 1}, want: []string{"1.", "2"}},
+		{text: "1..2", widths: []int{1, 1}, want: []string{"1..", "2"}},
+		{text: "12345", widths: []int{2, 2}, want: []string{"12", "34"}},
+		{text: "12", widths: []int{2, 2}, want: []string{"12", ""}},
+		{text: "", widths: []int{2}, want: []string{""}},
+	} {
+		if got := splitDigits(tt.text, tt.widths...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitDigits(%q, %v) = %q; want %q", tt.text, tt.widths, got, tt.want)
+		}
+	}
+}
+
+func TestPlaybackPending(t *testing.T) {
+	w, _ := newTestWing(t)
+	p, err := w.Playback(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := pendingCueTemplate.Handler(p.handlePending)
+
+	for _, tt := range []struct {
+		name string
+		send func()
+		want string
+	}{
+		{name: "cue", send: func() { pending(osc.NewMessage("/eos/out/pending/cue/1/5"), nil) }, want: "5"},
+		{name: "no cue", send: func() { p.handleNoPending(osc.NewMessage("/eos/out/pending/cue"), nil) }},
+		{name: "cue again", send: func() { pending(osc.NewMessage("/eos/out/pending/cue/1/6.5"), nil) }, want: "6.5"},
+		{name: "text", send: func() { p.handleNoPending(osc.NewMessage("/eos/out/pending/cue/text", "1/6.5 Act 2"), nil) }, want: "6.5"},
+		{name: "empty text", send: func() { p.handleNoPending(osc.NewMessage("/eos/out/pending/cue/text", ""), nil) }},
+	} {
+		tt.send()
+		p.mu.Lock()
+		got := p.pending
+		p.mu.Unlock()
+		if got != tt.want {
+			t.Errorf("%s: pending %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlaybackTransport(t *testing.T) {
+	w, c := newTestWing(t)
+	p, err := w.Playback(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.expect(t, osc.NewMessage("/eos/fader/2/config/1"))
+
+	// Go and Stop/Back act on the playback fader, like the loaded cues
+	p.press("fire", true)
+	p.press("fire", false)
+	p.press("stop", true)
+	c.expect(t,
+		osc.NewMessage("/eos/fader/2/1/fire", float32(1)),
+		osc.NewMessage("/eos/fader/2/1/fire", float32(0)),
+		osc.NewMessage("/eos/fader/2/1/stop", float32(1)),
+	)
+
+	if err := p.Step(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Step(-2); err != nil {
+		t.Fatal(err)
+	}
+	var want []*osc.Message
+	for _, text := range []string{"Cue Next", "Cue Last", "Cue Last"} {
+		want = append(want,
+			osc.NewMessage("/eos/user/0/newcmd", text),
+			osc.NewMessage("/eos/user/0/fader/2/1/load", float32(1)),
+			osc.NewMessage("/eos/user/0/fader/2/1/load", float32(0)),
+		)
+	}
+	c.expect(t, want...)
+}
@@ -0,0 +1,178 @@
+package eoswing
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+var (
+	activeWheelTemplate = osc.MustTemplate("/eos/out/active/wheel/{wheel}", ",s*")
+	wheelTemplate       = osc.MustTemplate("/eos/wheel/{mode}/{wheel}", ",f")
+)
+
+// Wheels binds the eight encoders of an X-Touch to the parameter wheels of
+// the channels selected on the console:
+//
+//	encoder N       /eos/wheel/coarse/<wheel>, fine while SHIFT is held
+//	LCD N           parameter name and value
+//	CHANNEL/LEFT    previous eight wheels
+//	CHANNEL/RIGHT   next eight wheels
+//
+// Turning an encoder shows the wheels on the LCDs.
+type Wheels struct {
+	w *Wing
+
+	mu     sync.Mutex
+	wheels map[int]wheel
+	page   int
+}
+
+// wheel is a parameter wheel as last reported by the console.
+type wheel struct {
+	name  string
+	value string
+}
+
+// Wheels binds the encoders to the parameter wheels.
+func (w *Wing) Wheels() (*Wheels, error) {
+	ws := &Wheels{w: w, wheels: map[int]wheel{}}
+	x, e := w.x, w.e
+
+	if err := e.Handler(activeWheelTemplate.Pattern(), activeWheelTemplate.Handler(ws.handleWheel)); err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= Strips; i++ {
+		x.Encoder(byte(i)).ModeContinuous().Handler(func(index byte, _ byte, delta int8) {
+			ws.turn(int(index), delta)
+		})
+	}
+	x.Button("CHANNEL/LEFT").PressBehavior().Handler(func(_ string, _ byte, down bool) {
+		if down {
+			ws.Page(-1)
+		}
+	})
+	x.Button("CHANNEL/RIGHT").PressBehavior().Handler(func(_ string, _ byte, down bool) {
+		if down {
+			ws.Page(1)
+		}
+	})
+	return ws, nil
+}
+
+// Page shows the wheels `delta` pages of eight further. It does not page past
+// the first or the last wheel.
+func (ws *Wheels) Page(delta int) {
+	ws.mu.Lock()
+	changed := ws.setPage(ws.page + delta)
+	ws.mu.Unlock()
+
+	if changed {
+		ws.draw()
+	}
+	ws.w.strips.show(layerWheels)
+}
+
+// setPage changes the page to `page`, clamped to the pages of wheels, and
+// returns whether it changed. It must be called with mu held.
+func (ws *Wheels) setPage(page int) bool {
+	if last := (ws.count() - 1) / Strips; page > last {
+		page = last
+	}
+	if page < 0 {
+		page = 0
+	}
+	changed := page != ws.page
+	ws.page = page
+	return changed
+}
+
+// count returns the number of wheels. It must be called with mu held.
+func (ws *Wheels) count() int {
+	count := 0
+	for n, wh := range ws.wheels {
+		if wh.name != "" && n > count {
+			count = n
+		}
+	}
+	return count
+}
+
+// turn sends the ticks of encoder `index` to its wheel. Encoders without a
+// wheel are ignored.
+func (ws *Wheels) turn(index int, delta int8) {
+	ws.mu.Lock()
+	n := ws.page*Strips + index
+	named := ws.wheels[n].name != ""
+	ws.mu.Unlock()
+	if !named {
+		return
+	}
+
+	mode := "coarse"
+	if ws.w.shift() {
+		mode = "fine"
+	}
+	ws.w.strips.show(layerWheels)
+	ws.w.send(wheelTemplate.MustMessage(mode, n, float32(delta)))
+}
+
+func (ws *Wheels) handleWheel(msg *osc.Message, params osc.Params, _ net.Addr) {
+	n, err := params.Int("wheel")
+	if err != nil || n < 1 {
+		return
+	}
+	label, err := msg.StringArg(0)
+	if err != nil {
+		return
+	}
+	wh := wheel{}
+	wh.name, wh.value = parseWheelLabel(label)
+	if wh.name != "" && wh.value == "" {
+		// The label has no value; the third argument is the raw value
+		if v, err := msg.Float(2); err == nil {
+			wh.value = strconv.FormatFloat(v, 'f', -1, 32)
+		}
+	}
+
+	ws.mu.Lock()
+	ws.wheels[n] = wh
+	// The number of wheels follows the selection, the page may now be past the last
+	changed := ws.setPage(ws.page)
+	index := n - ws.page*Strips
+	ws.mu.Unlock()
+
+	if changed {
+		ws.draw()
+	} else if index >= 1 && index <= Strips {
+		ws.w.strips.set(layerWheels, index, wh.name, wh.value)
+	}
+}
+
+// draw displays the wheels of the current page.
+func (ws *Wheels) draw() {
+	ws.mu.Lock()
+	var page [Strips]wheel
+	for i := range page {
+		page[i] = ws.wheels[ws.page*Strips+i+1]
+	}
+	ws.mu.Unlock()
+
+	for i, wh := range page {
+		ws.w.strips.set(layerWheels, i+1, wh.name, wh.value)
+	}
+}
+
+// parseWheelLabel splits a wheel label such as "Pan  [127]" into the
+// parameter name and value.
+func parseWheelLabel(label string) (string, string) {
+	label = strings.TrimSpace(label)
+	if i := strings.LastIndexByte(label, '['); i >= 0 && strings.HasSuffix(label, "]") {
+		return strings.TrimSpace(label[:i]), strings.TrimSpace(label[i+1 : len(label)-1])
+	}
+	return label, ""
+}
@@ -0,0 +1,101 @@
+package eoswing
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+func TestParseWheelLabel(t *testing.T) {
+	for _, tt := range []struct {
+		label string
+		name  string
+		value string
+	}{
+		{label: "Pan  [127]", name: "Pan", value: "127"},
+		{label: " Intens [ 50 ] ", name: "Intens", value: "50"},
+		{label: "Gobo [1] [Open]", name: "Gobo [1]", value: "Open"},
+		{label: "Zoom", name: "Zoom"},
+		{label: "Zoom [", name: "Zoom ["},
+		{label: "Edge]", name: "Edge]"},
+		{label: ""},
+	} {
+		name, value := parseWheelLabel(tt.label)
+		if name != tt.name || value != tt.value {
+			t.Errorf("parseWheelLabel(%q) = %q, %q; want %q, %q", tt.label, name, value, tt.name, tt.value)
+		}
+	}
+}
+
+// setWheels reports wheels 1 to `n` as named, and the ones up to `max` as
+// cleared, as the console does when the selection changes.
+func setWheels(ws *Wheels, n, max int) {
+	h := activeWheelTemplate.Handler(ws.handleWheel)
+	for i := 1; i <= max; i++ {
+		label := ""
+		if i <= n {
+			label = fmt.Sprintf("Wheel %d [%d]", i, i)
+		}
+		h(osc.NewMessage(fmt.Sprintf("/eos/out/active/wheel/%d", i), label, int32(0), float32(i)), nil)
+	}
+}
+
+func TestWheelsPage(t *testing.T) {
+	w, _ := newTestWing(t)
+	ws, err := w.Wheels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setWheels(ws, 20, 20)
+
+	for _, tt := range []struct {
+		delta int
+		want  int
+	}{
+		{delta: -1, want: 0},
+		{delta: 1, want: 1},
+		{delta: 1, want: 2},
+		{delta: 1, want: 2},
+		{delta: -5, want: 0},
+		{delta: 5, want: 2},
+	} {
+		ws.Page(tt.delta)
+		if ws.page != tt.want {
+			t.Errorf("Page(%d): page %d; want %d", tt.delta, ws.page, tt.want)
+		}
+	}
+	if got, want := w.strips.text[layerWheels][3], [2]string{"Wheel 20", "20"}; got != want {
+		t.Errorf("LCD 4 shows %q; want %q", got, want)
+	}
+
+	// Fewer wheels move the page back to the last one and redraw it
+	setWheels(ws, 10, 20)
+	if ws.page != 1 {
+		t.Errorf("page %d after the wheels changed; want 1", ws.page)
+	}
+	for i, want := range [Strips][2]string{{"Wheel 9", "9"}, {"Wheel 10", "10"}} {
+		if got := w.strips.text[layerWheels][i]; got != want {
+			t.Errorf("LCD %d shows %q; want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestWheelsTurn(t *testing.T) {
+	w, c := newTestWing(t)
+	ws, err := w.Wheels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setWheels(ws, 10, 10)
+
+	ws.turn(2, 3)
+	c.expect(t, osc.NewMessage("/eos/wheel/coarse/2", float32(3)))
+
+	// The second page has only two wheels
+	ws.Page(1)
+	ws.turn(2, -1)
+	c.expect(t, osc.NewMessage("/eos/wheel/coarse/10", float32(-1)))
+	ws.turn(3, 1)
+	c.expect(t)
+}
@@ -0,0 +1,119 @@
+// Package eoswing maps the controls of an X-Touch to an Eos console, so that
+// the X-Touch works as a fader and parameter wing.
+package eoswing
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tmshort/xtouch-eos/pkg/eos"
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+	"github.com/tmshort/xtouch-eos/pkg/xtouch"
+)
+
+// Strips is the number of channel strips of the X-Touch, each with a fader,
+// an encoder and an LCD.
+const Strips = 8
+
+// Wing binds an X-Touch to an Eos console. The mappings are added with its
+// methods, e.g. FaderBank and Wheels.
+type Wing struct {
+	x      *xtouch.XTouch
+	e      *eos.Eos
+	strips *strips
+	// bg runs the commands of the controls, leaving the command line of the
+	// operator alone
+	bg *eos.User
+	// playback is the Playback bound to the transport, if any, which the jog
+	// wheel steps through the cues of
+	playback atomic.Pointer[Playback]
+}
+
+// New returns a Wing for the X-Touch and the console. The NAME/VALUE button
+// switches the LCDs between the fader names and the parameter wheels.
+func New(x *xtouch.XTouch, e *eos.Eos) *Wing {
+	bg, _ := e.AsUser(eos.BackgroundUser) // only fails for negative users
+	w := &Wing{x: x, e: e, strips: &strips{x: x}, bg: bg}
+	x.Button("SHIFT").PressBehavior()
+	x.Button("NAME/VALUE").PressBehavior().Handler(func(_ string, _ byte, down bool) {
+		if down {
+			w.strips.toggle()
+		}
+	})
+	return w
+}
+
+// shift returns whether SHIFT is held.
+func (w *Wing) shift() bool {
+	return w.x.Button("SHIFT").Value()
+}
+
+func (w *Wing) send(msg *osc.Message) {
+	w.report(msg.Address, w.e.SendMessage(msg))
+}
+
+// report prints the error of sending `what` to the console, if any. The
+// handlers of the X-Touch have no caller to return it to.
+func (w *Wing) report(what string, err error) {
+	if err != nil {
+		fmt.Printf("error sending %v: %v\n", what, err)
+	}
+}
+
+////
+// LCD strips
+////
+
+// layer is what the LCDs show.
+type layer int
+
+const (
+	layerFaders layer = iota
+	layerWheels
+	layerCount
+)
+
+// strips shares the LCDs between the mappings. Each mapping writes to its own
+// layer, and only the shown layer is displayed.
+type strips struct {
+	x     *xtouch.XTouch
+	mu    sync.Mutex
+	text  [layerCount][Strips][2]string
+	shown layer
+}
+
+// set changes the text of LCD `n` in the layer.
+func (s *strips) set(l layer, n int, top, bottom string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.text[l][n-1] = [2]string{top, bottom}
+	if s.shown == l {
+		s.x.LcdDisplay(byte(n)).SetPanel(top, bottom)
+	}
+}
+
+// show displays the layer.
+func (s *strips) show(l layer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shown != l {
+		s.shown = l
+		s.draw()
+	}
+}
+
+// toggle displays the next layer.
+func (s *strips) toggle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shown = (s.shown + 1) % layerCount
+	s.draw()
+}
+
+// draw displays the shown layer. It must be called with mu held.
+func (s *strips) draw() {
+	for i, text := range s.text[s.shown] {
+		s.x.LcdDisplay(byte(i+1)).SetPanel(text[0], text[1])
+	}
+}
@@ -0,0 +1,143 @@
+package eoswing
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/eos"
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+	"github.com/tmshort/xtouch-eos/pkg/xtouch"
+	"gitlab.com/gomidi/midi/v2"
+)
+
+// console receives what a Wing sends to the console over UDP, and answers
+// the address it was last sent from.
+type console struct {
+	conn *net.UDPConn
+	addr net.Addr
+}
+
+// newTestWing returns a Wing for a fake X-Touch, talking to a console on the
+// loopback interface. The keepalives are disabled.
+func newTestWing(t *testing.T, opts ...eos.Option) (*Wing, *console) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	opts = append([]eos.Option{eos.WithKeepalive(0, 0)}, opts...)
+	e, err := eos.NewEos("127.0.0.1:0", conn.LocalAddr().String(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.StartServer(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { e.Close() })
+
+	x, err := xtouch.NewFakeXTouch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(x, e), &console{conn: conn}
+}
+
+// next returns the next message sent to the console, or nil if none is sent
+// within `timeout`.
+func (c *console) next(t *testing.T, timeout time.Duration) *osc.Message {
+	t.Helper()
+	buf := make([]byte, 65536)
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	n, addr, err := c.conn.ReadFrom(buf)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.addr = addr
+	p, err := osc.ParsePacketBytes(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, ok := p.(*osc.Message)
+	if !ok {
+		t.Fatalf("received %v; want a message", p)
+	}
+	return msg
+}
+
+// expect checks the messages sent to the console, and that nothing else is.
+func (c *console) expect(t *testing.T, want ...*osc.Message) {
+	t.Helper()
+	for _, w := range want {
+		got := c.next(t, time.Second)
+		if got == nil {
+			t.Fatalf("nothing sent; want %v", w)
+		}
+		// Compare the encodings, the decoded arguments are never nil
+		if !bytes.Equal(mustMarshal(t, got), mustMarshal(t, w)) {
+			t.Errorf("sent %v; want %v", got, w)
+		}
+	}
+	if got := c.next(t, 50*time.Millisecond); got != nil {
+		t.Errorf("sent %v; want nothing", got)
+	}
+}
+
+// send sends `msg` from the console to the Wing, which must have sent
+// something to the console first.
+func (c *console) send(t *testing.T, msg *osc.Message) {
+	t.Helper()
+	if c.addr == nil {
+		t.Fatal("the console does not know the address of the Wing")
+	}
+	if _, err := c.conn.WriteTo(mustMarshal(t, msg), c.addr); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// eventually waits for `cond`, which the handlers of the Wing satisfy on
+// their own goroutines.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// lcd returns the text of LCD `n` in the layer.
+func (w *Wing) lcd(l layer, n int) [2]string {
+	w.strips.mu.Lock()
+	defer w.strips.mu.Unlock()
+	return w.strips.text[l][n-1]
+}
+
+// press presses or releases the X-Touch button `name`.
+func (w *Wing) press(t *testing.T, name string, down bool) {
+	t.Helper()
+	var v uint8
+	if down {
+		v = 127
+	}
+	if err := w.x.Receive(midi.NoteOn(0, w.x.Button(name).Note(), v)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustMarshal(t *testing.T, msg *osc.Message) []byte {
+	t.Helper()
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,294 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrMissingArgument is returned when a message has fewer arguments than
+	// requested.
+	ErrMissingArgument = errors.New("missing argument")
+	// ErrArgumentType is returned when an argument cannot be converted to the
+	// requested type.
+	ErrArgumentType = errors.New("wrong argument type")
+)
+
+// ArgumentError describes an argument that is missing or has the wrong type.
+// Use errors.Is with ErrMissingArgument or ErrArgumentType to tell the cases
+// apart.
+type ArgumentError struct {
+	Address string
+	Index   int
+	Want    string
+	Value   interface{}
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *ArgumentError) Error() string {
+	if e.Err == ErrMissingArgument {
+		return fmt.Sprintf("%s: argument %d: %v", e.Address, e.Index, e.Err)
+	}
+	return fmt.Sprintf("%s: argument %d: %v: want %s, got %T", e.Address, e.Index, e.Err, e.Want, e.Value)
+}
+
+// Unwrap returns ErrMissingArgument or ErrArgumentType.
+func (e *ArgumentError) Unwrap() error {
+	return e.Err
+}
+
+////
+// Typed accessors
+////
+
+// argument returns the argument at index `i` or an ArgumentError if there is
+// none.
+func (msg *Message) argument(i int, want string) (interface{}, error) {
+	if i < 0 || i >= len(msg.Arguments) {
+		return nil, &ArgumentError{Address: msg.Address, Index: i, Want: want, Err: ErrMissingArgument}
+	}
+	return msg.Arguments[i], nil
+}
+
+// typeError returns an ArgumentError for an argument of the wrong type.
+func (msg *Message) typeError(i int, want string) error {
+	return &ArgumentError{Address: msg.Address, Index: i, Want: want, Value: msg.Arguments[i], Err: ErrArgumentType}
+}
+
+// Int32 returns argument `i` as an int32. Integer arguments that fit into an
+// int32 are accepted, as are floating point arguments without a fractional
+// part.
+func (msg *Message) Int32(i int) (int32, error) {
+	v, err := msg.Int64(i)
+	if err != nil {
+		if errors.Is(err, ErrArgumentType) {
+			return 0, msg.typeError(i, "int32")
+		}
+		return 0, err
+	}
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		return 0, msg.typeError(i, "int32")
+	}
+	return int32(v), nil
+}
+
+// Int64 returns argument `i` as an int64. Integer arguments are accepted, as
+// are floating point arguments without a fractional part.
+func (msg *Message) Int64(i int) (int64, error) {
+	arg, err := msg.argument(i, "int64")
+	if err != nil {
+		return 0, err
+	}
+	switch v := arg.(type) {
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float32:
+		if n, ok := floatInt64(float64(v)); ok {
+			return n, nil
+		}
+	case float64:
+		if n, ok := floatInt64(v); ok {
+			return n, nil
+		}
+	}
+	return 0, msg.typeError(i, "int64")
+}
+
+// floatInt64 converts `v` to an int64 if it is a whole number in range. The
+// range excludes 1<<63, which is a float64 but not an int64, and ±Inf.
+func floatInt64(v float64) (int64, bool) {
+	if v == math.Trunc(v) && v >= -1<<63 && v < 1<<63 {
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// Float returns argument `i` as a float64. Both floating point and integer
+// arguments are accepted.
+func (msg *Message) Float(i int) (float64, error) {
+	arg, err := msg.argument(i, "float")
+	if err != nil {
+		return 0, err
+	}
+	switch v := arg.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	}
+	return 0, msg.typeError(i, "float")
+}
+
+// StringArg returns argument `i` as a string. String and symbol arguments are
+// accepted. (The name String is taken by the fmt.Stringer implementation.)
+func (msg *Message) StringArg(i int) (string, error) {
+	arg, err := msg.argument(i, "string")
+	if err != nil {
+		return "", err
+	}
+	switch v := arg.(type) {
+	case string:
+		return v, nil
+	case Symbol:
+		return string(v), nil
+	}
+	return "", msg.typeError(i, "string")
+}
+
+// Bool returns argument `i` as a bool. Besides the OSC true and false
+// arguments, numeric arguments are accepted and are true if they are not
+// zero.
+func (msg *Message) Bool(i int) (bool, error) {
+	arg, err := msg.argument(i, "bool")
+	if err != nil {
+		return false, err
+	}
+	switch v := arg.(type) {
+	case bool:
+		return v, nil
+	case int32:
+		return v != 0, nil
+	case int64:
+		return v != 0, nil
+	case float32:
+		return v != 0, nil
+	case float64:
+		return v != 0, nil
+	}
+	return false, msg.typeError(i, "bool")
+}
+
+// Blob returns argument `i` as a byte slice.
+func (msg *Message) Blob(i int) ([]byte, error) {
+	arg, err := msg.argument(i, "blob")
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := arg.([]byte); ok {
+		return v, nil
+	}
+	return nil, msg.typeError(i, "blob")
+}
+
+////
+// Unmarshal
+////
+
+// Unmarshal fills the struct pointed to by `v` from the arguments of `msg`.
+// Each field to fill carries an `osc` tag with the index of its argument,
+// optionally followed by ",optional" if the message may omit it:
+//
+//	type Wheel struct {
+//		Name     string  `osc:"0"`
+//		Category int     `osc:"1"`
+//		Value    float32 `osc:"2,optional"`
+//	}
+//
+// Fields without a tag, or tagged "-", are left alone. Numeric fields accept
+// any numeric argument that converts without loss, in the same way as the
+// typed accessors; string, bool and []byte fields are filled by StringArg,
+// Bool and Blob. Fields of any other type must be assignable from the
+// argument itself.
+func Unmarshal(msg *Message, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("osc: Unmarshal needs a non-nil struct pointer, got %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for f := 0; f < rt.NumField(); f++ {
+		field := rt.Field(f)
+		tag, ok := field.Tag.Lookup("osc")
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		opts := strings.Split(tag, ",")
+		i, err := strconv.Atoi(opts[0])
+		if err != nil || i < 0 {
+			return fmt.Errorf("osc: invalid tag %q on field %s", tag, field.Name)
+		}
+		optional := len(opts) > 1 && opts[1] == "optional"
+		if i >= len(msg.Arguments) {
+			if optional {
+				continue
+			}
+			return &ArgumentError{Address: msg.Address, Index: i, Want: field.Type.String(), Err: ErrMissingArgument}
+		}
+
+		if err := msg.setField(rv.Field(f), i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField stores argument `i` in the struct field `fv`.
+func (msg *Message) setField(fv reflect.Value, i int) error {
+	arg := msg.Arguments[i]
+	if arg != nil && reflect.TypeOf(arg).AssignableTo(fv.Type()) {
+		fv.Set(reflect.ValueOf(arg))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := msg.Int64(i)
+		if err != nil {
+			return err
+		}
+		if fv.OverflowInt(n) {
+			return msg.typeError(i, fv.Type().String())
+		}
+		fv.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := msg.Int64(i)
+		if err != nil {
+			return err
+		}
+		if n < 0 || fv.OverflowUint(uint64(n)) {
+			return msg.typeError(i, fv.Type().String())
+		}
+		fv.SetUint(uint64(n))
+
+	case reflect.Float32, reflect.Float64:
+		n, err := msg.Float(i)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+
+	case reflect.String:
+		s, err := msg.StringArg(i)
+		if err != nil {
+			return err
+		}
+		fv.SetString(s)
+
+	case reflect.Bool:
+		b, err := msg.Bool(i)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+
+	default:
+		return msg.typeError(i, fv.Type().String())
+	}
+	return nil
+}
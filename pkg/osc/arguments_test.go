@@ -0,0 +1,140 @@
+package osc
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestInt64(t *testing.T) {
+	for _, tt := range []struct {
+		arg  interface{}
+		want int64
+		err  error
+	}{
+		{arg: int32(-7), want: -7},
+		{arg: int64(math.MaxInt64), want: math.MaxInt64},
+		{arg: float32(42), want: 42},
+		{arg: float64(-1 << 63), want: math.MinInt64},
+		{arg: float32(1.5), err: ErrArgumentType},
+		{arg: float64(1 << 63), err: ErrArgumentType},
+		{arg: float32(1 << 63), err: ErrArgumentType},
+		{arg: float32(math.Inf(1)), err: ErrArgumentType},
+		{arg: math.Inf(-1), err: ErrArgumentType},
+		{arg: math.NaN(), err: ErrArgumentType},
+		{arg: "1", err: ErrArgumentType},
+	} {
+		got, err := NewMessage("/test", tt.arg).Int64(0)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("Int64(%T %v) = %d, %v; want %d, %v", tt.arg, tt.arg, got, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := NewMessage("/test").Int64(0); !errors.Is(err, ErrMissingArgument) {
+		t.Errorf("Int64 of a missing argument: %v; want %v", err, ErrMissingArgument)
+	}
+}
+
+func TestInt32(t *testing.T) {
+	for _, tt := range []struct {
+		arg  interface{}
+		want int32
+		err  error
+	}{
+		{arg: int32(math.MinInt32), want: math.MinInt32},
+		{arg: int64(math.MaxInt32), want: math.MaxInt32},
+		{arg: int64(math.MaxInt32 + 1), err: ErrArgumentType},
+		{arg: float32(3), want: 3},
+	} {
+		got, err := NewMessage("/test", tt.arg).Int32(0)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("Int32(%T %v) = %d, %v; want %d, %v", tt.arg, tt.arg, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+type wheelArgs struct {
+	Name     string  `osc:"0"`
+	Category uint8   `osc:"1"`
+	Value    float32 `osc:"2,optional"`
+	Skipped  int     `osc:"-"`
+	Untagged int
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		msg  *Message
+		want wheelArgs
+		err  error
+	}{
+		{
+			name: "all arguments",
+			msg:  NewMessage("/eos/out/active/wheel/1", "Pan  [127]", int32(1), float32(127)),
+			want: wheelArgs{Name: "Pan  [127]", Category: 1, Value: 127},
+		},
+		{
+			name: "optional argument omitted",
+			msg:  NewMessage("/eos/out/active/wheel/1", Symbol("Pan"), float32(1)),
+			want: wheelArgs{Name: "Pan", Category: 1},
+		},
+		{
+			name: "required argument missing",
+			msg:  NewMessage("/eos/out/active/wheel/1", "Pan"),
+			err:  ErrMissingArgument,
+		},
+		{
+			name: "negative into unsigned",
+			msg:  NewMessage("/eos/out/active/wheel/1", "Pan", int32(-1)),
+			err:  ErrArgumentType,
+		},
+		{
+			name: "overflow",
+			msg:  NewMessage("/eos/out/active/wheel/1", "Pan", int32(256)),
+			err:  ErrArgumentType,
+		},
+		{
+			name: "fraction into integer",
+			msg:  NewMessage("/eos/out/active/wheel/1", "Pan", float32(1.5)),
+			err:  ErrArgumentType,
+		},
+		{
+			name: "wrong type",
+			msg:  NewMessage("/eos/out/active/wheel/1", int32(1), int32(1)),
+			err:  ErrArgumentType,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wheelArgs{Skipped: 5, Untagged: 6}
+			err := Unmarshal(tt.msg, &got)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Unmarshal: %v; want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			tt.want.Skipped, tt.want.Untagged = 5, 6
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	msg := NewMessage("/test", int32(1))
+	var s wheelArgs
+	for _, v := range []interface{}{nil, s, &msg, (*wheelArgs)(nil)} {
+		if err := Unmarshal(msg, v); err == nil {
+			t.Errorf("Unmarshal into %T: no error", v)
+		}
+	}
+
+	var bad struct {
+		Value int `osc:"x"`
+	}
+	if err := Unmarshal(msg, &bad); err == nil {
+		t.Error("Unmarshal with an invalid tag: no error")
+	}
+}
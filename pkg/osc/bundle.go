@@ -3,8 +3,6 @@
 package osc
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"time"
@@ -37,6 +35,12 @@ func NewBundle(time time.Time) *Bundle {
 	return &Bundle{Timetag: *NewTimetag(time)}
 }
 
+// NewBundleAfter returns an OSC Bundle due `d` after the current time of
+// `clock`.
+func NewBundleAfter(clock Clock, d time.Duration) *Bundle {
+	return NewBundle(clock.Now().Add(d))
+}
+
 // Append appends an OSC bundle or OSC message to the bundle.
 func (b *Bundle) Append(pck Packet) error {
 	switch t := pck.(type) {
@@ -62,76 +66,55 @@ func (b *Bundle) Append(pck Packet) error {
 // 5. Length of n OSC bundle element
 // 6. n bundle element
 func (b *Bundle) MarshalBinary() ([]byte, error) {
-	// Add the '#bundle' string
-	data := new(bytes.Buffer)
-	if _, err := writePaddedString("#bundle", data); err != nil {
-		return nil, err
-	}
+	return b.AppendBinary(nil)
+}
 
-	// Add the time tag
-	bd, err := b.Timetag.MarshalBinary()
-	if err != nil {
-		return nil, err
-	}
-	if _, err = data.Write(bd); err != nil {
-		return nil, err
-	}
+// AppendBinary appends the serialized OSC bundle to `dst` and returns the
+// extended buffer. It does not allocate if `dst` has enough capacity.
+func (b *Bundle) AppendBinary(dst []byte) ([]byte, error) {
+	// Add the '#bundle' string and the time tag
+	dst = appendPaddedString(dst, bundleTagString)
+	dst = binary.BigEndian.AppendUint64(dst, b.Timetag.TimeTag())
 
-	// Process all OSC Messages
+	// Process all OSC Messages, then all OSC Bundles. Each element is preceded
+	// by its size, which is filled in once the element has been appended.
+	var err error
 	for _, m := range b.Messages {
-		buf, err := m.MarshalBinary()
-		if err != nil {
-			return nil, err
-		}
-
-		// Append the length of the OSC message
-		if err = binary.Write(data, binary.BigEndian, int32(len(buf))); err != nil {
-			return nil, err
-		}
-
-		// Append the OSC message
-		if _, err = data.Write(buf); err != nil {
+		start := len(dst)
+		dst = append(dst, 0, 0, 0, 0)
+		if dst, err = m.AppendBinary(dst); err != nil {
 			return nil, err
 		}
+		binary.BigEndian.PutUint32(dst[start:], uint32(len(dst)-start-4))
 	}
-
-	// Process all OSC Bundles
-	for _, b := range b.Bundles {
-		buf, err := b.MarshalBinary()
-		if err != nil {
-			return nil, err
-		}
-
-		// Write the size of the bundle
-		if err = binary.Write(data, binary.BigEndian, int32(len(buf))); err != nil {
-			return nil, err
-		}
-
-		// Append the bundle
-		_, err = data.Write(buf)
-		if err != nil {
+	for _, e := range b.Bundles {
+		start := len(dst)
+		dst = append(dst, 0, 0, 0, 0)
+		if dst, err = e.AppendBinary(dst); err != nil {
 			return nil, err
 		}
+		binary.BigEndian.PutUint32(dst[start:], uint32(len(dst)-start-4))
 	}
 
-	return data.Bytes(), nil
+	return dst, nil
 }
 
-// readBundle reads an Bundle from reader.
-func readBundle(reader *bufio.Reader) (*Bundle, error) {
+// readBundle reads a Bundle from the decoder.
+func readBundle(d *decoder) (*Bundle, error) {
 	// Read the '#bundle' OSC string
-	startTag, _, err := readPaddedString(reader)
+	start := d.pos
+	startTag, err := d.readPaddedBytes()
 	if err != nil {
 		return nil, err
 	}
 
-	if startTag != bundleTagString {
-		return nil, fmt.Errorf("invalid bundle start tag: %s", startTag)
+	if string(startTag) != bundleTagString {
+		return nil, d.fail(start, ErrUnknownPacket, "invalid bundle start tag %q", startTag)
 	}
 
 	// Read the timetag
-	var timeTag uint64
-	if err := binary.Read(reader, binary.BigEndian, &timeTag); err != nil {
+	timeTag, err := d.readUint64()
+	if err != nil {
 		return nil, err
 	}
 
@@ -139,14 +122,18 @@ func readBundle(reader *bufio.Reader) (*Bundle, error) {
 	bundle := NewBundle(timetagToTime(timeTag))
 
 	// Read until the end of the buffer
-	for reader.Buffered() > 0 {
+	for d.remaining() > 0 {
 		// Read the size of the bundle element
-		var length int32
-		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		start := d.pos
+		length, err := d.readInt32()
+		if err != nil {
 			return nil, err
 		}
+		if length < 0 || int(length) > d.remaining() {
+			return nil, d.fail(start, ErrInvalidLength, "bundle element length %d exceeds bundle", length)
+		}
 
-		p, err := readPacket(reader)
+		p, err := readPacket(d.element(int(length)))
 		if err != nil {
 			return nil, err
 		}
@@ -5,14 +5,18 @@ package osc
 import (
 	"fmt"
 	"net"
+	"sync"
 )
 
 // Client enables you to send OSC packets. It sends OSC messages and bundles to
-// the given IP address and port.
+// the given IP address and port. The socket is dialed on the first Send and
+// kept open until Close or until the addresses change.
 type Client struct {
+	mu    sync.Mutex
 	ip    string
 	port  int
 	laddr *net.UDPAddr
+	conn  *net.UDPConn
 }
 
 // NewClient creates a new OSC client. The Client is used to send OSC
@@ -24,16 +28,34 @@ func NewClient(ip string, port int) *Client {
 }
 
 // IP returns the IP address.
-func (c *Client) IP() string { return c.ip }
+func (c *Client) IP() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ip
+}
 
 // SetIP sets a new IP address.
-func (c *Client) SetIP(ip string) { c.ip = ip }
+func (c *Client) SetIP(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ip = ip
+	c.closeConn()
+}
 
 // Port returns the port.
-func (c *Client) Port() int { return c.port }
+func (c *Client) Port() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.port
+}
 
 // SetPort sets a new port.
-func (c *Client) SetPort(port int) { c.port = port }
+func (c *Client) SetPort(port int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.port = port
+	c.closeConn()
+}
 
 // SetLocalAddr sets the local address.
 func (c *Client) SetLocalAddr(ip string, port int) error {
@@ -41,28 +63,53 @@ func (c *Client) SetLocalAddr(ip string, port int) error {
 	if err != nil {
 		return err
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.laddr = laddr
+	c.closeConn()
 	return nil
 }
 
+// Close closes the socket of the client. The next Send dials a new one.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeConn()
+}
+
+// closeConn closes the dialed socket, if any. The caller holds c.mu.
+func (c *Client) closeConn() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
 // Send sends an OSC Bundle or an OSC Message.
 func (c *Client) Send(packet Packet) error {
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", c.ip, c.port))
-	if err != nil {
-		return err
-	}
-	conn, err := net.DialUDP("udp", c.laddr, addr)
-	if err != nil {
-		return err
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", c.ip, c.port))
+		if err != nil {
+			return err
+		}
+		if c.conn, err = net.DialUDP("udp", c.laddr, addr); err != nil {
+			return err
+		}
 	}
-	defer conn.Close()
 
-	data, err := packet.MarshalBinary()
+	buf := getPacketBuffer()
+	defer putPacketBuffer(buf)
+	data, err := packet.AppendBinary((*buf)[:0])
 	if err != nil {
 		return err
 	}
 
-	if _, err = conn.Write(data); err != nil {
+	if _, err = c.conn.Write(data); err != nil {
 		return err
 	}
 	return nil
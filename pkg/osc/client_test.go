@@ -0,0 +1,77 @@
+package osc
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClientSocket(t *testing.T) {
+	a, b := listenUDP(t), listenUDP(t)
+	c := NewClient("127.0.0.1", a.LocalAddr().(*net.UDPAddr).Port)
+	defer c.Close()
+
+	// The socket is dialed once
+	var from []string
+	for range 2 {
+		if err := c.Send(NewMessage("/eos/ping")); err != nil {
+			t.Fatal(err)
+		}
+		_, addr := receiveUDP(t, a)
+		from = append(from, addr.String())
+	}
+	if from[0] != from[1] {
+		t.Errorf("sent from %q; want one socket", from)
+	}
+	conn := c.conn
+
+	// Changing the addresses dials again
+	c.SetPort(b.LocalAddr().(*net.UDPAddr).Port)
+	if c.conn != nil {
+		t.Error("SetPort kept the socket")
+	}
+	if err := c.Send(NewMessage("/eos/ping")); err != nil {
+		t.Fatal(err)
+	}
+	receiveUDP(t, b)
+	receivedNothing(t, a)
+	if c.conn == conn {
+		t.Error("Send after SetPort reused the socket")
+	}
+
+	conn = c.conn
+	c.SetIP("127.0.0.1")
+	if err := c.Send(NewMessage("/eos/ping")); err != nil {
+		t.Fatal(err)
+	}
+	receiveUDP(t, b)
+	if c.conn == conn {
+		t.Error("Send after SetIP reused the socket")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(NewMessage("/eos/ping")); err != nil {
+		t.Fatal(err)
+	}
+	receiveUDP(t, b)
+}
+
+func TestClientLocalAddr(t *testing.T) {
+	a := listenUDP(t)
+	c := NewClient("127.0.0.1", a.LocalAddr().(*net.UDPAddr).Port)
+	defer c.Close()
+
+	local := listenUDP(t)
+	port := local.LocalAddr().(*net.UDPAddr).Port
+	local.Close()
+	if err := c.SetLocalAddr("127.0.0.1", port); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(NewMessage("/eos/ping")); err != nil {
+		t.Fatal(err)
+	}
+	if _, addr := receiveUDP(t, a); addr.(*net.UDPAddr).Port != port {
+		t.Errorf("sent from %v; want port %d", addr, port)
+	}
+}
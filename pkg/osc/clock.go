@@ -0,0 +1,159 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for time tags and bundle scheduling. Replace
+// the system clock with a FakeClock to control time in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// AfterFunc calls `f` once `d` has elapsed.
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// ClockTimer is a timer created by Clock.AfterFunc. Stop and Reset behave like
+// their time.Timer counterparts.
+type ClockTimer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+////
+// System clock
+////
+
+// systemClock is the Clock backed by the time package.
+type systemClock struct{}
+
+// SystemClock returns the Clock backed by the time package.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
+
+////
+// FakeClock
+////
+
+// FakeClock is a Clock that only moves when told to. Timers fire during
+// Advance and Set, on the goroutine calling them, in the order they are due.
+// A timer that is due already when it is created or reset fires on the next
+// call to Advance, even Advance(0).
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers map[*fakeTimer]struct{}
+}
+
+// Verify that FakeClock implements the Clock interface.
+var _ Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock set to `now`.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, timers: map[*fakeTimer]struct{}{}}
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc calls `f` once the fake time has advanced by `d`.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	t := &fakeTimer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the fake time forward by `d` and fires all timers that become
+// due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the fake time to `now` and fires all timers that become due. Each
+// timer sees the fake time set to its due time while it runs.
+func (c *FakeClock) Set(now time.Time) {
+	for {
+		c.mu.Lock()
+		var due []*fakeTimer
+		for t := range c.timers {
+			if !t.at.After(now) {
+				due = append(due, t)
+			}
+		}
+		if len(due) == 0 {
+			if now.After(c.now) {
+				c.now = now
+			}
+			c.mu.Unlock()
+			return
+		}
+
+		sort.Slice(due, func(i, j int) bool {
+			if due[i].at.Equal(due[j].at) {
+				return due[i].seq < due[j].seq
+			}
+			return due[i].at.Before(due[j].at)
+		})
+		next := due[0]
+		delete(c.timers, next)
+		if next.at.After(c.now) {
+			c.now = next.at
+		}
+		c.mu.Unlock()
+
+		next.f()
+	}
+}
+
+// PendingTimers returns the number of timers that have not fired yet.
+func (c *FakeClock) PendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// fakeTimer is a ClockTimer of a FakeClock.
+type fakeTimer struct {
+	clock *FakeClock
+	f     func()
+	at    time.Time
+	seq   uint64
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, active := c.timers[t]
+	c.seq++
+	t.at = c.now.Add(d)
+	t.seq = c.seq
+	c.timers[t] = struct{}{}
+	return active
+}
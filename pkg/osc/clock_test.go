@@ -0,0 +1,117 @@
+package osc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFakeClockTimers(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	var fired []string
+	var at []time.Duration
+	timer := func(name string, d time.Duration) ClockTimer {
+		return clock.AfterFunc(d, func() {
+			fired = append(fired, name)
+			at = append(at, clock.Now().Sub(start))
+		})
+	}
+	timer("c", 3*time.Second)
+	timer("a", time.Second)
+	timer("b", time.Second)
+	stopped := timer("stopped", 2*time.Second)
+	reset := timer("reset", time.Second)
+	if got := clock.PendingTimers(); got != 5 {
+		t.Errorf("PendingTimers = %d; want 5", got)
+	}
+
+	if !stopped.Stop() {
+		t.Error("Stop of a pending timer = false")
+	}
+	if stopped.Stop() {
+		t.Error("Stop twice = true")
+	}
+	if !reset.Reset(4 * time.Second) {
+		t.Error("Reset of a pending timer = false")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if fired != nil {
+		t.Errorf("fired %q before they were due", fired)
+	}
+
+	// Timers fire in the order they are due, then created, at their due time
+	clock.Advance(5 * time.Second)
+	if want := []string{"a", "b", "c", "reset"}; !reflect.DeepEqual(fired, want) {
+		t.Errorf("fired %q; want %q", fired, want)
+	}
+	if want := []time.Duration{time.Second, time.Second, 3 * time.Second, 4 * time.Second}; !reflect.DeepEqual(at, want) {
+		t.Errorf("fired at %v; want %v", at, want)
+	}
+	if got, want := clock.Now(), start.Add(5500*time.Millisecond); !got.Equal(want) {
+		t.Errorf("Now = %v; want %v", got, want)
+	}
+	if got := clock.PendingTimers(); got != 0 {
+		t.Errorf("PendingTimers = %d; want 0", got)
+	}
+
+	// A fired timer can be reset, and one due already fires on Advance(0)
+	fired = nil
+	if reset.Reset(0) {
+		t.Error("Reset of a fired timer = true")
+	}
+	clock.Advance(0)
+	if want := []string{"reset"}; !reflect.DeepEqual(fired, want) {
+		t.Errorf("fired %q; want %q", fired, want)
+	}
+}
+
+func TestFakeClockTimerFromTimer(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	ticks := 0
+	var tick func()
+	tick = func() {
+		ticks++
+		clock.AfterFunc(time.Second, tick)
+	}
+	clock.AfterFunc(time.Second, tick)
+
+	clock.Advance(3500 * time.Millisecond)
+	if ticks != 3 {
+		t.Errorf("ticked %d times; want 3", ticks)
+	}
+}
+
+func TestFakeClockSetBackwards(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	clock.Set(start.Add(-time.Hour))
+	if got := clock.Now(); !got.Equal(start) {
+		t.Errorf("Now = %v; want %v", got, start)
+	}
+}
+
+func TestClockTimetag(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	tag := NewTimetagNow(clock)
+	if got := tag.Time(); !got.Equal(start) {
+		t.Errorf("NewTimetagNow = %v; want %v", got, start)
+	}
+
+	b := NewBundleAfter(clock, 2*time.Second)
+	if got := b.Timetag.ExpiresInClock(clock); got != 2*time.Second {
+		t.Errorf("ExpiresInClock = %v; want 2s", got)
+	}
+	clock.Advance(1500 * time.Millisecond)
+	if got := b.Timetag.ExpiresInClock(clock); got != 500*time.Millisecond {
+		t.Errorf("ExpiresInClock after 1.5s = %v; want 500ms", got)
+	}
+	clock.Advance(time.Second)
+	if got := b.Timetag.ExpiresInClock(clock); got != 0 {
+		t.Errorf("ExpiresInClock of a past time tag = %v; want 0", got)
+	}
+}
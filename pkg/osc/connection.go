@@ -3,11 +3,11 @@
 package osc
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,9 +17,21 @@ type Connection struct {
 	conn        *net.UDPConn
 	Dispatcher  Dispatcher
 	ReadTimeout time.Duration
-	errorCh     chan error
-	readCh      chan readData
-	doneCh      chan bool
+	// ErrorHandler, if set, is called for every received packet that cannot
+	// be decoded. Such packets are dropped and the connection keeps serving.
+	ErrorHandler ErrorHandlerFunc
+	// DispatchOptions controls how received packets are handed to the
+	// Dispatcher. It is read when Serve starts.
+	DispatchOptions DispatchOptions
+	// DecodeOptions controls how received packets are decoded.
+	DecodeOptions DecodeOptions
+	// Recorder, if set, records every packet sent and received.
+	Recorder     *Recorder
+	state        serveState
+	addresses    addressCache
+	multicast    *MulticastConfig
+	destMu       sync.RWMutex
+	destinations map[string]*net.UDPAddr
 }
 
 // NewConnection creates a new OSC client/server. The Connection is used to send and receive OSC
@@ -37,8 +49,6 @@ func NewConnection(lport int, raddr string) (*Connection, error) {
 			return nil, err
 		}
 	}
-	conn.errorCh = make(chan error)
-	conn.readCh = make(chan readData, 20)
 	return conn, nil
 }
 
@@ -79,32 +89,25 @@ func (c *Connection) SetLocalAddress(addr string) error {
 
 func (c *Connection) SetRemoteAddress(addr string) error {
 	if addr == "" {
+		c.destMu.Lock()
 		c.raddr = nil
+		c.destMu.Unlock()
 		return nil
 	}
 	newAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
 		return err
 	}
+	c.destMu.Lock()
 	c.raddr = newAddr
+	c.destMu.Unlock()
 	return nil
 }
 
-// Send sends an OSC Bundle or an OSC Message.
+// Send sends an OSC Bundle or an OSC Message to the default remote address and
+// to all destinations added with AddDestination.
 func (c *Connection) Send(packet Packet) error {
-	if c.conn == nil {
-		if err := c.Open(); err != nil {
-			return err
-		}
-	}
-
-	data, err := packet.MarshalBinary()
-	if err != nil {
-		return err
-	}
-
-	_, err = c.conn.WriteToUDP(data, c.raddr)
-	return err
+	return c.send(packet, c.fanOut())
 }
 
 func (c *Connection) Open() error {
@@ -115,17 +118,41 @@ func (c *Connection) Open() error {
 		c.Dispatcher = NewStandardDispatcher()
 	}
 	var err error
-	c.conn, err = net.ListenUDP("udp", c.laddr)
+	if c.multicast != nil {
+		c.conn, err = c.listenMulticast()
+	} else {
+		c.conn, err = net.ListenUDP("udp", c.laddr)
+	}
 	if err != nil {
+		c.conn = nil
 		return err
 	}
 	return nil
 }
 
+// Close closes the connection immediately. A running Serve returns
+// ErrConnectionClosed, dispatches in progress are not waited for, and bundles
+// queued by the scheduler of the Dispatcher are dropped.
 func (c *Connection) Close() error {
-	if c.doneCh != nil {
-		close(c.doneCh)
-	}
+	c.state.stop()
+	return c.closeConn()
+}
+
+// Shutdown gracefully stops the connection. It stops reading packets, waits
+// for Serve to return and for all dispatches in progress to finish, and then
+// closes the connection. If `ctx` expires first, the connection is closed
+// anyway and the context's error is returned. Bundles queued by the scheduler
+// of the Dispatcher are dropped.
+func (c *Connection) Shutdown(ctx context.Context) error {
+	return c.state.shutdown(ctx, c.closeConn)
+}
+
+// DispatchStats reports how the packets received so far were dispatched.
+func (c *Connection) DispatchStats() DispatchStats {
+	return c.state.counters.stats()
+}
+
+func (c *Connection) closeConn() error {
 	if c.conn == nil {
 		return fmt.Errorf("connection not open")
 	}
@@ -139,80 +166,97 @@ func (c *Connection) Close() error {
 }
 
 // Serve retrieves incoming OSC packets from the given connection and dispatches
-// retrieved OSC packets. If something goes wrong an error is returned.
-func (c *Connection) Serve() error {
-	defer fmt.Println("exiting Serve())")
-	c.doneCh = make(chan bool)
-	go c.readFromConnection()
+// retrieved OSC packets until `ctx` is cancelled or the connection is closed.
+// Packets that cannot be decoded are reported to ErrorHandler and skipped.
+// Serve returns the context's error when cancelled, and ErrConnectionClosed
+// after Close or Shutdown.
+func (c *Connection) Serve(ctx context.Context) error {
+	if c.conn == nil {
+		return fmt.Errorf("connection not open")
+	}
+	ctx, end, err := c.state.begin(ctx, c.Dispatcher, c.DispatchOptions)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	// Unblock a pending read when the context is cancelled
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
 	var tempDelay time.Duration
 	for {
-		//	case msg, addr, err := c.readFromConnection():
-		select {
-		case d := <-c.readCh:
-			go c.Dispatcher.Dispatch(d.msg, d.addr)
-		case err := <-c.errorCh:
-			if err != nil {
-				// This was looking at ne.Temporary() which is deprecated
-				if _, ok := err.(net.Error); ok {
-					if tempDelay == 0 {
-						tempDelay = 5 * time.Millisecond
-					} else {
-						tempDelay *= 2
-					}
-					if max := 1 * time.Second; tempDelay > max {
-						tempDelay = max
-					}
-					time.Sleep(tempDelay)
-					continue
-				}
-				return err
-			}
+		p, addr, err := c.readFromConnection(ctx)
+		if err == nil {
 			tempDelay = 0
-			return err
-		case _, ok := <-c.doneCh:
-			if !ok {
-				return nil
+			if p != nil {
+				c.state.dispatch(p, addr)
 			}
+			continue
 		}
-	}
-}
 
-type readData struct {
-	msg  Packet
-	addr net.Addr
-}
+		switch {
+		case c.state.isClosed():
+			return ErrConnectionClosed
+		case ctx.Err() != nil:
+			return ctx.Err()
+		}
 
-// readFromConnection retrieves OSC packets.
-func (c *Connection) readFromConnection() {
-	defer fmt.Println("exiting needFromConnection()")
-	for {
-		if c.ReadTimeout != 0 {
-			if err := c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout)); err != nil {
-				c.errorCh <- err
-				return
+		// This was looking at ne.Temporary() which is deprecated
+		if ne, ok := err.(net.Error); ok {
+			if ne.Timeout() {
+				continue
+			}
+			if tempDelay == 0 {
+				tempDelay = 5 * time.Millisecond
+			} else {
+				tempDelay *= 2
 			}
+			if max := 1 * time.Second; tempDelay > max {
+				tempDelay = max
+			}
+			time.Sleep(tempDelay)
+			continue
 		}
+		return err
+	}
+}
 
-		data := make([]byte, 65535)
-		n, addr, err := c.conn.ReadFrom(data)
-		if err != nil {
-			c.errorCh <- err
-			return
+// readFromConnection retrieves a single OSC packet. A packet that cannot be
+// decoded is reported to the ErrorHandler, and nil is returned without an
+// error.
+func (c *Connection) readFromConnection(ctx context.Context) (Packet, net.Addr, error) {
+	if c.ReadTimeout != 0 {
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout)); err != nil {
+			return nil, nil, err
 		}
-
-		p, err := readPacket(bufio.NewReader(bytes.NewBuffer(data[0:n])))
-		if err != nil {
-			c.errorCh <- err
-			return
+		// The deadline may have overwritten the one set on cancellation
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
 		}
+	}
 
-		c.readCh <- readData{msg: p, addr: addr}
-		select {
-		case _, ok := <-c.doneCh:
-			if !ok {
-				return
-			}
-		default:
+	buf := getPacketBuffer()
+	defer putPacketBuffer(buf)
+	n, addr, err := c.conn.ReadFrom(*buf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.Recorder.Record(DirectionIn, addr, (*buf)[:n])
+
+	// The packet does not refer to the buffer, so it can go back to the pool
+	if c.addresses == nil {
+		c.addresses = addressCache{}
+	}
+	p, err := readPacket(&decoder{data: (*buf)[:n], addresses: c.addresses, opts: c.DecodeOptions})
+	if err != nil {
+		if c.ErrorHandler != nil {
+			c.ErrorHandler(err, addr)
 		}
+		return nil, addr, nil
 	}
+	return p, addr, nil
 }
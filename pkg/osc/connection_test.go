@@ -0,0 +1,165 @@
+package osc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// newServingConnection returns a Connection on the loopback interface,
+// serving `ctx` to a blockingDispatcher. Serve's result is sent on the
+// returned channel.
+func newServingConnection(t *testing.T, ctx context.Context) (*Connection, *blockingDispatcher, <-chan error) {
+	t.Helper()
+	c, err := NewConnection(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetLocalAddress("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	d := newBlockingDispatcher(&c.state.counters)
+	c.Dispatcher = d
+	c.DispatchOptions = DispatchOptions{Mode: DispatchSerial}
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	served := make(chan error, 1)
+	go func() { served <- c.Serve(ctx) }()
+	return c, d, served
+}
+
+// served returns the result of Serve.
+func served(t *testing.T, ch <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return")
+		return nil
+	}
+}
+
+func TestConnectionShutdown(t *testing.T) {
+	c, d, ch := newServingConnection(t, context.Background())
+	sendUDP(t, c.conn.LocalAddr(), mustMarshal(t, NewMessage("/1")))
+	d.wait(t, "/1")
+	sendUDP(t, c.conn.LocalAddr(), mustMarshal(t, NewMessage("/2")))
+	for deadline := time.Now().Add(time.Second); c.DispatchStats().Queued == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("/2 not queued")
+		}
+	}
+
+	// Shutdown waits for the dispatch in progress and the queued one
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- c.Shutdown(context.Background()) }()
+	if err := served(t, ch); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("Serve = %v; want ErrConnectionClosed", err)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown = %v during a dispatch", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(d.release)
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("Shutdown = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return")
+	}
+	if want := (DispatchStats{Dispatched: 2}); c.DispatchStats() != want {
+		t.Errorf("stats %+v; want %+v", c.DispatchStats(), want)
+	}
+
+	if err := c.Serve(context.Background()); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("Serve after Shutdown = %v; want ErrConnectionClosed", err)
+	}
+}
+
+func TestConnectionShutdownTimeout(t *testing.T) {
+	c, d, ch := newServingConnection(t, context.Background())
+	defer close(d.release)
+	sendUDP(t, c.conn.LocalAddr(), mustMarshal(t, NewMessage("/1")))
+	d.wait(t, "/1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v; want context.DeadlineExceeded", err)
+	}
+	served(t, ch)
+}
+
+func TestConnectionServeCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, d, ch := newServingConnection(t, ctx)
+	sendUDP(t, c.conn.LocalAddr(), mustMarshal(t, NewMessage("/1")))
+	d.wait(t, "/1")
+
+	cancel()
+	if err := served(t, ch); !errors.Is(err, context.Canceled) {
+		t.Errorf("Serve = %v; want context.Canceled", err)
+	}
+
+	// The dispatch started by the cancelled loop is still waited for
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- c.Shutdown(context.Background()) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown = %v during a dispatch", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(d.release)
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+}
+
+func TestConnectionErrorHandler(t *testing.T) {
+	errs := make(chan net.Addr, 1)
+	c, err := NewConnection(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetLocalAddress("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	d := newBlockingDispatcher(&c.state.counters)
+	close(d.release)
+	c.Dispatcher = d
+	c.ErrorHandler = func(err error, addr net.Addr) {
+		errs <- addr
+	}
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	go c.Serve(context.Background())
+
+	from := sendUDP(t, c.conn.LocalAddr(), []byte("not OSC"))
+	select {
+	case addr := <-errs:
+		if addr.String() != from.String() {
+			t.Errorf("error from %v; want %v", addr, from)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ErrorHandler not called")
+	}
+
+	// Serving goes on
+	sendUDP(t, c.conn.LocalAddr(), mustMarshal(t, NewMessage("/eos/ping")))
+	d.wait(t, "/eos/ping")
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,128 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// AddDestination adds a named remote address that Send delivers every packet
+// to, in addition to the default remote address. Adding a name again replaces
+// its address.
+func (c *Connection) AddDestination(name, addr string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	c.destMu.Lock()
+	defer c.destMu.Unlock()
+	if c.destinations == nil {
+		c.destinations = map[string]*net.UDPAddr{}
+	}
+	c.destinations[name] = udpAddr
+	return nil
+}
+
+// RemoveDestination removes a named destination. It reports whether the
+// destination existed.
+func (c *Connection) RemoveDestination(name string) bool {
+	c.destMu.Lock()
+	defer c.destMu.Unlock()
+	_, ok := c.destinations[name]
+	delete(c.destinations, name)
+	return ok
+}
+
+// Destinations returns the names of the destinations, sorted.
+func (c *Connection) Destinations() []string {
+	c.destMu.RLock()
+	defer c.destMu.RUnlock()
+	names := make([]string, 0, len(c.destinations))
+	for name := range c.destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SendTo sends an OSC Bundle or an OSC Message to `addr` only. Pass the
+// address a request was received from to reply to its sender.
+func (c *Connection) SendTo(packet Packet, addr net.Addr) error {
+	udpAddr, err := toUDPAddr(addr)
+	if err != nil {
+		return err
+	}
+	return c.send(packet, []*net.UDPAddr{udpAddr})
+}
+
+// SendToDestination sends an OSC Bundle or an OSC Message to the named
+// destination only.
+func (c *Connection) SendToDestination(packet Packet, name string) error {
+	c.destMu.RLock()
+	addr, ok := c.destinations[name]
+	c.destMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown destination: %v", name)
+	}
+	return c.send(packet, []*net.UDPAddr{addr})
+}
+
+// fanOut returns the default remote address, if set, followed by all named
+// destinations.
+func (c *Connection) fanOut() []*net.UDPAddr {
+	c.destMu.RLock()
+	defer c.destMu.RUnlock()
+	addrs := make([]*net.UDPAddr, 0, len(c.destinations)+1)
+	if c.raddr != nil {
+		addrs = append(addrs, c.raddr)
+	}
+	for _, addr := range c.destinations {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// send encodes the packet once and writes it to every address. An address
+// that fails does not keep the packet from the others; all errors are
+// returned joined.
+func (c *Connection) send(packet Packet, addrs []*net.UDPAddr) error {
+	if c.conn == nil {
+		if err := c.Open(); err != nil {
+			return err
+		}
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no remote address")
+	}
+
+	buf := getPacketBuffer()
+	defer putPacketBuffer(buf)
+	data, err := packet.AppendBinary((*buf)[:0])
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		if _, err := c.conn.WriteToUDP(data, addr); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.Recorder.Record(DirectionOut, addr, data)
+	}
+	return errors.Join(errs...)
+}
+
+// toUDPAddr converts `addr` to a UDP address, resolving it if needed.
+func toUDPAddr(addr net.Addr) (*net.UDPAddr, error) {
+	if addr == nil {
+		return nil, fmt.Errorf("no remote address")
+	}
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		return udpAddr, nil
+	}
+	return net.ResolveUDPAddr("udp", addr.String())
+}
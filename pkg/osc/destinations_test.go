@@ -0,0 +1,175 @@
+package osc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// listenUDP returns a UDP socket on the loopback interface.
+func listenUDP(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// receiveUDP returns the next message received on `conn`, and where it came
+// from.
+func receiveUDP(t *testing.T, conn *net.UDPConn) (*Message, net.Addr) {
+	t.Helper()
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, addr, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePacketBytes(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*Message), addr
+}
+
+// receivedNothing tests that nothing arrives on `conn` for a while.
+func receivedNothing(t *testing.T, conn *net.UDPConn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if n, _, err := conn.ReadFrom(make([]byte, 65536)); err == nil {
+		t.Errorf("received %d bytes", n)
+	}
+}
+
+// newTestConnection returns an open Connection on the loopback interface
+// sending to `raddr` by default.
+func newTestConnection(t *testing.T, raddr string) *Connection {
+	t.Helper()
+	c, err := NewConnection(0, raddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetLocalAddress("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestConnectionFanOut(t *testing.T) {
+	def, a, b := listenUDP(t), listenUDP(t), listenUDP(t)
+	c := newTestConnection(t, def.LocalAddr().String())
+	if err := c.AddDestination("b", b.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddDestination("a", a.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Destinations(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Destinations = %q; want %q", got, want)
+	}
+
+	// Send goes to the default address and every destination, from one socket
+	if err := c.Send(NewMessage("/eos/ping")); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range []*net.UDPConn{def, a, b} {
+		msg, from := receiveUDP(t, conn)
+		if msg.Address != "/eos/ping" || from.String() != c.conn.LocalAddr().String() {
+			t.Errorf("%v received %v from %v", conn.LocalAddr(), msg, from)
+		}
+	}
+
+	if !c.RemoveDestination("a") {
+		t.Error("RemoveDestination = false")
+	}
+	if c.RemoveDestination("a") {
+		t.Error("RemoveDestination twice = true")
+	}
+	if err := c.Send(NewMessage("/eos/ping")); err != nil {
+		t.Fatal(err)
+	}
+	receiveUDP(t, def)
+	receiveUDP(t, b)
+	receivedNothing(t, a)
+
+	// A named destination alone
+	if err := c.SendToDestination(NewMessage("/eos/ping"), "b"); err != nil {
+		t.Fatal(err)
+	}
+	receiveUDP(t, b)
+	receivedNothing(t, def)
+	if err := c.SendToDestination(NewMessage("/eos/ping"), "a"); err == nil {
+		t.Error("SendToDestination of a removed destination: no error")
+	}
+
+	// Without any address, there is nowhere to send
+	c.RemoveDestination("b")
+	c.SetRemoteAddress("")
+	if err := c.Send(NewMessage("/eos/ping")); err == nil {
+		t.Error("Send without an address: no error")
+	}
+}
+
+func TestConnectionSendTo(t *testing.T) {
+	def, peer := listenUDP(t), listenUDP(t)
+	c := newTestConnection(t, def.LocalAddr().String())
+
+	// Replies go to the sender of the request only
+	d := NewStandardDispatcher()
+	d.AddMsgHandler("/ping", func(msg *Message, addr net.Addr) {
+		if err := c.SendTo(NewMessage("/pong"), addr); err != nil {
+			t.Error(err)
+		}
+	})
+	c.Dispatcher = d
+	go c.Serve(context.Background())
+
+	if _, err := peer.WriteTo(mustMarshal(t, NewMessage("/ping")), c.conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ := receiveUDP(t, peer); msg.Address != "/pong" {
+		t.Errorf("replied %v", msg)
+	}
+	receivedNothing(t, def)
+
+	if err := c.SendTo(NewMessage("/pong"), nil); err == nil {
+		t.Error("SendTo without an address: no error")
+	}
+}
+
+func TestConnectionSendErrors(t *testing.T) {
+	def := listenUDP(t)
+	c := newTestConnection(t, def.LocalAddr().String())
+	var rec bytes.Buffer
+	c.Recorder = NewRecorder(&rec, RecordBinary)
+
+	// Sending to port 0 fails
+	c.AddDestination("a", "127.0.0.1:0")
+	c.AddDestination("b", "127.0.0.1:0")
+	err := c.Send(NewMessage("/eos/ping"))
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 {
+		t.Fatalf("Send = %v; want both destinations failing", err)
+	}
+
+	// The other addresses still get the packet, and only those are recorded
+	receiveUDP(t, def)
+	rr := NewRecordReader(&rec)
+	if r, err := rr.Next(); err != nil || r.Addr != def.LocalAddr().String() {
+		t.Errorf("recorded %v, %v; want the default address", r, err)
+	}
+	if _, err := rr.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("recorded more packets: %v", err)
+	}
+}
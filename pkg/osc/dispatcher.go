@@ -6,7 +6,7 @@ import (
 	"errors"
 	"net"
 	"regexp"
-	"time"
+	"sort"
 )
 
 // Dispatcher is an interface for an OSC message dispatcher. A dispatcher is
@@ -35,39 +35,105 @@ func (f HandlerFunc) HandleMessage(msg *Message, addr net.Addr) {
 // StandardDispatcher
 ////
 
+// MatchMode selects how a StandardDispatcher compares the address of a handler
+// with the address of an incoming message.
+type MatchMode int
+
+const (
+	// MatchRegexp treats handler addresses as Go regular expressions that are
+	// searched for in the message address.
+	MatchRegexp MatchMode = iota
+	// MatchOSCPattern implements OSC address pattern matching. Handler
+	// addresses may contain OSC wildcards themselves, and incoming address
+	// patterns are matched against literal handler addresses. Addresses
+	// without wildcards must match exactly.
+	MatchOSCPattern
+)
+
+// DispatcherOption configures a StandardDispatcher.
+type DispatcherOption func(*StandardDispatcher)
+
+// WithMatchMode selects how handler addresses are matched. The default is
+// MatchRegexp.
+func WithMatchMode(mode MatchMode) DispatcherOption {
+	return func(s *StandardDispatcher) {
+		s.mode = mode
+	}
+}
+
 // StandardDispatcher is a dispatcher for OSC packets. It handles the dispatching of
 // received OSC packets to Handlers for their given address.
 type StandardDispatcher struct {
-	handlers       map[string]Handler
+	handlers       map[string]*addressHandler
 	defaultHandler Handler
+	mode           MatchMode
+	scheduler      *Scheduler
+}
+
+// addressHandler is a Handler together with its precompiled address matcher.
+type addressHandler struct {
+	handler Handler
+	regexp  *regexp.Regexp
+	pattern *Pattern
 }
 
 // NewStandardDispatcher returns an StandardDispatcher.
-func NewStandardDispatcher() *StandardDispatcher {
-	return &StandardDispatcher{handlers: make(map[string]Handler)}
+func NewStandardDispatcher(opts ...DispatcherOption) *StandardDispatcher {
+	s := &StandardDispatcher{handlers: make(map[string]*addressHandler)}
+	s.scheduler = NewScheduler(s.dispatchMessage)
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Scheduler returns the scheduler that dispatches received bundles, e.g. to
+// change its late policy.
+func (s *StandardDispatcher) Scheduler() *Scheduler {
+	return s.scheduler
 }
 
-// AddMsgHandler adds a new message handler for the given OSC address.
+// AddMsgHandler adds a new message handler for the given OSC address. The
+// address "*" installs a default handler that receives every message.
 func (s *StandardDispatcher) AddMsgHandler(oscAddr string, handler HandlerFunc) error {
 	// "*" is special
 	if oscAddr == "*" {
 		s.defaultHandler = handler
 		return nil
 	}
-	// addr is a regex, used to match the input string
-	_, err := regexp.Compile(oscAddr)
-	if err != nil {
-		return err
-	}
 
-	if addressExists(oscAddr, s.handlers) {
+	if _, ok := s.handlers[oscAddr]; ok {
 		return errors.New("OSC address exists already")
 	}
 
-	s.handlers[oscAddr] = handler
+	h := &addressHandler{handler: handler}
+	var err error
+	switch s.mode {
+	case MatchOSCPattern:
+		h.pattern, err = CompilePattern(oscAddr)
+	default:
+		// addr is a regex, used to match the input string
+		h.regexp, err = regexp.Compile(oscAddr)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.handlers[oscAddr] = h
 	return nil
 }
 
+// Addresses returns the addresses of the message handlers, sorted. The
+// default handler is not included.
+func (s *StandardDispatcher) Addresses() []string {
+	addrs := make([]string, 0, len(s.handlers))
+	for addr := range s.handlers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
 // Dispatch dispatches OSC packets. Implements the Dispatcher interface.
 func (s *StandardDispatcher) Dispatch(packet Packet, addr net.Addr) {
 	switch p := packet.(type) {
@@ -75,45 +141,42 @@ func (s *StandardDispatcher) Dispatch(packet Packet, addr net.Addr) {
 		return
 
 	case *Message:
-		for oscAddr, handler := range s.handlers {
-			if p.Match(oscAddr) {
-				handler.HandleMessage(p, addr)
-			}
-		}
-		if s.defaultHandler != nil {
-			s.defaultHandler.HandleMessage(p, addr)
-		}
+		s.dispatchMessage(p, addr)
 
 	case *Bundle:
-		timer := time.NewTimer(p.Timetag.ExpiresIn())
-
-		go func() {
-			<-timer.C
-			for _, message := range p.Messages {
-				for address, handler := range s.handlers {
-					if message.Match(address) {
-						handler.HandleMessage(message, addr)
-					}
-				}
-				if s.defaultHandler != nil {
-					s.defaultHandler.HandleMessage(message, addr)
-				}
-			}
-
-			// Process all bundles
-			for _, b := range p.Bundles {
-				s.Dispatch(b, addr)
-			}
-		}()
+		s.scheduler.Schedule(p, addr)
 	}
 }
 
-// addressExists returns true if the OSC address `oscAddr` is found in `handlers`.
-func addressExists(oscAddr string, handlers map[string]Handler) bool {
-	for h := range handlers {
-		if h == oscAddr {
-			return true
+// dispatchMessage calls every handler whose address matches the message,
+// followed by the default handler.
+func (s *StandardDispatcher) dispatchMessage(msg *Message, addr net.Addr) {
+	// An incoming address pattern is compiled once and matched against the
+	// literal handler addresses.
+	var incoming *Pattern
+	if s.mode == MatchOSCPattern && IsPattern(msg.Address) {
+		incoming, _ = CompilePattern(msg.Address)
+	}
+
+	for oscAddr, h := range s.handlers {
+		if h.match(oscAddr, msg.Address, incoming) {
+			h.handler.HandleMessage(msg, addr)
 		}
 	}
-	return false
+	if s.defaultHandler != nil {
+		s.defaultHandler.HandleMessage(msg, addr)
+	}
+}
+
+// match returns true if the handler registered for `oscAddr` accepts a message
+// sent to `msgAddr`. `incoming` is the compiled message address, if it is an
+// OSC address pattern.
+func (h *addressHandler) match(oscAddr, msgAddr string, incoming *Pattern) bool {
+	if h.regexp != nil {
+		return h.regexp.MatchString(msgAddr)
+	}
+	if incoming != nil && h.pattern.literal {
+		return incoming.Match(oscAddr)
+	}
+	return h.pattern.Match(msgAddr)
 }
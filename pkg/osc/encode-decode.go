@@ -3,133 +3,317 @@
 package osc
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 )
 
 ////
 // De/Encoding functions
 ////
 
-// readBlob reads an OSC blob from the blob byte array. Padding bytes are
-// removed from the reader and not returned.
-func readBlob(reader *bufio.Reader) ([]byte, int, error) {
-	// First, get the length
-	var blobLen int32
-	if err := binary.Read(reader, binary.BigEndian, &blobLen); err != nil {
-		return nil, 0, err
+const (
+	// maxPacketSize is the largest OSC packet that fits into a UDP datagram.
+	maxPacketSize = 65535
+
+	// maxCachedAddresses limits the number of distinct addresses an
+	// addressCache remembers.
+	maxCachedAddresses = 4096
+
+	// DefaultMaxDepth is the default limit for nested bundles and arrays.
+	DefaultMaxDepth = 16
+)
+
+// Errors wrapped by a DecodeError, telling what is wrong with a packet.
+var (
+	ErrTruncated      = errors.New("truncated packet")
+	ErrUnknownPacket  = errors.New("not an OSC message or bundle")
+	ErrInvalidTypeTag = errors.New("invalid type tag string")
+	ErrInvalidLength  = errors.New("invalid length")
+	ErrInvalidPadding = errors.New("invalid padding")
+	ErrTrailingData   = errors.New("trailing data")
+	ErrTooDeep        = errors.New("nesting too deep")
+)
+
+// DecodeError reports a packet that cannot be decoded.
+type DecodeError struct {
+	// Offset is the position in the packet where decoding failed.
+	Offset int
+	// Err is one of the Err* errors above.
+	Err error
+	// Detail describes the problem further, if not empty.
+	Detail string
+}
+
+func (e *DecodeError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("osc: %v at offset %d", e.Err, e.Offset)
 	}
-	n := 4 + int(blobLen)
+	return fmt.Sprintf("osc: %v at offset %d: %v", e.Err, e.Offset, e.Detail)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeOptions controls how packets are decoded. The zero value decodes
+// leniently, as many senders get the details of the encoding wrong.
+type DecodeOptions struct {
+	// Strict rejects packets that do not follow the OSC 1.0 encoding exactly:
+	// messages without a type tag string, non-zero padding bytes, data after
+	// the last argument, and packets or bundle elements whose size is not a
+	// multiple of four.
+	Strict bool
+	// MaxDepth limits how deep bundles and arrays may be nested. Zero means
+	// DefaultMaxDepth. The limit applies in lenient mode as well.
+	MaxDepth int
+}
 
-	if blobLen < 1 || blobLen > int32(reader.Buffered()) {
-		return nil, 0, fmt.Errorf("readBlob: invalid blob length %d", blobLen)
+// ParsePacket decodes `data` into a Packet according to the options. It
+// returns a *DecodeError for invalid data and never panics. The packet does
+// not refer to `data` afterwards.
+func (o DecodeOptions) ParsePacket(data []byte) (Packet, error) {
+	return readPacket(&decoder{data: data, opts: o})
+}
+
+// packetBufferPool holds maxPacketSize byte buffers for receiving and sending
+// packets.
+var packetBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, maxPacketSize)
+		return &b
+	},
+}
+
+// getPacketBuffer returns a maxPacketSize buffer from the pool. Return it with
+// putPacketBuffer once no data refers to it anymore.
+func getPacketBuffer() *[]byte {
+	return packetBufferPool.Get().(*[]byte)
+}
+
+// putPacketBuffer returns a buffer obtained from getPacketBuffer to the pool.
+func putPacketBuffer(b *[]byte) {
+	if cap(*b) < maxPacketSize {
+		return
 	}
+	*b = (*b)[:maxPacketSize]
+	packetBufferPool.Put(b)
+}
 
-	// Read the data
-	blob := make([]byte, blobLen)
-	if _, err := reader.Read(blob); err != nil {
-		return nil, 0, err
+// addressCache interns OSC addresses, so decoding a message for an address
+// seen before does not allocate a new string. Devices such as consoles send
+// the same few addresses over and over. An addressCache is not safe for
+// concurrent use.
+type addressCache map[string]string
+
+// intern returns the cached string equal to `b`, adding it if there is room.
+func (c addressCache) intern(b []byte) string {
+	// The compiler does not allocate for a string(b) map index
+	if s, ok := c[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if len(c) < maxCachedAddresses {
+		c[s] = s
 	}
+	return s
+}
+
+// decoder parses OSC data from a byte slice.
+type decoder struct {
+	data      []byte
+	pos       int
+	addresses addressCache
+	opts      DecodeOptions
+	// base is the offset of data in the packet, and depth the number of
+	// bundles around it.
+	base  int
+	depth int
+}
+
+// remaining returns the number of bytes not parsed yet.
+func (d *decoder) remaining() int {
+	return len(d.data) - d.pos
+}
+
+// fail returns a DecodeError at offset `pos` of the decoder's data.
+func (d *decoder) fail(pos int, err error, format string, args ...interface{}) error {
+	e := &DecodeError{Offset: d.base + pos, Err: err}
+	if format != "" {
+		e.Detail = fmt.Sprintf(format, args...)
+	}
+	return e
+}
 
-	// Remove the padding bytes
-	numPadBytes := padBytesNeeded(int(blobLen))
-	if numPadBytes > 0 {
-		n += numPadBytes
-		dummy := make([]byte, numPadBytes)
-		if _, err := reader.Read(dummy); err != nil {
-			return nil, 0, err
+// maxDepth returns the nesting limit.
+func (d *decoder) maxDepth() int {
+	if d.opts.MaxDepth > 0 {
+		return d.opts.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+// element returns a decoder for the next `n` bytes, which form a bundle
+// element, and skips them.
+func (d *decoder) element(n int) *decoder {
+	e := &decoder{
+		data:      d.data[d.pos : d.pos+n],
+		addresses: d.addresses,
+		opts:      d.opts,
+		base:      d.base + d.pos,
+		depth:     d.depth + 1,
+	}
+	d.pos += n
+	return e
+}
+
+// skipPadding skips `n` padding bytes, which must be zero in strict mode.
+func (d *decoder) skipPadding(n int) error {
+	if n > d.remaining() {
+		return d.fail(len(d.data), ErrTruncated, "missing padding")
+	}
+	if d.opts.Strict {
+		for i := d.pos; i < d.pos+n; i++ {
+			if d.data[i] != 0 {
+				return d.fail(i, ErrInvalidPadding, "non-zero padding byte")
+			}
 		}
 	}
+	d.pos += n
+	return nil
+}
+
+// readPaddedBytes reads a null-terminated, padded OSC string and returns its
+// bytes without the terminator. The returned slice refers to the input data.
+func (d *decoder) readPaddedBytes() ([]byte, error) {
+	end := bytes.IndexByte(d.data[d.pos:], 0)
+	if end < 0 {
+		return nil, d.fail(len(d.data), ErrTruncated, "unterminated string")
+	}
+	str := d.data[d.pos : d.pos+end]
 
-	return blob, n, nil
+	// Skip the terminator and the padding bytes
+	n := end + 1
+	d.pos += n
+	if err := d.skipPadding(padBytesNeeded(n)); err != nil {
+		d.pos -= n
+		return nil, err
+	}
+	return str, nil
 }
 
-// writeBlob writes the data byte array as an OSC blob into buff. If the length
-// of data isn't 32-bit aligned, padding bytes will be added.
-func writeBlob(data []byte, buf *bytes.Buffer) (int, error) {
-	// Add the size of the blob
-	dlen := int32(len(data))
-	if err := binary.Write(buf, binary.BigEndian, dlen); err != nil {
-		return 0, err
+// readPaddedString reads a padded string. The padding bytes are skipped.
+func (d *decoder) readPaddedString() (string, error) {
+	b, err := d.readPaddedBytes()
+	if err != nil {
+		return "", err
 	}
+	return string(b), nil
+}
 
-	// Write the data
-	if _, err := buf.Write(data); err != nil {
-		return 0, nil
+// readAddress reads a padded string and interns it, if the decoder has an
+// address cache.
+func (d *decoder) readAddress() (string, error) {
+	b, err := d.readPaddedBytes()
+	if err != nil {
+		return "", err
+	}
+	if d.addresses != nil {
+		return d.addresses.intern(b), nil
 	}
+	return string(b), nil
+}
 
-	// Add padding bytes if necessary
-	numPadBytes := padBytesNeeded(len(data))
-	if numPadBytes > 0 {
-		padBytes := make([]byte, numPadBytes)
-		n, err := buf.Write(padBytes)
-		if err != nil {
-			return 0, err
-		}
-		numPadBytes = n
+// readBlob reads an OSC blob. Padding bytes are skipped and not returned. The
+// blob is copied out of the input data.
+func (d *decoder) readBlob() ([]byte, error) {
+	// First, get the length
+	start := d.pos
+	blobLen, err := d.readInt32()
+	if err != nil {
+		return nil, err
+	}
+
+	if blobLen < 0 || int(blobLen) > d.remaining() {
+		return nil, d.fail(start, ErrInvalidLength, "blob length %d exceeds packet", blobLen)
+	}
+
+	// Read the data
+	blob := make([]byte, blobLen)
+	copy(blob, d.data[d.pos:])
+	d.pos += int(blobLen)
+
+	// Skip the padding bytes
+	if err := d.skipPadding(padBytesNeeded(int(blobLen))); err != nil {
+		return nil, err
 	}
+	return blob, nil
+}
 
-	return 4 + len(data) + numPadBytes, nil
+// read returns the next `n` bytes.
+func (d *decoder) read(n int) ([]byte, error) {
+	if n > d.remaining() {
+		return nil, d.fail(len(d.data), ErrTruncated, "")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
 }
 
-// readPaddedString reads a padded string from the given reader. The padding
-// bytes are removed from the reader.
-func readPaddedString(reader *bufio.Reader) (string, int, error) {
-	// Read the string from the reader
-	str, err := reader.ReadString(0)
+func (d *decoder) readInt32() (int32, error) {
+	b, err := d.read(4)
 	if err != nil {
-		return "", 0, err
+		return 0, err
 	}
-	n := len(str)
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
 
-	// Remove the padding bytes (leaving the null delimiter)
-	padLen := padBytesNeeded(len(str))
-	if padLen > 0 {
-		n += padLen
-		padBytes := make([]byte, padLen)
-		if _, err = reader.Read(padBytes); err != nil {
-			return "", 0, err
-		}
+func (d *decoder) readUint64() (uint64, error) {
+	b, err := d.read(8)
+	if err != nil {
+		return 0, err
 	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// appendBlob appends the data byte array as an OSC blob to `dst`. If the
+// length of data isn't 32-bit aligned, padding bytes will be added.
+func appendBlob(dst []byte, data []byte) []byte {
+	// Add the size of the blob
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(data)))
+
+	// Write the data
+	dst = append(dst, data...)
 
-	// Strip off the string delimiter
-	return str[:len(str)-1], n, nil
+	// Add padding bytes if necessary
+	return appendPadding(dst, len(data))
 }
 
-// writePaddedString writes a string with padding bytes to the a buffer.
-// Returns, the number of written bytes and an error if any.
-func writePaddedString(str string, buf *bytes.Buffer) (int, error) {
+// appendPaddedString appends a string with a null terminator and padding
+// bytes to `dst`.
+func appendPaddedString(dst []byte, str string) []byte {
 	// Truncate at the first null, just in case there is more than one present
 	nullIndex := strings.Index(str, "\x00")
 	if nullIndex > 0 {
 		str = str[:nullIndex]
 	}
-	// Write the string to the buffer
-	n, err := buf.WriteString(str)
-	if err != nil {
-		return 0, err
-	}
-
 	// Always write a null terminator, as we stripped it earlier if it existed
-	buf.WriteByte(0)
-	n++
-
-	// Calculate the padding bytes needed and create a buffer for the padding bytes
-	numPadBytes := padBytesNeeded(n)
-	if numPadBytes > 0 {
-		padBytes := make([]byte, numPadBytes)
-		// Add the padding bytes to the buffer
-		n, err := buf.Write(padBytes)
-		if err != nil {
-			return 0, err
-		}
-		numPadBytes = n
-	}
+	dst = append(dst, str...)
+	dst = append(dst, 0)
 
-	return n + numPadBytes, nil
+	return appendPadding(dst, len(str)+1)
+}
+
+// appendPadding appends the padding bytes needed after an element of length
+// `elementLen`.
+func appendPadding(dst []byte, elementLen int) []byte {
+	for i := padBytesNeeded(elementLen); i > 0; i-- {
+		dst = append(dst, 0)
+	}
+	return dst
 }
 
 // padBytesNeeded determines how many bytes are needed to fill up to the next 4
@@ -0,0 +1,127 @@
+package osc
+
+import (
+	"testing"
+	"time"
+)
+
+// eosWheelMessage returns a message as Eos sends it for a parameter wheel of
+// the selected channels.
+func eosWheelMessage() *Message {
+	return NewMessage("/eos/out/active/wheel/1", "Pan  [127]", int32(1), float32(127))
+}
+
+// eosBundle returns a bundle of the updates Eos sends when the selection
+// changes.
+func eosBundle() *Bundle {
+	b := NewBundle(time.Time{})
+	b.Append(eosWheelMessage())
+	b.Append(NewMessage("/eos/out/active/wheel/2", "Tilt  [45]", int32(1), float32(45)))
+	b.Append(NewMessage("/eos/out/fader/1/1", float32(0.5)))
+	b.Append(NewMessage("/eos/out/fader/1/1/name", "Sub 1"))
+	return b
+}
+
+func BenchmarkDecodeMessage(b *testing.B) {
+	data, err := eosWheelMessage().MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	// The receive path decodes with an address cache
+	addresses := addressCache{}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		if _, err := readPacket(&decoder{data: data, addresses: addresses}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeBundle(b *testing.B) {
+	data, err := eosBundle().MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	addresses := addressCache{}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		if _, err := readPacket(&decoder{data: data, addresses: addresses}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendBinary(b *testing.B) {
+	msg := eosWheelMessage()
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for b.Loop() {
+		var err error
+		if buf, err = msg.AppendBinary(buf[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(int64(len(buf)))
+}
+
+// bundleOf returns the bytes of a bundle holding the element `elem`.
+func bundleOf(elem []byte) []byte {
+	data := append([]byte("#bundle\x00"), make([]byte, 8)...)
+	data = append(data, byte(len(elem)>>24), byte(len(elem)>>16), byte(len(elem)>>8), byte(len(elem)))
+	return append(data, elem...)
+}
+
+func FuzzParsePacket(f *testing.F) {
+	msg, err := eosWheelMessage().MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	bundle, err := eosBundle().MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	nested := msg
+	for range DefaultMaxDepth + 2 {
+		nested = bundleOf(nested)
+	}
+	for _, seed := range [][]byte{
+		msg,
+		bundle,
+		nested,
+		// Truncated blobs
+		[]byte("/b\x00\x00,b\x00\x00\x00\x00\x00\x08abc"),
+		[]byte("/b\x00\x00,b\x00\x00\x7f\xff\xff\xff"),
+		[]byte("/b\x00\x00,b\x00\x00\xff\xff\xff\xfc"),
+		// Bad padding
+		[]byte("/a\x00x,i\x00\x00\x00\x00\x00\x01"),
+		[]byte("/a\x00\x00,s\x00\x00ab\x00"),
+		// Bad type tags
+		[]byte("/a\x00\x00,?\x00\x00"),
+		[]byte("/a\x00\x00,]]\x00"),
+		[]byte("/a\x00\x00,[[[[\x00\x00\x00"),
+		[]byte("/a\x00\x00i\x00\x00\x00"),
+		// Bundles with bad element sizes
+		bundleOf(nil),
+		append(bundleOf(msg)[:16], 0x7f, 0xff, 0xff, 0xff),
+		append(bundleOf(msg)[:16], 0xff, 0xff, 0xff, 0xff),
+	} {
+		f.Add(seed, false)
+		f.Add(seed, true)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, strict bool) {
+		opts := DecodeOptions{Strict: strict}
+		packet, err := opts.ParsePacket(data)
+		if err != nil {
+			if _, ok := err.(*DecodeError); !ok {
+				t.Fatalf("error %v is a %T, not a *DecodeError", err, err)
+			}
+			return
+		}
+		// A decoded packet encodes again
+		if _, err := packet.MarshalBinary(); err != nil {
+			t.Fatalf("encoding %v: %v", packet, err)
+		}
+	})
+}
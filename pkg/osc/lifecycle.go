@@ -0,0 +1,147 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// ErrConnectionClosed is returned by Serve after the connection has been
+// closed with Close or Shutdown.
+var ErrConnectionClosed = errors.New("osc: connection closed")
+
+// ErrorHandlerFunc is called with every packet that cannot be decoded, and
+// with the address it came from. The connection keeps serving afterwards.
+type ErrorHandlerFunc func(err error, addr net.Addr)
+
+// serveState tracks a running Serve loop and the dispatches it started, so
+// that Shutdown can stop the loop and wait for them.
+type serveState struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	cancel   context.CancelFunc
+	done     chan struct{}
+	closed   bool
+	pool     *dispatchPool
+	counters dispatchCounters
+	// scheduler is the Scheduler of the dispatcher, if it has one
+	scheduler *Scheduler
+}
+
+// scheduled is implemented by dispatchers that queue bundles for later.
+type scheduled interface {
+	Scheduler() *Scheduler
+}
+
+// begin registers a new Serve loop that dispatches to `d` as configured by
+// `opts`. It returns the context of the loop and a function to call when the
+// loop returns.
+func (s *serveState) begin(ctx context.Context, d Dispatcher, opts DispatchOptions) (context.Context, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, nil, ErrConnectionClosed
+	}
+	if s.done != nil {
+		return nil, nil, fmt.Errorf("connection already serving")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	s.cancel = cancel
+	s.done = done
+	s.pool = newDispatchPool(d, opts, &s.counters, &s.wg)
+	if sd, ok := d.(scheduled); ok {
+		s.scheduler = sd.Scheduler()
+	}
+
+	end := func() {
+		cancel()
+		s.mu.Lock()
+		s.pool.close()
+		s.pool = nil
+		s.cancel = nil
+		s.done = nil
+		s.mu.Unlock()
+		close(done)
+	}
+	return ctx, end, nil
+}
+
+// isClosed returns true once Close or Shutdown has been called.
+func (s *serveState) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// dispatch hands the packet to the dispatch pool of the running Serve loop.
+// It must only be called from that loop.
+func (s *serveState) dispatch(packet Packet, addr net.Addr) {
+	s.pool.submit(packet, addr)
+}
+
+// stop marks the connection closed, cancels the Serve loop and drops the
+// bundles waiting in the scheduler. It returns a channel that is closed when
+// the loop has returned, or nil if no loop was running.
+func (s *serveState) stop() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.stopScheduler()
+	return s.done
+}
+
+// stopScheduler drops the bundles waiting in the scheduler. The caller holds
+// mu.
+func (s *serveState) stopScheduler() {
+	if s.scheduler != nil {
+		s.scheduler.Stop()
+	}
+}
+
+// shutdown stops the Serve loop and waits for it and for all in-flight
+// dispatches to finish, or for `ctx` to expire. `closeConn` is called in
+// either case.
+func (s *serveState) shutdown(ctx context.Context, closeConn func() error) error {
+	var err error
+	if done := s.stop(); done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if err == nil {
+		drained := make(chan struct{})
+		go func() {
+			s.wg.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	// The dispatches waited for may have scheduled more bundles
+	s.mu.Lock()
+	s.stopScheduler()
+	s.mu.Unlock()
+
+	if cerr := closeConn(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -3,10 +3,9 @@
 package osc
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
 )
@@ -95,28 +94,41 @@ func (msg *Message) String() string {
 	args = append(args, tags)
 
 	for _, arg := range msg.Arguments {
-		switch arg := arg.(type) {
-		case bool, int32, int64, float32, float64, string:
-			formatString += " %v"
-			args = append(args, arg)
-
-		case nil:
-			formatString += " %s"
-			args = append(args, "Nil")
-
-		case []byte:
-			formatString += " %s"
-			args = append(args, "blob")
-
-		case Timetag:
-			formatString += " %d"
-			args = append(args, arg.TimeTag())
-		}
+		formatString += " %s"
+		args = append(args, formatArgument(arg))
 	}
 
 	return fmt.Sprintf(formatString, args...)
 }
 
+// formatArgument returns the textual form of an argument for String.
+func formatArgument(arg interface{}) string {
+	switch arg := arg.(type) {
+	case bool, int32, int64, float32, float64, string, Symbol, Char, RGBA, MIDIMessage, Infinitum:
+		return fmt.Sprintf("%v", arg)
+
+	case nil:
+		return "Nil"
+
+	case []byte:
+		return "blob"
+
+	case Timetag:
+		return fmt.Sprintf("%d", arg.TimeTag())
+
+	case []interface{}:
+		s := "["
+		for i, a := range arg {
+			if i > 0 {
+				s += " "
+			}
+			s += formatArgument(a)
+		}
+		return s + "]"
+	}
+	return ""
+}
+
 // CountArguments returns the number of arguments.
 func (msg *Message) CountArguments() int {
 	return len(msg.Arguments)
@@ -128,198 +140,269 @@ func (msg *Message) CountArguments() int {
 // 2. OSC Type Tag String
 // 3. OSC Arguments
 func (msg *Message) MarshalBinary() ([]byte, error) {
-	// We can start with the OSC address and add it to the buffer
-	data := new(bytes.Buffer)
-	if _, err := writePaddedString(msg.Address, data); err != nil {
-		return nil, err
-	}
+	return msg.AppendBinary(nil)
+}
 
-	// Type tag string starts with ","
-	typetags := []byte{','}
+// AppendBinary appends the serialized OSC message to `dst` and returns the
+// extended buffer. It does not allocate if `dst` has enough capacity.
+func (msg *Message) AppendBinary(dst []byte) ([]byte, error) {
+	// We can start with the OSC address
+	dst = appendPaddedString(dst, msg.Address)
 
-	// Process the type tags and collect all arguments
-	payload := new(bytes.Buffer)
+	// Type tag string starts with ","
+	start := len(dst)
+	dst = append(dst, ',')
 	for _, arg := range msg.Arguments {
-		// FIXME: Use t instead of arg
-		switch t := arg.(type) {
-		default:
-			return nil, fmt.Errorf("OSC - unsupported type: %T", t)
+		var err error
+		if dst, err = appendTypeTags(dst, arg); err != nil {
+			return nil, err
+		}
+	}
+	dst = append(dst, 0)
+	dst = appendPadding(dst, len(dst)-start)
 
-		case bool:
-			if arg.(bool) {
-				typetags = append(typetags, 'T')
-			} else {
-				typetags = append(typetags, 'F')
-			}
+	// Append the payload (OSC arguments)
+	for _, arg := range msg.Arguments {
+		dst = appendArgument(dst, arg)
+	}
 
-		case nil:
-			typetags = append(typetags, 'N')
+	return dst, nil
+}
 
-		case int32:
-			typetags = append(typetags, 'i')
-			if err := binary.Write(payload, binary.BigEndian, int32(t)); err != nil {
+// appendTypeTags appends the type tag of `arg` to `dst`. Arrays append the
+// tags of their elements between '[' and ']'.
+func appendTypeTags(dst []byte, arg interface{}) ([]byte, error) {
+	if a, ok := arg.([]interface{}); ok {
+		dst = append(dst, '[')
+		for _, elem := range a {
+			var err error
+			if dst, err = appendTypeTags(dst, elem); err != nil {
 				return nil, err
 			}
+		}
+		return append(dst, ']'), nil
+	}
 
-		case float32:
-			typetags = append(typetags, 'f')
-			if err := binary.Write(payload, binary.BigEndian, float32(t)); err != nil {
-				return nil, err
-			}
+	tag, err := typeTag(arg)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, tag), nil
+}
 
-		case string:
-			typetags = append(typetags, 's')
-			if _, err := writePaddedString(t, payload); err != nil {
-				return nil, err
-			}
+// appendArgument appends the value of `arg` to `dst`. The type of `arg` must
+// have been checked by appendTypeTags.
+func appendArgument(dst []byte, arg interface{}) []byte {
+	switch t := arg.(type) {
+	case int32:
+		dst = binary.BigEndian.AppendUint32(dst, uint32(t))
 
-		case []byte:
-			typetags = append(typetags, 'b')
-			if _, err := writeBlob(t, payload); err != nil {
-				return nil, err
-			}
+	case float32:
+		dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(t))
 
-		case int64:
-			typetags = append(typetags, 'h')
-			if err := binary.Write(payload, binary.BigEndian, int64(t)); err != nil {
-				return nil, err
-			}
+	case string:
+		dst = appendPaddedString(dst, t)
 
-		case float64:
-			typetags = append(typetags, 'd')
-			if err := binary.Write(payload, binary.BigEndian, float64(t)); err != nil {
-				return nil, err
-			}
+	case Symbol:
+		dst = appendPaddedString(dst, string(t))
 
-		case Timetag:
-			typetags = append(typetags, 't')
-			timeTag := arg.(Timetag)
-			b, err := timeTag.MarshalBinary()
-			if err != nil {
-				return nil, err
-			}
-			if _, err = payload.Write(b); err != nil {
-				return nil, err
-			}
-		}
-	}
+	case []byte:
+		dst = appendBlob(dst, t)
 
-	// Write the type tag string to the data buffer
-	if _, err := writePaddedString(string(typetags), data); err != nil {
-		return nil, err
-	}
+	case int64:
+		dst = binary.BigEndian.AppendUint64(dst, uint64(t))
 
-	// Write the payload (OSC arguments) to the data buffer
-	if _, err := data.Write(payload.Bytes()); err != nil {
-		return nil, err
+	case float64:
+		dst = binary.BigEndian.AppendUint64(dst, math.Float64bits(t))
+
+	case Char:
+		dst = binary.BigEndian.AppendUint32(dst, uint32(t))
+
+	case RGBA:
+		dst = append(dst, t.R, t.G, t.B, t.A)
+
+	case MIDIMessage:
+		dst = append(dst, t.Port, t.Status, t.Data1, t.Data2)
+
+	case Timetag:
+		dst = binary.BigEndian.AppendUint64(dst, t.TimeTag())
+
+	case []interface{}:
+		for _, a := range t {
+			dst = appendArgument(dst, a)
+		}
 	}
 
-	return data.Bytes(), nil
+	// bool, nil and Infinitum have no payload
+	return dst
 }
 
-// readMessage from `reader`.
-func readMessage(reader *bufio.Reader) (*Message, error) {
+// readMessage reads a message from the decoder.
+func readMessage(d *decoder) (*Message, error) {
 	// First, read the OSC address
-	addr, _, err := readPaddedString(reader)
+	addr, err := d.readAddress()
 	if err != nil {
 		return nil, err
 	}
 
 	// Read all arguments
-	msg := NewMessage(addr)
-	if err = readArguments(msg, reader); err != nil {
+	msg := &Message{Address: addr}
+	if err = readArguments(msg, d); err != nil {
 		return nil, err
 	}
 
 	return msg, nil
 }
 
-// readArguments from `reader` and add them to the OSC message `msg`.
-func readArguments(msg *Message, reader *bufio.Reader) error {
-	// Read the type tag string
-	typetags, _, err := readPaddedString(reader)
+// readArguments from the decoder and add them to the OSC message `msg`.
+func readArguments(msg *Message, d *decoder) error {
+	// Read the type tag string. Old senders may omit it, which strict mode
+	// does not allow.
+	tagsPos := d.pos
+	if d.remaining() == 0 {
+		if d.opts.Strict {
+			return d.fail(tagsPos, ErrInvalidTypeTag, "missing type tag string")
+		}
+		return nil
+	}
+	typetags, err := d.readPaddedBytes()
 	if err != nil {
 		return err
 	}
 
-	if len(typetags) == 0 {
+	if len(typetags) == 0 && !d.opts.Strict {
 		return nil
 	}
 
 	// If the typetag doesn't start with ',', it's not valid
-	if typetags[0] != ',' {
-		return fmt.Errorf("unsupported type tag string %s", typetags)
+	if len(typetags) == 0 || typetags[0] != ',' {
+		return d.fail(tagsPos, ErrInvalidTypeTag, "%q does not start with ','", typetags)
 	}
 
 	// Remove ',' from the type tag
 	typetags = typetags[1:]
 
+	// The top of the stack receives the arguments; '[' starts a nested array
+	args := [][]interface{}{make([]interface{}, 0, len(typetags))}
 	for _, c := range typetags {
+		var arg interface{}
+
 		switch c {
 		default:
-			return fmt.Errorf("unsupported type tag: %c", c)
+			return d.fail(tagsPos, ErrInvalidTypeTag, "unsupported type tag %q", c)
+
+		case '[': // array start
+			if d.depth+len(args) > d.maxDepth() {
+				return d.fail(tagsPos, ErrTooDeep, "more than %d levels", d.maxDepth())
+			}
+			args = append(args, []interface{}{})
+			continue
+
+		case ']': // array end
+			if len(args) < 2 {
+				return d.fail(tagsPos, ErrInvalidTypeTag, "unbalanced ']'")
+			}
+			arg = args[len(args)-1]
+			args = args[:len(args)-1]
 
 		case 'i': // int32
 			var i int32
-			if err = binary.Read(reader, binary.BigEndian, &i); err != nil {
+			if i, err = d.readInt32(); err != nil {
 				return err
 			}
-			msg.Append(i)
+			arg = i
 
 		case 'h': // int64
-			var i int64
-			if err = binary.Read(reader, binary.BigEndian, &i); err != nil {
+			var u uint64
+			if u, err = d.readUint64(); err != nil {
 				return err
 			}
-			msg.Append(i)
+			arg = int64(u)
 
 		case 'f': // float32
-			var f float32
-			if err = binary.Read(reader, binary.BigEndian, &f); err != nil {
+			var i int32
+			if i, err = d.readInt32(); err != nil {
 				return err
 			}
-			msg.Append(f)
+			arg = math.Float32frombits(uint32(i))
 
 		case 'd': // float64/double
-			var d float64
-			if err = binary.Read(reader, binary.BigEndian, &d); err != nil {
+			var u uint64
+			if u, err = d.readUint64(); err != nil {
 				return err
 			}
-			msg.Append(d)
+			arg = math.Float64frombits(u)
 
-		case 's': // string
-			// TODO: fix reading string value
+		case 's', 'S': // string, symbol
 			var s string
-			if s, _, err = readPaddedString(reader); err != nil {
+			if s, err = d.readPaddedString(); err != nil {
 				return err
 			}
-			msg.Append(s)
+			if c == 'S' {
+				arg = Symbol(s)
+			} else {
+				arg = s
+			}
 
 		case 'b': // blob
 			var buf []byte
-			if buf, _, err = readBlob(reader); err != nil {
+			if buf, err = d.readBlob(); err != nil {
+				return err
+			}
+			arg = buf
+
+		case 'c': // ASCII character
+			var r int32
+			if r, err = d.readInt32(); err != nil {
+				return err
+			}
+			arg = Char(r)
+
+		case 'r': // RGBA color
+			var b []byte
+			if b, err = d.read(4); err != nil {
 				return err
 			}
-			msg.Append(buf)
+			arg = RGBA{R: b[0], G: b[1], B: b[2], A: b[3]}
+
+		case 'm': // MIDI message
+			var b []byte
+			if b, err = d.read(4); err != nil {
+				return err
+			}
+			arg = MIDIMessage{Port: b[0], Status: b[1], Data1: b[2], Data2: b[3]}
 
 		case 't': // OSC time tag
 			var tt uint64
-			if err = binary.Read(reader, binary.BigEndian, &tt); err != nil {
-				return nil
+			if tt, err = d.readUint64(); err != nil {
+				return err
 			}
-			msg.Append(*NewTimetagFromTimetag(tt))
+			arg = *NewTimetagFromTimetag(tt)
 
 		case 'N': // nil
-			msg.Append(nil)
+			arg = nil
+
+		case 'I': // infinitum/impulse
+			arg = Infinitum{}
 
 		case 'T': // true
-			msg.Append(true)
+			arg = true
 
 		case 'F': // false
-			msg.Append(false)
+			arg = false
 		}
+
+		args[len(args)-1] = append(args[len(args)-1], arg)
 	}
 
+	if len(args) != 1 {
+		return d.fail(tagsPos, ErrInvalidTypeTag, "missing ']'")
+	}
+	if d.opts.Strict && d.remaining() > 0 {
+		return d.fail(d.pos, ErrTrailingData, "%d bytes after the arguments", d.remaining())
+	}
+	msg.Arguments = args[0]
+
 	return nil
 }
 
@@ -330,29 +413,48 @@ func PrintMessage(msg *Message) {
 
 // getTypeTag returns the OSC type tag for the given argument.
 func getTypeTag(arg interface{}) (string, error) {
+	tags, err := appendTypeTags(nil, arg)
+	if err != nil {
+		return "", err
+	}
+	return string(tags), nil
+}
+
+// typeTag returns the OSC type tag for the given non-array argument.
+func typeTag(arg interface{}) (byte, error) {
 	switch t := arg.(type) {
 	case bool:
-		if arg.(bool) {
-			return "T", nil
+		if t {
+			return 'T', nil
 		}
-		return "F", nil
+		return 'F', nil
 	case nil:
-		return "N", nil
+		return 'N', nil
 	case int32:
-		return "i", nil
+		return 'i', nil
 	case float32:
-		return "f", nil
+		return 'f', nil
 	case string:
-		return "s", nil
+		return 's', nil
 	case []byte:
-		return "b", nil
+		return 'b', nil
 	case int64:
-		return "h", nil
+		return 'h', nil
 	case float64:
-		return "d", nil
+		return 'd', nil
 	case Timetag:
-		return "t", nil
+		return 't', nil
+	case Char:
+		return 'c', nil
+	case RGBA:
+		return 'r', nil
+	case MIDIMessage:
+		return 'm', nil
+	case Symbol:
+		return 'S', nil
+	case Infinitum:
+		return 'I', nil
 	default:
-		return "", fmt.Errorf("unsupported type: %T", t)
+		return 0, fmt.Errorf("unsupported type: %T", t)
 	}
 }
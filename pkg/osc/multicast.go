@@ -0,0 +1,123 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// MulticastConfig configures a Connection to receive from and send to IPv4
+// multicast groups.
+type MulticastConfig struct {
+	// Groups lists the multicast group addresses to join, e.g. "239.1.1.1".
+	Groups []string
+	// Interface names the network interface used to join the groups and to
+	// send multicast packets. The system chooses one if it is empty.
+	Interface string
+	// TTL is the time-to-live of multicast packets sent. Zero keeps the
+	// system default, which keeps packets on the local network.
+	TTL int
+	// Loopback delivers multicast packets sent by this host to listeners on
+	// this host as well, including this connection.
+	Loopback bool
+}
+
+// SetMulticast configures the connection to join multicast groups when it is
+// opened. It must be called before Open. The local port is shared with other
+// sockets on this host, so several tools can listen to the same group. To
+// send to a group, set the remote address to the group address and port.
+func (c *Connection) SetMulticast(cfg MulticastConfig) error {
+	if c.conn != nil {
+		return fmt.Errorf("connection already opened")
+	}
+	for _, g := range cfg.Groups {
+		ip := net.ParseIP(g)
+		if ip == nil || ip.To4() == nil || !ip.IsMulticast() {
+			return fmt.Errorf("not an IPv4 multicast group: %v", g)
+		}
+	}
+	c.multicast = &cfg
+	return nil
+}
+
+// listenMulticast opens the UDP socket of a multicast connection and applies
+// the multicast configuration.
+func (c *Connection) listenMulticast() (*net.UDPConn, error) {
+	var ifi *net.Interface
+	if c.multicast.Interface != "" {
+		var err error
+		if ifi, err = net.InterfaceByName(c.multicast.Interface); err != nil {
+			return nil, err
+		}
+	}
+
+	lc := net.ListenConfig{Control: setReuseAddr}
+	pc, err := lc.ListenPacket(context.Background(), "udp4", c.laddr.String())
+	if err != nil {
+		return nil, err
+	}
+	conn := pc.(*net.UDPConn)
+
+	if err = setMulticastOptions(conn, c.multicast, ifi); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// BroadcastAddress returns the IPv4 directed broadcast address of the named
+// network interface together with `port`, e.g. "192.168.1.255:8000", for use
+// as a remote address. Sending to "255.255.255.255" reaches the local network
+// of every interface instead.
+func BroadcastAddress(iface string, port int) (string, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return "", err
+	}
+	bcast := broadcastIP(addrs)
+	if bcast == nil {
+		return "", fmt.Errorf("interface %v has no IPv4 address", iface)
+	}
+	return net.JoinHostPort(bcast.String(), fmt.Sprint(port)), nil
+}
+
+// broadcastIP returns the directed broadcast address of the first IPv4
+// network in `addrs`, or nil.
+func broadcastIP(addrs []net.Addr) net.IP {
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP.To4()
+		if ip == nil || len(ipnet.Mask) != net.IPv4len {
+			continue
+		}
+		bcast := make(net.IP, net.IPv4len)
+		for i := range ip {
+			bcast[i] = ip[i] | ^ipnet.Mask[i]
+		}
+		return bcast
+	}
+	return nil
+}
+
+// interfaceIPv4 returns the first IPv4 address of the interface, or nil.
+func interfaceIPv4(ifi *net.Interface) (net.IP, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return ipnet.IP.To4(), nil
+		}
+	}
+	return nil, fmt.Errorf("interface %v has no IPv4 address", ifi.Name)
+}
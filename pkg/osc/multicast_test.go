@@ -0,0 +1,102 @@
+package osc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestSetMulticast(t *testing.T) {
+	for _, tt := range []struct {
+		groups []string
+		ok     bool
+	}{
+		{groups: []string{"239.1.1.1", "224.0.0.251"}, ok: true},
+		{groups: nil, ok: true},
+		{groups: []string{"10.101.100.101"}},
+		{groups: []string{"ff02::1"}},
+		{groups: []string{"eos"}},
+		{groups: []string{"239.1.1.1", "192.168.1.255"}},
+	} {
+		c, err := NewConnection(0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.SetMulticast(MulticastConfig{Groups: tt.groups})
+		if (err == nil) != tt.ok {
+			t.Errorf("SetMulticast(%q) = %v", tt.groups, err)
+		}
+		if err != nil && c.multicast != nil {
+			t.Errorf("SetMulticast(%q) kept the invalid configuration", tt.groups)
+		}
+	}
+
+	c := newTestConnection(t, "")
+	if err := c.SetMulticast(MulticastConfig{Groups: []string{"239.1.1.1"}}); err == nil {
+		t.Error("SetMulticast of an opened connection: no error")
+	}
+}
+
+func TestBroadcastIP(t *testing.T) {
+	ipnet := func(cidr string) *net.IPNet {
+		ip, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.IP = ip
+		return n
+	}
+
+	for _, tt := range []struct {
+		addrs []net.Addr
+		want  string
+	}{
+		{addrs: []net.Addr{ipnet("192.168.1.10/24")}, want: "192.168.1.255"},
+		{addrs: []net.Addr{ipnet("10.101.100.101/16")}, want: "10.101.255.255"},
+		{addrs: []net.Addr{ipnet("172.16.5.4/20")}, want: "172.16.15.255"},
+		{addrs: []net.Addr{ipnet("10.0.0.1/32")}, want: "10.0.0.1"},
+		// IPv6 networks and other addresses are skipped
+		{addrs: []net.Addr{ipnet("fe80::1/64"), &net.IPAddr{IP: net.IPv4(10, 0, 0, 1)}, ipnet("10.0.0.1/8")}, want: "10.255.255.255"},
+		{addrs: []net.Addr{&net.IPNet{IP: net.IPv4(10, 0, 0, 1), Mask: net.CIDRMask(8, 128)}}, want: "<nil>"},
+		{addrs: nil, want: "<nil>"},
+	} {
+		if got := broadcastIP(tt.addrs).String(); got != tt.want {
+			t.Errorf("broadcastIP(%v) = %s; want %s", tt.addrs, got, tt.want)
+		}
+	}
+
+	if _, err := BroadcastAddress("no-such-interface", 8000); err == nil {
+		t.Error("BroadcastAddress of an unknown interface: no error")
+	}
+}
+
+func TestMulticastLoopback(t *testing.T) {
+	const group = "239.255.77.77"
+	c, err := NewConnection(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetMulticast(MulticastConfig{Groups: []string{group}, Loopback: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Open(); err != nil {
+		t.Skipf("cannot join %s: %v", group, err)
+	}
+	defer c.Close()
+
+	d := make(chanDispatcher, 1)
+	c.Dispatcher = d
+	go c.Serve(context.Background())
+
+	port := c.conn.LocalAddr().(*net.UDPAddr).Port
+	if err := c.SetRemoteAddress(fmt.Sprintf("%s:%d", group, port)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(NewMessage("/eos/ping")); err != nil {
+		t.Skipf("cannot send to %s: %v", group, err)
+	}
+	if got := d.next(t); got != "/eos/ping" {
+		t.Errorf("received %s", got)
+	}
+}
@@ -3,49 +3,53 @@
 package osc
 
 import (
-	"bufio"
-	"bytes"
 	"encoding"
 )
 
 // Packet is the interface for Message and Bundle.
 type Packet interface {
 	encoding.BinaryMarshaler
+	encoding.BinaryAppender
 }
 
 // ParsePacket parses the given msg string and returns a Packet
 func ParsePacket(msg string) (Packet, error) {
-	p, err := readPacket(bufio.NewReader(bytes.NewBufferString(msg)))
-	if err != nil {
-		return nil, err
-	}
-	return p, nil
+	return ParsePacketBytes([]byte(msg))
+}
+
+// ParsePacketBytes parses the given data leniently and returns a Packet. The
+// packet does not refer to `data` afterwards. Invalid data is reported as a
+// *DecodeError. Use DecodeOptions to parse strictly.
+func ParsePacketBytes(data []byte) (Packet, error) {
+	return DecodeOptions{}.ParsePacket(data)
 }
 
-// receivePacket receives an OSC packet from the given reader.
-func readPacket(reader *bufio.Reader) (Packet, error) {
-	//var buf []byte
-	buf, err := reader.Peek(1)
-	if err != nil {
-		return nil, err
+// readPacket reads an OSC packet from the decoder.
+func readPacket(d *decoder) (Packet, error) {
+	if d.remaining() == 0 {
+		return nil, d.fail(d.pos, ErrTruncated, "empty packet")
+	}
+	if d.depth > d.maxDepth() {
+		return nil, d.fail(d.pos, ErrTooDeep, "more than %d levels", d.maxDepth())
+	}
+	if d.opts.Strict && len(d.data)%4 != 0 {
+		return nil, d.fail(len(d.data), ErrInvalidPadding, "size %d is not a multiple of 4", len(d.data))
 	}
 
 	// An OSC Message starts with a '/'
-	if buf[0] == '/' {
-		packet, err := readMessage(reader)
+	switch d.data[d.pos] {
+	case '/':
+		packet, err := readMessage(d)
 		if err != nil {
 			return nil, err
 		}
 		return packet, nil
-	}
-	if buf[0] == '#' { // An OSC bundle starts with a '#'
-		packet, err := readBundle(reader)
+	case '#': // An OSC bundle starts with a '#'
+		packet, err := readBundle(d)
 		if err != nil {
 			return nil, err
 		}
 		return packet, nil
 	}
-
-	var p Packet
-	return p, nil
+	return nil, d.fail(d.pos, ErrUnknownPacket, "starts with %q", d.data[d.pos])
 }
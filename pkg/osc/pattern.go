@@ -0,0 +1,309 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// patternChars are the characters with a special meaning in an OSC address
+// pattern.
+const patternChars = "?*[]{}"
+
+// Pattern is a compiled OSC address pattern. It supports the OSC 1.0 wildcards
+// '?', '*', '[...]', '[!...]' and '{foo,bar}' within a part of the address, as
+// well as the OSC 1.1 path-traversing wildcard '//', which matches any number
+// of address parts, including none.
+type Pattern struct {
+	pattern string
+	literal bool
+	parts   [][]patternToken // nil entry means '//' path traversal
+}
+
+type tokenKind int
+
+const (
+	tokenLiteral tokenKind = iota // text must match exactly
+	tokenAny                      // '?', any single character
+	tokenStar                     // '*', any sequence of characters
+	tokenClass                    // '[...]', a character from the set
+	tokenChoice                   // '{...}', one of a list of strings
+)
+
+type patternToken struct {
+	kind    tokenKind
+	text    string
+	ranges  []rune // pairs of inclusive bounds for tokenClass
+	negate  bool
+	choices []string
+}
+
+////
+// Pattern
+////
+
+// CompilePattern parses an OSC address pattern. The pattern must start with
+// '/'.
+func CompilePattern(pattern string) (*Pattern, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("OSC address pattern must start with '/': %q", pattern)
+	}
+
+	p := &Pattern{pattern: pattern}
+	if !IsPattern(pattern) {
+		p.literal = true
+		return p, nil
+	}
+
+	for i, part := range strings.Split(pattern[1:], "/") {
+		if part == "" {
+			if i > 0 && p.parts[len(p.parts)-1] == nil {
+				return nil, fmt.Errorf("invalid OSC address pattern %q: repeated '//'", pattern)
+			}
+			p.parts = append(p.parts, nil)
+			continue
+		}
+		tokens, err := compilePart(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OSC address pattern %q: %v", pattern, err)
+		}
+		p.parts = append(p.parts, tokens)
+	}
+	return p, nil
+}
+
+// MustCompilePattern is like CompilePattern but panics if the pattern cannot be
+// parsed.
+func MustCompilePattern(pattern string) *Pattern {
+	p, err := CompilePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// IsPattern returns true if `addr` contains any OSC pattern-matching
+// characters or the '//' path-traversing wildcard.
+func IsPattern(addr string) bool {
+	return strings.ContainsAny(addr, patternChars) || strings.Contains(addr, "//")
+}
+
+// String returns the source text of the pattern.
+func (p *Pattern) String() string {
+	return p.pattern
+}
+
+// Match returns true if the OSC address `addr` matches the pattern. The match
+// is case sensitive.
+func (p *Pattern) Match(addr string) bool {
+	if p.literal {
+		return p.pattern == addr
+	}
+	if !strings.HasPrefix(addr, "/") {
+		return false
+	}
+	return matchParts(p.parts, strings.Split(addr[1:], "/"))
+}
+
+// matchPrefix returns true if an address matched by the pattern can be the
+// address with the given parts or lie below it, e.g. "/eos/out/*" and
+// "/eos//ping" against "/eos/out".
+func (p *Pattern) matchPrefix(addr []string) bool {
+	if p.literal {
+		prefix := "/" + strings.Join(addr, "/")
+		return len(addr) == 0 || p.pattern == prefix || strings.HasPrefix(p.pattern, prefix+"/")
+	}
+	parts := p.parts
+	for ; len(addr) > 0; addr = addr[1:] {
+		if len(parts) == 0 {
+			return false
+		}
+		if parts[0] == nil {
+			// '//' reaches any address below
+			return true
+		}
+		if !matchTokens(parts[0], addr[0]) {
+			return false
+		}
+		parts = parts[1:]
+	}
+	return true
+}
+
+// matchParts matches the compiled pattern parts against the address parts.
+func matchParts(parts [][]patternToken, addr []string) bool {
+	for len(parts) > 0 {
+		if parts[0] == nil {
+			// '//' matches any number of address parts
+			for i := 0; i <= len(addr); i++ {
+				if matchParts(parts[1:], addr[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(addr) == 0 || !matchTokens(parts[0], addr[0]) {
+			return false
+		}
+		parts = parts[1:]
+		addr = addr[1:]
+	}
+	return len(addr) == 0
+}
+
+// matchTokens matches a single address part against the tokens of one pattern
+// part.
+func matchTokens(tokens []patternToken, s string) bool {
+	for len(tokens) > 0 {
+		t := tokens[0]
+		tokens = tokens[1:]
+
+		switch t.kind {
+		case tokenLiteral:
+			if !strings.HasPrefix(s, t.text) {
+				return false
+			}
+			s = s[len(t.text):]
+
+		case tokenAny:
+			if s == "" {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(s)
+			s = s[size:]
+
+		case tokenClass:
+			if s == "" {
+				return false
+			}
+			r, size := utf8.DecodeRuneInString(s)
+			if t.matchRune(r) == t.negate {
+				return false
+			}
+			s = s[size:]
+
+		case tokenStar:
+			if len(tokens) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchTokens(tokens, s[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case tokenChoice:
+			for _, c := range t.choices {
+				if strings.HasPrefix(s, c) && matchTokens(tokens, s[len(c):]) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return s == ""
+}
+
+// matchRune returns true if `r` is in one of the ranges of a character class.
+func (t patternToken) matchRune(r rune) bool {
+	for i := 0; i < len(t.ranges); i += 2 {
+		if t.ranges[i] <= r && r <= t.ranges[i+1] {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePart converts a single part of an address pattern into tokens.
+func compilePart(part string) ([]patternToken, error) {
+	var tokens []patternToken
+	runes := []rune(part)
+
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '?':
+			tokens = append(tokens, patternToken{kind: tokenAny})
+
+		case '*':
+			// Consecutive stars are equivalent to a single one
+			if len(tokens) == 0 || tokens[len(tokens)-1].kind != tokenStar {
+				tokens = append(tokens, patternToken{kind: tokenStar})
+			}
+
+		case '[':
+			end := indexRune(runes[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']'")
+			}
+			class, err := compileClass(runes[i+1 : i+1+end])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, class)
+			i += end + 1
+
+		case '{':
+			end := indexRune(runes[i+1:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("missing '}'")
+			}
+			body := string(runes[i+1 : i+1+end])
+			if strings.ContainsAny(body, patternChars) {
+				return nil, fmt.Errorf("unsupported pattern characters in {%s}", body)
+			}
+			tokens = append(tokens, patternToken{kind: tokenChoice, choices: strings.Split(body, ",")})
+			i += end + 1
+
+		case ']', '}':
+			return nil, fmt.Errorf("unexpected '%c'", c)
+
+		default:
+			if n := len(tokens); n > 0 && tokens[n-1].kind == tokenLiteral {
+				tokens[n-1].text += string(c)
+			} else {
+				tokens = append(tokens, patternToken{kind: tokenLiteral, text: string(c)})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// compileClass parses the body of a '[...]' character class. A leading '!'
+// negates the class, and '-' between two characters denotes a range. A '-' at
+// the start or end of the class stands for itself.
+func compileClass(body []rune) (patternToken, error) {
+	t := patternToken{kind: tokenClass}
+	if len(body) > 0 && body[0] == '!' {
+		t.negate = true
+		body = body[1:]
+	}
+	if len(body) == 0 {
+		return t, fmt.Errorf("empty character class")
+	}
+
+	for i := 0; i < len(body); i++ {
+		lo, hi := body[i], body[i]
+		if i+2 < len(body) && body[i+1] == '-' {
+			hi = body[i+2]
+			i += 2
+			if lo > hi {
+				return t, fmt.Errorf("invalid range %c-%c", lo, hi)
+			}
+		}
+		t.ranges = append(t.ranges, lo, hi)
+	}
+	return t, nil
+}
+
+// indexRune returns the index of the first `r` in `runes`, or -1.
+func indexRune(runes []rune, r rune) int {
+	for i, c := range runes {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,160 @@
+package osc
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPatternMatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{
+			pattern: "/eos/out/fader/1/2",
+			match:   []string{"/eos/out/fader/1/2"},
+			noMatch: []string{"/eos/out/fader/1/20", "/eos/out/fader/1"},
+		},
+		{
+			pattern: "/eos/fader/?/1",
+			match:   []string{"/eos/fader/1/1", "/eos/fader/é/1"},
+			noMatch: []string{"/eos/fader/10/1", "/eos/fader//1"},
+		},
+		{
+			pattern: "/eos/out/*/name",
+			match:   []string{"/eos/out/fader/name", "/eos/out//name"},
+			noMatch: []string{"/eos/out/fader/1/name"},
+		},
+		{
+			pattern: "/eos/out/fader/*1",
+			match:   []string{"/eos/out/fader/1", "/eos/out/fader/11", "/eos/out/fader/21"},
+			noMatch: []string{"/eos/out/fader/12"},
+		},
+		{
+			pattern: "/eos/fader/[1-3]/[!02-9]",
+			match:   []string{"/eos/fader/2/1"},
+			noMatch: []string{"/eos/fader/4/1", "/eos/fader/2/5", "/eos/fader/2/10"},
+		},
+		{
+			pattern: "/eos/wheel/[-c]",
+			match:   []string{"/eos/wheel/-", "/eos/wheel/c"},
+			noMatch: []string{"/eos/wheel/b"},
+		},
+		{
+			pattern: "/eos/key/{go_0,stop}",
+			match:   []string{"/eos/key/go_0", "/eos/key/stop"},
+			noMatch: []string{"/eos/key/go", "/eos/key/stop_0"},
+		},
+		{
+			pattern: "/eos/{wheel,wheels}/*",
+			match:   []string{"/eos/wheel/pan", "/eos/wheels/pan"},
+		},
+		{
+			pattern: "//ping",
+			match:   []string{"/ping", "/eos/ping", "/eos/out/ping"},
+			noMatch: []string{"/eos/ping/1"},
+		},
+		{
+			pattern: "/eos//name",
+			match:   []string{"/eos/name", "/eos/out/fader/1/name"},
+			noMatch: []string{"/qlab/name"},
+		},
+	} {
+		t.Run(tt.pattern, func(t *testing.T) {
+			p, err := CompilePattern(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, addr := range tt.match {
+				if !p.Match(addr) {
+					t.Errorf("%q does not match %q", tt.pattern, addr)
+				}
+			}
+			for _, addr := range tt.noMatch {
+				if p.Match(addr) {
+					t.Errorf("%q matches %q", tt.pattern, addr)
+				}
+			}
+		})
+	}
+}
+
+func TestCompilePatternInvalid(t *testing.T) {
+	for _, pattern := range []string{
+		"eos/ping",
+		"/eos/[1-",
+		"/eos/[]",
+		"/eos/[3-1]",
+		"/eos/{go,stop",
+		"/eos/{go,*}",
+		"/eos/]",
+		"/eos///ping",
+	} {
+		if _, err := CompilePattern(pattern); err == nil {
+			t.Errorf("CompilePattern(%q): no error", pattern)
+		}
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"/eos/ping":       false,
+		"/eos/fader/*":    true,
+		"/eos/key/{a,b}":  true,
+		"//ping":          true,
+		"/eos/fader/[12]": true,
+	} {
+		if got := IsPattern(addr); got != want {
+			t.Errorf("IsPattern(%q) = %v; want %v", addr, got, want)
+		}
+	}
+}
+
+func TestStandardDispatcherPattern(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		handlers []string
+		addr     string
+		want     []string
+	}{
+		{
+			name:     "incoming pattern",
+			handlers: []string{"/eos/out/fader/1/1", "/eos/out/fader/1/2", "/eos/out/fader/2/1"},
+			addr:     "/eos/out/fader/1/*",
+			want:     []string{"/eos/out/fader/1/1", "/eos/out/fader/1/2"},
+		},
+		{
+			name:     "handler pattern",
+			handlers: []string{"/eos/out/fader/*/1", "/eos/out/fader/1/2", "//ping"},
+			addr:     "/eos/out/fader/2/1",
+			want:     []string{"/eos/out/fader/*/1"},
+		},
+		{
+			name:     "literal addresses match exactly",
+			handlers: []string{"/eos/out/fader", "/eos/out/fader/1"},
+			addr:     "/eos/out/fader/1",
+			want:     []string{"/eos/out/fader/1"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewStandardDispatcher(WithMatchMode(MatchOSCPattern))
+			var got []string
+			for _, addr := range tt.handlers {
+				if err := d.AddMsgHandler(addr, func(*Message, net.Addr) {
+					got = append(got, addr)
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d.Dispatch(NewMessage(tt.addr), nil)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dispatched to %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
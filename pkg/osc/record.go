@@ -0,0 +1,401 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Direction tells whether a recorded packet was received or sent.
+type Direction int
+
+const (
+	// DirectionIn marks a received packet.
+	DirectionIn Direction = iota
+	// DirectionOut marks a sent packet.
+	DirectionOut
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionIn:
+		return "in"
+	case DirectionOut:
+		return "out"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+// RecordFormat is the file format of a recording.
+type RecordFormat int
+
+const (
+	// RecordBinary is a compact binary format. It starts with a header, and
+	// every record is the time in Unix nanoseconds (int64), the direction
+	// (uint8), the network and address of the peer (each an uint8 length and
+	// the bytes) and the packet (an uint32 length and the bytes), all big
+	// endian.
+	RecordBinary RecordFormat = iota
+	// RecordJSON writes one JSON object per line, with the packet as base64
+	// and, for readability, in the notation of Message.String.
+	RecordJSON
+)
+
+// recordMagic starts every recording in the binary format.
+const recordMagic = "OSCREC1\n"
+
+// Record is a packet in a recording.
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	// Network and Addr describe the peer the packet came from or went to.
+	Network string
+	Addr    string
+	// Data is the encoded packet.
+	Data []byte
+}
+
+// Packet decodes the recorded packet.
+func (r *Record) Packet() (Packet, error) {
+	return ParsePacketBytes(r.Data)
+}
+
+// jsonRecord is a Record in the JSON-lines format.
+type jsonRecord struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"dir"`
+	Network   string    `json:"net,omitempty"`
+	Addr      string    `json:"addr,omitempty"`
+	Data      []byte    `json:"data"`
+	Text      string    `json:"text,omitempty"`
+}
+
+// Recorder writes the packets a connection sends and receives to a recording.
+// Set it as the Recorder of a Connection, TCPConnection or Server. A Recorder
+// is safe for concurrent use; each record is written with a single Write.
+type Recorder struct {
+	// Clock timestamps the records. It defaults to the system clock.
+	Clock Clock
+
+	mu     sync.Mutex
+	w      io.Writer
+	format RecordFormat
+	header bool
+	buf    []byte
+	err    error
+}
+
+// NewRecorder returns a Recorder writing to `w` in the given format.
+func NewRecorder(w io.Writer, format RecordFormat) *Recorder {
+	return &Recorder{w: w, format: format}
+}
+
+// Record writes a packet that was received from or sent to `addr`. After a
+// write fails, the Recorder drops all further records and returns the error.
+// Record does nothing on a nil Recorder.
+func (r *Recorder) Record(dir Direction, addr net.Addr, data []byte) error {
+	if r == nil {
+		return nil
+	}
+	rec := Record{Direction: dir, Data: data}
+	if r.Clock != nil {
+		rec.Time = r.Clock.Now()
+	} else {
+		rec.Time = time.Now()
+	}
+	if addr != nil {
+		rec.Network, rec.Addr = addr.Network(), addr.String()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	buf := r.buf[:0]
+	if r.format == RecordBinary && !r.header {
+		buf = append(buf, recordMagic...)
+	}
+	var err error
+	buf, err = r.appendRecord(buf, &rec)
+	if err == nil {
+		_, err = r.w.Write(buf)
+	}
+	if err != nil {
+		r.err = err
+		return err
+	}
+	r.header = true
+	r.buf = buf
+	return nil
+}
+
+// RecordPacket encodes and writes a packet that was received from or sent to
+// `addr`.
+func (r *Recorder) RecordPacket(dir Direction, addr net.Addr, packet Packet) error {
+	if r == nil {
+		return nil
+	}
+	data, err := packet.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return r.Record(dir, addr, data)
+}
+
+// Err returns the error that stopped the Recorder, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// appendRecord appends `rec` in the Recorder's format to `dst`.
+func (r *Recorder) appendRecord(dst []byte, rec *Record) ([]byte, error) {
+	switch r.format {
+	case RecordBinary:
+		if len(rec.Network) > 255 || len(rec.Addr) > 255 {
+			return nil, fmt.Errorf("peer address too long: %v", rec.Addr)
+		}
+		dst = binary.BigEndian.AppendUint64(dst, uint64(rec.Time.UnixNano()))
+		dst = append(dst, byte(rec.Direction), byte(len(rec.Network)))
+		dst = append(dst, rec.Network...)
+		dst = append(dst, byte(len(rec.Addr)))
+		dst = append(dst, rec.Addr...)
+		dst = binary.BigEndian.AppendUint32(dst, uint32(len(rec.Data)))
+		return append(dst, rec.Data...), nil
+
+	case RecordJSON:
+		jr := jsonRecord{
+			Time:      rec.Time,
+			Direction: rec.Direction.String(),
+			Network:   rec.Network,
+			Addr:      rec.Addr,
+			Data:      rec.Data,
+		}
+		if p, err := rec.Packet(); err == nil {
+			if msg, ok := p.(*Message); ok {
+				jr.Text = msg.String()
+			}
+		}
+		b, err := json.Marshal(&jr)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, b...)
+		return append(dst, '\n'), nil
+	}
+	return nil, fmt.Errorf("unknown record format: %d", r.format)
+}
+
+// RecordReader reads the records of a recording in either format.
+type RecordReader struct {
+	r      *bufio.Reader
+	format RecordFormat
+	init   bool
+}
+
+// NewRecordReader returns a RecordReader for `r`. The format is detected from
+// the first bytes of the recording.
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next record, or io.EOF at the end of the recording.
+func (rr *RecordReader) Next() (*Record, error) {
+	if !rr.init {
+		magic, err := rr.r.Peek(len(recordMagic))
+		if err != nil && len(magic) == 0 {
+			return nil, err
+		}
+		if bytes.Equal(magic, []byte(recordMagic)) {
+			rr.format = RecordBinary
+			rr.r.Discard(len(recordMagic))
+		} else {
+			rr.format = RecordJSON
+		}
+		rr.init = true
+	}
+	if rr.format == RecordBinary {
+		return rr.nextBinary()
+	}
+	return rr.nextJSON()
+}
+
+func (rr *RecordReader) nextBinary() (*Record, error) {
+	var head [10]byte
+	if _, err := io.ReadFull(rr.r, head[:]); err != nil {
+		return nil, err
+	}
+	rec := &Record{
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(head[:8]))),
+		Direction: Direction(head[8]),
+	}
+
+	network := make([]byte, head[9])
+	if _, err := io.ReadFull(rr.r, network); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	n, err := rr.r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	addr := make([]byte, n)
+	if _, err := io.ReadFull(rr.r, addr); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(rr.r, size[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length > maxPacketSize*16 {
+		return nil, fmt.Errorf("record too large: %d bytes", length)
+	}
+	rec.Network, rec.Addr = string(network), string(addr)
+	rec.Data = make([]byte, length)
+	if _, err := io.ReadFull(rr.r, rec.Data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return rec, nil
+}
+
+func (rr *RecordReader) nextJSON() (*Record, error) {
+	for {
+		line, err := rr.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		var jr jsonRecord
+		if err := json.Unmarshal(line, &jr); err != nil {
+			return nil, err
+		}
+		rec := &Record{Time: jr.Time, Network: jr.Network, Addr: jr.Addr, Data: jr.Data}
+		switch jr.Direction {
+		case "in":
+			rec.Direction = DirectionIn
+		case "out":
+			rec.Direction = DirectionOut
+		default:
+			return nil, fmt.Errorf("unknown direction: %q", jr.Direction)
+		}
+		return rec, nil
+	}
+}
+
+// unexpectedEOF turns io.EOF in the middle of a record into
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// recordAddr is the peer address of a replayed packet.
+type recordAddr struct {
+	network, addr string
+}
+
+func (a recordAddr) Network() string { return a.network }
+func (a recordAddr) String() string  { return a.addr }
+
+// Player replays a recording into a Dispatcher, keeping the time between the
+// packets.
+type Player struct {
+	Dispatcher Dispatcher
+	// Rate speeds up playback: 2 plays twice as fast as recorded. Zero plays
+	// in real time, and a negative rate plays without waiting.
+	Rate float64
+	// Outgoing replays sent packets as well. By default, only received
+	// packets are replayed.
+	Outgoing bool
+	// ErrorHandler, if set, is called for every recorded packet that cannot
+	// be decoded. Such packets are skipped.
+	ErrorHandler ErrorHandlerFunc
+	// Clock paces playback. It defaults to the system clock.
+	Clock Clock
+}
+
+// Play replays the records of `r` until the end of the recording, or until
+// `ctx` is cancelled. Packets are dispatched on the calling goroutine.
+func (p *Player) Play(ctx context.Context, r *RecordReader) error {
+	clock := p.Clock
+	if clock == nil {
+		clock = SystemClock()
+	}
+	rate := p.Rate
+	if rate == 0 {
+		rate = 1
+	}
+
+	var first time.Time
+	var start time.Time
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if rec.Direction == DirectionOut && !p.Outgoing {
+			continue
+		}
+
+		if first.IsZero() {
+			first, start = rec.Time, clock.Now()
+		} else if rate > 0 {
+			at := start.Add(time.Duration(float64(rec.Time.Sub(first)) / rate))
+			if err := sleep(ctx, clock, at.Sub(clock.Now())); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		addr := recordAddr{rec.Network, rec.Addr}
+		packet, err := rec.Packet()
+		if err != nil {
+			if p.ErrorHandler != nil {
+				p.ErrorHandler(err, addr)
+			}
+			continue
+		}
+		p.Dispatcher.Dispatch(packet, addr)
+	}
+}
+
+// sleep waits for `d` to pass on `clock`, or for `ctx` to be cancelled.
+func sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	done := make(chan struct{})
+	t := clock.AfterFunc(d, func() { close(done) })
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	}
+}
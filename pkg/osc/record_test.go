@@ -0,0 +1,253 @@
+package osc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chanDispatcher sends every dispatched packet on a channel.
+type chanDispatcher chan Packet
+
+func (d chanDispatcher) Dispatch(packet Packet, _ net.Addr) {
+	d <- packet
+}
+
+// next returns the address of the next dispatched message.
+func (d chanDispatcher) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case p := <-d:
+		return p.(*Message).Address
+	case <-time.After(time.Second):
+		t.Fatal("nothing dispatched")
+		return ""
+	}
+}
+
+// none tests that nothing is dispatched for a while.
+func (d chanDispatcher) none(t *testing.T) {
+	t.Helper()
+	select {
+	case p := <-d:
+		t.Fatalf("dispatched %v", p)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+var recordStart = time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+
+// record returns a recording in `format` of a message for each address.
+// The messages are a second apart; addresses starting with "out" are sent
+// packets.
+func record(t *testing.T, format RecordFormat, addrs ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	clock := NewFakeClock(recordStart)
+	r := NewRecorder(&buf, format)
+	r.Clock = clock
+	peer := &net.UDPAddr{IP: net.IPv4(10, 101, 100, 101), Port: 8000}
+	for _, addr := range addrs {
+		dir := DirectionIn
+		if strings.HasPrefix(addr, "/out") {
+			dir = DirectionOut
+		}
+		if err := r.RecordPacket(dir, peer, NewMessage(addr, int32(1))); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second)
+	}
+	return buf.Bytes()
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	for _, format := range []RecordFormat{RecordBinary, RecordJSON} {
+		data := record(t, format, "/eos/out/ping", "/out/eos/ping")
+		if format == RecordJSON && !strings.Contains(string(data), `"text":"/eos/out/ping ,i 1"`) {
+			t.Errorf("JSON recording without text: %s", data)
+		}
+
+		rr := NewRecordReader(bytes.NewReader(data))
+		for i, want := range []struct {
+			at  time.Time
+			dir Direction
+			msg string
+		}{
+			{at: recordStart, dir: DirectionIn, msg: "/eos/out/ping ,i 1"},
+			{at: recordStart.Add(time.Second), dir: DirectionOut, msg: "/out/eos/ping ,i 1"},
+		} {
+			rec, err := rr.Next()
+			if err != nil {
+				t.Fatalf("format %d, record %d: %v", format, i, err)
+			}
+			if !rec.Time.Equal(want.at) || rec.Direction != want.dir || rec.Network != "udp" || rec.Addr != "10.101.100.101:8000" {
+				t.Errorf("format %d, record %d: %v %v %s %s", format, i, rec.Time, rec.Direction, rec.Network, rec.Addr)
+			}
+			p, err := rec.Packet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.(*Message).String(); got != want.msg {
+				t.Errorf("format %d, record %d: packet %s", format, i, got)
+			}
+		}
+		if _, err := rr.Next(); err != io.EOF {
+			t.Errorf("format %d: Next at the end = %v; want io.EOF", format, err)
+		}
+	}
+}
+
+func TestRecordTruncated(t *testing.T) {
+	data := record(t, RecordBinary, "/eos/out/ping")
+	if _, err := NewRecordReader(bytes.NewReader(data[:len(recordMagic)])).Next(); err != io.EOF {
+		t.Errorf("Next of an empty recording = %v; want io.EOF", err)
+	}
+	for n := len(recordMagic) + 1; n < len(data); n++ {
+		if _, err := NewRecordReader(bytes.NewReader(data[:n])).Next(); err != io.ErrUnexpectedEOF {
+			t.Fatalf("Next of %d bytes out of %d = %v; want io.ErrUnexpectedEOF", n, len(data), err)
+		}
+	}
+
+	// A truncated line is not valid JSON
+	data = record(t, RecordJSON, "/eos/out/ping")
+	if _, err := NewRecordReader(bytes.NewReader(data[:len(data)/2])).Next(); err == nil {
+		t.Error("Next of a truncated JSON line: no error")
+	}
+}
+
+func TestRecorderError(t *testing.T) {
+	r := NewRecorder(failingWriter{}, RecordBinary)
+	if err := r.Record(DirectionIn, nil, nil); err == nil {
+		t.Fatal("Record: no error")
+	}
+	if err := r.Record(DirectionIn, nil, nil); err == nil || err != r.Err() {
+		t.Errorf("Record after an error = %v; want %v", err, r.Err())
+	}
+
+	var nilRecorder *Recorder
+	if err := nilRecorder.Record(DirectionIn, nil, nil); err != nil {
+		t.Errorf("Record on a nil Recorder = %v", err)
+	}
+}
+
+// waitTimer waits for Play to start waiting on `clock`.
+func waitTimer(t *testing.T, clock *FakeClock) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); clock.PendingTimers() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Play is not waiting")
+		}
+	}
+}
+
+func TestPlayerRate(t *testing.T) {
+	data := record(t, RecordBinary, "/1", "/out/2", "/3", "/4")
+	clock := NewFakeClock(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+	d := make(chanDispatcher, 4)
+	p := &Player{Dispatcher: d, Rate: 2, Clock: clock}
+	played := make(chan error, 1)
+	go func() { played <- p.Play(context.Background(), NewRecordReader(bytes.NewReader(data))) }()
+
+	// Twice as fast, without the sent packet
+	if got := d.next(t); got != "/1" {
+		t.Fatalf("dispatched %s; want /1", got)
+	}
+	waitTimer(t, clock)
+	clock.Advance(999 * time.Millisecond)
+	d.none(t)
+	clock.Advance(time.Millisecond)
+	if got := d.next(t); got != "/3" {
+		t.Fatalf("dispatched %s; want /3", got)
+	}
+	waitTimer(t, clock)
+	clock.Advance(500 * time.Millisecond)
+	if got := d.next(t); got != "/4" {
+		t.Fatalf("dispatched %s; want /4", got)
+	}
+	if err := <-played; err != nil {
+		t.Errorf("Play = %v", err)
+	}
+}
+
+func TestPlayerOutgoing(t *testing.T) {
+	data := record(t, RecordJSON, "/1", "/out/2", "/3")
+	clock := NewFakeClock(recordStart)
+	d := make(chanDispatcher, 3)
+	p := &Player{Dispatcher: d, Rate: -1, Outgoing: true, Clock: clock}
+
+	// A negative rate does not wait
+	if err := p.Play(context.Background(), NewRecordReader(bytes.NewReader(data))); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/1", "/out/2", "/3"} {
+		if got := d.next(t); got != want {
+			t.Errorf("dispatched %s; want %s", got, want)
+		}
+	}
+	if clock.PendingTimers() != 0 {
+		t.Error("Play waited")
+	}
+}
+
+func TestPlayerBadPacket(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRecorder(&buf, RecordBinary)
+	r.Record(DirectionIn, nil, []byte("not OSC"))
+	r.RecordPacket(DirectionIn, nil, NewMessage("/1"))
+
+	var errs int
+	d := make(chanDispatcher, 1)
+	p := &Player{
+		Dispatcher:   d,
+		Rate:         -1,
+		ErrorHandler: func(error, net.Addr) { errs++ },
+	}
+	if err := p.Play(context.Background(), NewRecordReader(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if errs != 1 {
+		t.Errorf("%d errors reported; want 1", errs)
+	}
+	if got := d.next(t); got != "/1" {
+		t.Errorf("dispatched %s; want /1", got)
+	}
+}
+
+func TestPlayerCancel(t *testing.T) {
+	data := record(t, RecordBinary, "/1", "/2")
+	clock := NewFakeClock(recordStart)
+	d := make(chanDispatcher, 2)
+	p := &Player{Dispatcher: d, Clock: clock}
+	ctx, cancel := context.WithCancel(context.Background())
+	played := make(chan error, 1)
+	go func() { played <- p.Play(ctx, NewRecordReader(bytes.NewReader(data))) }()
+
+	d.next(t)
+	waitTimer(t, clock)
+	cancel()
+	select {
+	case err := <-played:
+		if err != context.Canceled {
+			t.Errorf("Play = %v; want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Play did not return")
+	}
+	if clock.PendingTimers() != 0 {
+		t.Error("timer not stopped")
+	}
+	clock.Advance(time.Second)
+	d.none(t)
+}
@@ -0,0 +1,216 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"container/heap"
+	"net"
+	"sync"
+	"time"
+)
+
+// LatePolicy selects what a Scheduler does with a bundle whose time tag is
+// already in the past when it arrives.
+type LatePolicy int
+
+const (
+	// LateDispatch dispatches late bundles immediately.
+	LateDispatch LatePolicy = iota
+	// LateDrop silently drops late bundles.
+	LateDrop
+	// LateReport drops late bundles and passes them to the OnLate callback
+	// of the Scheduler.
+	LateReport
+)
+
+// Scheduler dispatches the messages of OSC bundles at the time given by their
+// time tags. All future bundles wait in a single priority queue served by one
+// timer, and bundles due at the same time are dispatched in the order they
+// were scheduled.
+//
+// A bundle is dispatched atomically: its messages, and those of nested bundles
+// that are due no later than the bundle itself, are dispatched one after
+// another without messages of any other queued bundle in between. Nested
+// bundles with a later time tag are queued on their own. Bundles that are due
+// on arrival are dispatched on the goroutine that scheduled them. Handlers
+// must therefore not schedule bundles on the Scheduler that called them.
+type Scheduler struct {
+	// LatePolicy selects what happens to bundles that arrive late.
+	LatePolicy LatePolicy
+	// Tolerance is how far in the past a time tag may be before the bundle
+	// counts as late. Bundles within the tolerance are dispatched immediately.
+	Tolerance time.Duration
+	// OnLate is called with every bundle dropped under LateReport, and with
+	// how late it was.
+	OnLate func(bundle *Bundle, addr net.Addr, late time.Duration)
+	// Clock is the source of time. It defaults to the system clock and must
+	// not be changed once bundles have been scheduled.
+	Clock Clock
+
+	dispatch func(msg *Message, addr net.Addr)
+	mu       sync.Mutex
+	runMu    sync.Mutex
+	queue    bundleQueue
+	timer    ClockTimer
+	seq      uint64
+}
+
+// NewScheduler returns a Scheduler that hands the messages of due bundles to
+// `dispatch`.
+func NewScheduler(dispatch func(msg *Message, addr net.Addr)) *Scheduler {
+	return &Scheduler{dispatch: dispatch}
+}
+
+// Schedule dispatches the bundle when its time tag is due. Bundles tagged
+// "immediately", and bundles that are due already, are dispatched before
+// Schedule returns.
+func (s *Scheduler) Schedule(b *Bundle, addr net.Addr) {
+	now := s.clock().Now()
+	if b.Timetag.TimeTag() > 1 {
+		at := b.Timetag.Time()
+		if at.After(now) {
+			s.push(b, addr, at)
+			return
+		}
+		if late := now.Sub(at); late > s.Tolerance {
+			switch s.LatePolicy {
+			case LateDrop:
+				return
+			case LateReport:
+				if s.OnLate != nil {
+					s.OnLate(b, addr, late)
+				}
+				return
+			}
+		}
+	}
+
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	s.run(b, addr, now)
+}
+
+// Pending returns the number of bundles waiting for their time tag.
+func (s *Scheduler) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Stop drops all pending bundles.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = nil
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// run dispatches the messages of a due bundle and of its nested bundles that
+// are due by `at`. Later nested bundles are queued.
+func (s *Scheduler) run(b *Bundle, addr net.Addr, at time.Time) {
+	for _, msg := range b.Messages {
+		s.dispatch(msg, addr)
+	}
+	for _, nested := range b.Bundles {
+		if nested.Timetag.TimeTag() > 1 && nested.Timetag.Time().After(at) {
+			s.push(nested, addr, nested.Timetag.Time())
+			continue
+		}
+		s.run(nested, addr, at)
+	}
+}
+
+// push adds a future bundle to the queue and rearms the timer if the bundle
+// is the next one due.
+func (s *Scheduler) push(b *Bundle, addr net.Addr, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	heap.Push(&s.queue, &scheduledBundle{bundle: b, addr: addr, at: at, seq: s.seq})
+	if s.queue[0].seq == s.seq {
+		s.arm(at)
+	}
+}
+
+// clock returns the Clock of the scheduler.
+func (s *Scheduler) clock() Clock {
+	if s.Clock == nil {
+		return SystemClock()
+	}
+	return s.Clock
+}
+
+// arm sets the timer to fire at `at`. The caller holds mu.
+func (s *Scheduler) arm(at time.Time) {
+	clock := s.clock()
+	d := at.Sub(clock.Now())
+	if s.timer == nil {
+		s.timer = clock.AfterFunc(d, s.fire)
+		return
+	}
+	s.timer.Reset(d)
+}
+
+// fire dispatches all bundles that are due and rearms the timer for the next
+// one.
+func (s *Scheduler) fire() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	for {
+		s.mu.Lock()
+		now := s.clock().Now()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		if next := s.queue[0]; next.at.After(now) {
+			s.arm(next.at)
+			s.mu.Unlock()
+			return
+		}
+		due := heap.Pop(&s.queue).(*scheduledBundle)
+		s.mu.Unlock()
+
+		s.run(due.bundle, due.addr, due.at)
+	}
+}
+
+// scheduledBundle is an entry of the bundle queue.
+type scheduledBundle struct {
+	bundle *Bundle
+	addr   net.Addr
+	at     time.Time
+	seq    uint64
+}
+
+// bundleQueue is a min-heap of scheduled bundles ordered by time, then by the
+// order they were scheduled in.
+type bundleQueue []*scheduledBundle
+
+func (q bundleQueue) Len() int { return len(q) }
+
+func (q bundleQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q bundleQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *bundleQueue) Push(x interface{}) {
+	*q = append(*q, x.(*scheduledBundle))
+}
+
+func (q *bundleQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return item
+}
@@ -0,0 +1,197 @@
+package osc
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorded collects the addresses of the dispatched messages.
+type recorded struct {
+	mu    sync.Mutex
+	addrs []string
+}
+
+func (r *recorded) dispatch(msg *Message, _ net.Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs = append(r.addrs, msg.Address)
+}
+
+// take returns the addresses dispatched since the last call.
+func (r *recorded) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	addrs := r.addrs
+	r.addrs = nil
+	return addrs
+}
+
+// bundleAt returns a bundle due at `at` with a message for each address.
+func bundleAt(at time.Time, addrs ...string) *Bundle {
+	b := NewBundle(at)
+	for _, addr := range addrs {
+		b.Append(NewMessage(addr))
+	}
+	return b
+}
+
+func TestScheduler(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	immediate := &Bundle{Timetag: *NewTimetagFromTimetag(1)}
+	immediate.Append(NewMessage("/now"))
+
+	for _, tt := range []struct {
+		name    string
+		bundles []*Bundle
+		// steps advances the clock and lists what is dispatched each time
+		steps []time.Duration
+		want  [][]string
+	}{
+		{
+			name:    "immediately",
+			bundles: []*Bundle{immediate},
+			want:    [][]string{{"/now"}},
+		},
+		{
+			name:    "in time order",
+			bundles: []*Bundle{bundleAt(start.Add(2*time.Second), "/b"), bundleAt(start.Add(time.Second), "/a")},
+			steps:   []time.Duration{time.Second - 1, 1, time.Second},
+			want:    [][]string{nil, nil, {"/a"}, {"/b"}},
+		},
+		{
+			name:    "same time in scheduling order",
+			bundles: []*Bundle{bundleAt(start.Add(time.Second), "/a", "/b"), bundleAt(start.Add(time.Second), "/c")},
+			steps:   []time.Duration{time.Second},
+			want:    [][]string{nil, {"/a", "/b", "/c"}},
+		},
+		{
+			name: "nested later bundle is queued",
+			bundles: func() []*Bundle {
+				b := bundleAt(start.Add(time.Second), "/outer")
+				b.Append(bundleAt(start, "/early"))
+				b.Append(bundleAt(start.Add(2*time.Second), "/late"))
+				return []*Bundle{b}
+			}(),
+			steps: []time.Duration{time.Second, time.Second},
+			want:  [][]string{nil, {"/outer", "/early"}, {"/late"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(start)
+			r := &recorded{}
+			s := NewScheduler(r.dispatch)
+			s.Clock = clock
+
+			for _, b := range tt.bundles {
+				s.Schedule(b, nil)
+			}
+			got := [][]string{r.take()}
+			for _, d := range tt.steps {
+				clock.Advance(d)
+				got = append(got, r.take())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dispatched %q; want %q", got, tt.want)
+			}
+			if n := s.Pending(); n != 0 {
+				t.Errorf("%d bundles pending", n)
+			}
+		})
+	}
+}
+
+func TestSchedulerLatePolicy(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name   string
+		policy LatePolicy
+		late   time.Duration
+		want   []string
+		report bool
+	}{
+		{name: "dispatch", policy: LateDispatch, late: time.Second, want: []string{"/late"}},
+		{name: "drop", policy: LateDrop, late: time.Second},
+		{name: "report", policy: LateReport, late: time.Second, report: true},
+		{name: "within tolerance", policy: LateDrop, late: 10 * time.Millisecond, want: []string{"/late"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(start)
+			r := &recorded{}
+			s := NewScheduler(r.dispatch)
+			s.Clock = clock
+			s.LatePolicy = tt.policy
+			s.Tolerance = 50 * time.Millisecond
+			var reported time.Duration
+			s.OnLate = func(_ *Bundle, _ net.Addr, late time.Duration) {
+				reported = late
+			}
+
+			s.Schedule(bundleAt(start.Add(-tt.late), "/late"), nil)
+			if got := r.take(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dispatched %q; want %q", got, tt.want)
+			}
+			if tt.report && reported != tt.late {
+				t.Errorf("reported %v late; want %v", reported, tt.late)
+			}
+			if !tt.report && reported != 0 {
+				t.Errorf("reported %v late", reported)
+			}
+		})
+	}
+}
+
+func TestSchedulerStop(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	r := &recorded{}
+	s := NewScheduler(r.dispatch)
+	s.Clock = clock
+
+	s.Schedule(bundleAt(start.Add(time.Second), "/a"), nil)
+	s.Schedule(bundleAt(start.Add(2*time.Second), "/b"), nil)
+	if n := s.Pending(); n != 2 {
+		t.Fatalf("%d bundles pending; want 2", n)
+	}
+	s.Stop()
+	clock.Advance(time.Minute)
+	if got := r.take(); got != nil {
+		t.Errorf("dispatched %q after Stop", got)
+	}
+
+	// The scheduler still works after Stop
+	s.Schedule(bundleAt(clock.Now().Add(time.Second), "/c"), nil)
+	clock.Advance(time.Second)
+	if got := r.take(); !reflect.DeepEqual(got, []string{"/c"}) {
+		t.Errorf("dispatched %q; want [/c]", got)
+	}
+}
+
+func TestShutdownStopsScheduler(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	d := NewTrieDispatcher()
+	d.Scheduler().Clock = clock
+	fired := false
+	if err := d.AddMsgHandler("/later", func(*Message, net.Addr) { fired = true }); err != nil {
+		t.Fatal(err)
+	}
+
+	var state serveState
+	_, end, err := state.begin(t.Context(), d, DispatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Dispatch(bundleAt(start.Add(time.Second), "/later"), nil)
+	end()
+
+	if err := state.shutdown(t.Context(), func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if fired {
+		t.Error("queued bundle dispatched after shutdown")
+	}
+}
@@ -3,20 +3,33 @@
 package osc
 
 import (
-	"bufio"
-	"bytes"
+	"errors"
 	"net"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Server represents an OSC server. The server listens on Address and Port for
 // incoming OSC packets and bundles.
 type Server struct {
-	Addr        string
-	Dispatcher  Dispatcher
+	Addr       string
+	Dispatcher Dispatcher
+	// ReadTimeout, if set, bounds every read. Serve keeps waiting after a
+	// timeout, while ServeTCP closes the connection that timed out.
 	ReadTimeout time.Duration
-	close       func() error
+	// ErrorHandler, if set, is called for every received packet that cannot
+	// be decoded. Such packets are dropped and the server keeps serving.
+	ErrorHandler ErrorHandlerFunc
+	// DispatchOptions controls how received packets are handed to the
+	// Dispatcher.
+	DispatchOptions DispatchOptions
+	// DecodeOptions controls how received packets are decoded.
+	DecodeOptions DecodeOptions
+	// Recorder, if set, records every packet received.
+	Recorder *Recorder
+	close    func() error
+	counters dispatchCounters
 }
 
 // ListenAndServe retrieves incoming OSC packets and dispatches the retrieved
@@ -39,14 +52,32 @@ func (s *Server) ListenAndServe() error {
 }
 
 // Serve retrieves incoming OSC packets from the given connection and dispatches
-// retrieved OSC packets. If something goes wrong an error is returned.
+// retrieved OSC packets until the connection is closed, e.g. with
+// CloseConnection. Packets that cannot be decoded are reported to ErrorHandler
+// and skipped. If something else goes wrong an error is returned.
 func (s *Server) Serve(c net.PacketConn) error {
+	pool := s.newDispatchPool()
+	defer pool.close()
+
 	var tempDelay time.Duration
 	for {
 		msg, addr, err := s.readFromConnection(c)
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			// A packet was read, but it could not be decoded
+			if addr != nil {
+				if s.ErrorHandler != nil {
+					s.ErrorHandler(err, addr)
+				}
+				continue
+			}
 			// This was looking at ne.Temporary() which is deprecated
-			if _, ok := err.(net.Error); ok {
+			if ne, ok := err.(net.Error); ok {
+				if ne.Timeout() {
+					continue
+				}
 				if tempDelay == 0 {
 					tempDelay = 5 * time.Millisecond
 				} else {
@@ -61,10 +92,21 @@ func (s *Server) Serve(c net.PacketConn) error {
 			return err
 		}
 		tempDelay = 0
-		go s.Dispatcher.Dispatch(msg, addr)
+		pool.submit(msg, addr)
 	}
 }
 
+// DispatchStats reports how the packets received so far were dispatched.
+func (s *Server) DispatchStats() DispatchStats {
+	return s.counters.stats()
+}
+
+// newDispatchPool returns a pool dispatching to the server's Dispatcher. The
+// server does not wait for dispatches when it stops serving.
+func (s *Server) newDispatchPool() *dispatchPool {
+	return newDispatchPool(s.Dispatcher, s.DispatchOptions, &s.counters, &sync.WaitGroup{})
+}
+
 // CloseConnection forcibly closes a server's connection.
 //
 // This causes a "use of closed network connection" error the next time the
@@ -86,12 +128,15 @@ func (s *Server) CloseConnection() error {
 	return nil
 }
 
-// ReceivePacket listens for incoming OSC packets and returns the packet if one is received.
+// ReceivePacket listens for incoming OSC packets and returns the packet if one
+// is received. If the packet cannot be decoded, the error is returned with the
+// address it came from.
 func (s *Server) ReceivePacket(c net.PacketConn) (Packet, net.Addr, error) {
 	return s.readFromConnection(c)
 }
 
-// readFromConnection retrieves OSC packets.
+// readFromConnection retrieves OSC packets. The address is only returned
+// with an error if the packet was read but could not be decoded.
 func (s *Server) readFromConnection(c net.PacketConn) (Packet, net.Addr, error) {
 	if s.ReadTimeout != 0 {
 		if err := c.SetReadDeadline(time.Now().Add(s.ReadTimeout)); err != nil {
@@ -99,15 +144,18 @@ func (s *Server) readFromConnection(c net.PacketConn) (Packet, net.Addr, error)
 		}
 	}
 
-	data := make([]byte, 65535)
-	n, addr, err := c.ReadFrom(data)
+	buf := getPacketBuffer()
+	defer putPacketBuffer(buf)
+	n, addr, err := c.ReadFrom(*buf)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	p, err := readPacket(bufio.NewReader(bytes.NewBuffer(data[0:n])))
+	s.Recorder.Record(DirectionIn, addr, (*buf)[:n])
+
+	p, err := s.DecodeOptions.ParsePacket((*buf)[:n])
 	if err != nil {
-		return nil, nil, err
+		return nil, addr, err
 	}
 	return p, addr, nil
 }
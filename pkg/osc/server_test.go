@@ -0,0 +1,117 @@
+package osc
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// sendUDP sends `data` to `addr` from a new socket, and returns the address
+// it was sent from.
+func sendUDP(t *testing.T, addr net.Addr, data []byte) net.Addr {
+	t.Helper()
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	return conn.LocalAddr()
+}
+
+func TestServerErrorHandler(t *testing.T) {
+	ln, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newBlockingDispatcher(&dispatchCounters{})
+	close(d.release)
+	errs := make(chan net.Addr, 1)
+	s := &Server{
+		Dispatcher:      d,
+		DispatchOptions: DispatchOptions{Mode: DispatchSerial},
+		ErrorHandler: func(err error, addr net.Addr) {
+			errs <- addr
+		},
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ln) }()
+
+	// The bad packet is reported, and the next one is dispatched
+	from := sendUDP(t, ln.LocalAddr(), []byte("not OSC"))
+	select {
+	case addr := <-errs:
+		if addr.String() != from.String() {
+			t.Errorf("error from %v; want %v", addr, from)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ErrorHandler not called")
+	}
+	sendUDP(t, ln.LocalAddr(), mustMarshal(t, NewMessage("/eos/ping")))
+	d.wait(t, "/eos/ping")
+
+	// Closing the connection stops serving
+	ln.Close()
+	select {
+	case err := <-served:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Serve = %v; want net.ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the connection was closed")
+	}
+	if stats := s.DispatchStats(); stats.Dispatched != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestServerReadTimeout(t *testing.T) {
+	ln, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Timeouts do not stop serving
+	d := newBlockingDispatcher(&dispatchCounters{})
+	close(d.release)
+	s := &Server{Dispatcher: d, ReadTimeout: time.Millisecond}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ln) }()
+	time.Sleep(20 * time.Millisecond)
+	sendUDP(t, ln.LocalAddr(), mustMarshal(t, NewMessage("/eos/ping")))
+	d.wait(t, "/eos/ping")
+
+	ln.Close()
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the connection was closed")
+	}
+}
+
+func TestServerReceivePacket(t *testing.T) {
+	ln, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s := &Server{ReadTimeout: time.Second}
+	from := sendUDP(t, ln.LocalAddr(), []byte("not OSC"))
+	if _, addr, err := s.ReceivePacket(ln); err == nil || addr == nil || addr.String() != from.String() {
+		t.Errorf("ReceivePacket of a bad packet = %v, %v; want an error from %v", addr, err, from)
+	}
+
+	sendUDP(t, ln.LocalAddr(), mustMarshal(t, NewMessage("/eos/ping")))
+	p, _, err := s.ReceivePacket(ln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, ok := p.(*Message); !ok || msg.Address != "/eos/ping" {
+		t.Errorf("received %v", p)
+	}
+}
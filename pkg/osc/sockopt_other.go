@@ -0,0 +1,21 @@
+//go:build !unix
+
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+var errMulticastUnsupported = errors.New("osc: multicast is not supported on this platform")
+
+func setReuseAddr(_, _ string, _ syscall.RawConn) error {
+	return errMulticastUnsupported
+}
+
+func setMulticastOptions(_ *net.UDPConn, _ *MulticastConfig, _ *net.Interface) error {
+	return errMulticastUnsupported
+}
@@ -0,0 +1,72 @@
+//go:build unix
+
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"net"
+	"syscall"
+)
+
+// setReuseAddr allows other sockets on this host to bind the same port, which
+// is needed for several multicast listeners on one host.
+func setReuseAddr(_, _ string, c syscall.RawConn) error {
+	var serr error
+	err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// setMulticastOptions joins the configured groups on `ifi`, or on the system
+// default interface if nil, and sets the outgoing interface, TTL and loopback.
+func setMulticastOptions(conn *net.UDPConn, cfg *MulticastConfig, ifi *net.Interface) error {
+	var local [4]byte
+	if ifi != nil {
+		ip, err := interfaceIPv4(ifi)
+		if err != nil {
+			return err
+		}
+		copy(local[:], ip)
+	}
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		s := int(fd)
+		for _, g := range cfg.Groups {
+			mreq := &syscall.IPMreq{Interface: local}
+			copy(mreq.Multiaddr[:], net.ParseIP(g).To4())
+			if serr = syscall.SetsockoptIPMreq(s, syscall.IPPROTO_IP, syscall.IP_ADD_MEMBERSHIP, mreq); serr != nil {
+				return
+			}
+		}
+		if ifi != nil {
+			if serr = syscall.SetsockoptInet4Addr(s, syscall.IPPROTO_IP, syscall.IP_MULTICAST_IF, local); serr != nil {
+				return
+			}
+		}
+		if cfg.TTL > 0 {
+			if serr = syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, cfg.TTL); serr != nil {
+				return
+			}
+		}
+		loop := 0
+		if cfg.Loopback {
+			loop = 1
+		}
+		serr = syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_MULTICAST_LOOP, loop)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
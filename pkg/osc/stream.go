@@ -0,0 +1,241 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Framing selects how OSC packets are delimited on a stream transport such as
+// TCP. UDP datagrams need no framing, as every datagram is a single packet.
+type Framing int
+
+const (
+	// FramingSLIP frames packets with SLIP (RFC 1055) double-END encoding, as
+	// required by the OSC 1.1 specification.
+	FramingSLIP Framing = iota
+	// FramingLengthPrefix precedes every packet with its size as a 32-bit
+	// big-endian integer, as described by the OSC 1.0 specification.
+	FramingLengthPrefix
+)
+
+const (
+	slipEnd    = 0xC0
+	slipEsc    = 0xDB
+	slipEscEnd = 0xDC
+	slipEscEsc = 0xDD
+
+	// defaultMaxStreamPacketSize is the largest packet a StreamDecoder accepts
+	// unless told otherwise.
+	defaultMaxStreamPacketSize = 1 << 20
+)
+
+// String implements the fmt.Stringer interface.
+func (f Framing) String() string {
+	switch f {
+	case FramingSLIP:
+		return "slip"
+	case FramingLengthPrefix:
+		return "length-prefix"
+	default:
+		return fmt.Sprintf("Framing(%d)", int(f))
+	}
+}
+
+////
+// StreamEncoder
+////
+
+// StreamEncoder writes framed OSC packets to a stream.
+type StreamEncoder struct {
+	w       io.Writer
+	framing Framing
+}
+
+// NewStreamEncoder returns a StreamEncoder that writes packets to `w` using the
+// given framing.
+func NewStreamEncoder(w io.Writer, framing Framing) *StreamEncoder {
+	return &StreamEncoder{w: w, framing: framing}
+}
+
+// Encode serializes the packet and writes it to the stream as a single frame.
+func (e *StreamEncoder) Encode(packet Packet) error {
+	data, err := packet.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	frame, err := encodeFrame(data, e.framing)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(frame)
+	return err
+}
+
+// encodeFrame wraps a serialized packet in the given framing.
+func encodeFrame(data []byte, framing Framing) ([]byte, error) {
+	switch framing {
+	case FramingSLIP:
+		frame := make([]byte, 0, len(data)+2)
+		frame = append(frame, slipEnd)
+		for _, b := range data {
+			switch b {
+			case slipEnd:
+				frame = append(frame, slipEsc, slipEscEnd)
+			case slipEsc:
+				frame = append(frame, slipEsc, slipEscEsc)
+			default:
+				frame = append(frame, b)
+			}
+		}
+		return append(frame, slipEnd), nil
+
+	case FramingLengthPrefix:
+		frame := make([]byte, 4, len(data)+4)
+		binary.BigEndian.PutUint32(frame, uint32(len(data)))
+		return append(frame, data...), nil
+	}
+	return nil, fmt.Errorf("unsupported framing: %v", framing)
+}
+
+////
+// StreamDecoder
+////
+
+// StreamDecoder reads framed OSC packets from a stream.
+type StreamDecoder struct {
+	r       *bufio.Reader
+	framing Framing
+	// MaxPacketSize is the largest frame accepted. Larger frames are reported
+	// as an error.
+	MaxPacketSize int
+	// DecodeOptions controls how Decode parses the frames.
+	DecodeOptions DecodeOptions
+
+	// The frame being read, kept across reads that fail, e.g. on a timeout
+	frame   []byte
+	escaped bool
+	header  [4]byte
+	sized   bool
+	n       int
+}
+
+// NewStreamDecoder returns a StreamDecoder that reads packets from `r` using
+// the given framing.
+func NewStreamDecoder(r io.Reader, framing Framing) *StreamDecoder {
+	return &StreamDecoder{
+		r:             bufio.NewReader(r),
+		framing:       framing,
+		MaxPacketSize: defaultMaxStreamPacketSize,
+	}
+}
+
+// Decode reads the next frame from the stream and parses it as an OSC packet.
+func (d *StreamDecoder) Decode() (Packet, error) {
+	frame, err := d.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return d.DecodeOptions.ParsePacket(frame)
+}
+
+// ReadFrame reads the next frame from the stream and returns its payload with
+// the framing removed. Empty frames are skipped. If reading fails part way
+// through a frame, e.g. on a read deadline, the next call resumes the frame.
+func (d *StreamDecoder) ReadFrame() ([]byte, error) {
+	switch d.framing {
+	case FramingSLIP:
+		return d.readSLIPFrame()
+	case FramingLengthPrefix:
+		return d.readLengthPrefixFrame()
+	}
+	return nil, fmt.Errorf("unsupported framing: %v", d.framing)
+}
+
+// readSLIPFrame reads bytes up to the next END byte and unescapes them.
+func (d *StreamDecoder) readSLIPFrame() ([]byte, error) {
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && (len(d.frame) > 0 || d.escaped) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		switch {
+		case d.escaped:
+			d.escaped = false
+			switch b {
+			case slipEscEnd:
+				b = slipEnd
+			case slipEscEsc:
+				b = slipEsc
+			default:
+				return nil, fmt.Errorf("invalid SLIP escape sequence 0x%02X", b)
+			}
+		case b == slipEsc:
+			d.escaped = true
+			continue
+		case b == slipEnd:
+			// Double-END encoding produces empty frames between packets
+			if len(d.frame) == 0 {
+				continue
+			}
+			frame := d.frame
+			d.frame = nil
+			return frame, nil
+		}
+
+		if len(d.frame) >= d.MaxPacketSize {
+			return nil, fmt.Errorf("SLIP frame exceeds %d bytes", d.MaxPacketSize)
+		}
+		d.frame = append(d.frame, b)
+	}
+}
+
+// readLengthPrefixFrame reads a 32-bit size followed by that many bytes.
+// Zero-length frames are skipped.
+func (d *StreamDecoder) readLengthPrefixFrame() ([]byte, error) {
+	for !d.sized {
+		if err := d.read(d.header[:]); err != nil {
+			return nil, err
+		}
+		d.n = 0
+		size := int32(binary.BigEndian.Uint32(d.header[:]))
+		if size == 0 {
+			continue
+		}
+		if size < 0 || int(size) > d.MaxPacketSize {
+			return nil, fmt.Errorf("invalid frame length %d", size)
+		}
+		d.frame = make([]byte, size)
+		d.sized = true
+	}
+
+	if err := d.read(d.frame); err != nil {
+		return nil, err
+	}
+	frame := d.frame
+	d.frame, d.sized, d.n = nil, false, 0
+	return frame, nil
+}
+
+// read fills `buf` from the stream, continuing after the d.n bytes read by
+// earlier calls.
+func (d *StreamDecoder) read(buf []byte) error {
+	for d.n < len(buf) {
+		n, err := d.r.Read(buf[d.n:])
+		d.n += n
+		if err != nil {
+			if err == io.EOF && (d.n > 0 || d.sized) {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package osc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeFrame(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		framing Framing
+		data    []byte
+		want    []byte
+	}{
+		{
+			name:    "slip",
+			framing: FramingSLIP,
+			data:    []byte("/a\x00\x00"),
+			want:    []byte("\xc0/a\x00\x00\xc0"),
+		},
+		{
+			name:    "slip escapes",
+			framing: FramingSLIP,
+			data:    []byte{slipEnd, 1, slipEsc, 2},
+			want:    []byte{slipEnd, slipEsc, slipEscEnd, 1, slipEsc, slipEscEsc, 2, slipEnd},
+		},
+		{
+			name:    "length prefix",
+			framing: FramingLengthPrefix,
+			data:    []byte("/a\x00\x00"),
+			want:    []byte("\x00\x00\x00\x04/a\x00\x00"),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeFrame(tt.data, tt.framing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("encodeFrame = %q; want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := encodeFrame(nil, Framing(9)); err == nil {
+		t.Error("encodeFrame with an unknown framing: no error")
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		framing Framing
+		stream  []byte
+		want    [][]byte
+		err     error
+	}{
+		{
+			name:    "slip empty frames",
+			framing: FramingSLIP,
+			stream:  []byte("\xc0\xc0a\xc0\xc0\xc0b\xc0"),
+			want:    [][]byte{[]byte("a"), []byte("b")},
+			err:     io.EOF,
+		},
+		{
+			name:    "slip escapes",
+			framing: FramingSLIP,
+			stream:  []byte{slipEnd, slipEsc, slipEscEnd, slipEsc, slipEscEsc, slipEnd},
+			want:    [][]byte{{slipEnd, slipEsc}},
+			err:     io.EOF,
+		},
+		{
+			name:    "slip without leading END",
+			framing: FramingSLIP,
+			stream:  []byte("a\xc0"),
+			want:    [][]byte{[]byte("a")},
+			err:     io.EOF,
+		},
+		{
+			name:    "slip truncated",
+			framing: FramingSLIP,
+			stream:  []byte("\xc0a\xc0bc"),
+			want:    [][]byte{[]byte("a")},
+			err:     io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "length prefix",
+			framing: FramingLengthPrefix,
+			stream:  []byte("\x00\x00\x00\x01a\x00\x00\x00\x00\x00\x00\x00\x02bc"),
+			want:    [][]byte{[]byte("a"), []byte("bc")},
+			err:     io.EOF,
+		},
+		{
+			name:    "length prefix truncated size",
+			framing: FramingLengthPrefix,
+			stream:  []byte("\x00\x00"),
+			err:     io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "length prefix truncated frame",
+			framing: FramingLengthPrefix,
+			stream:  []byte("\x00\x00\x00\x04abc"),
+			err:     io.ErrUnexpectedEOF,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewStreamDecoder(bytes.NewReader(tt.stream), tt.framing)
+			var got [][]byte
+			for {
+				frame, err := d.ReadFrame()
+				if err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Errorf("ReadFrame: %v; want %v", err, tt.err)
+					}
+					break
+				}
+				got = append(got, frame)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frames = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFrameInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		framing Framing
+		stream  []byte
+	}{
+		{name: "slip escape", framing: FramingSLIP, stream: []byte{slipEnd, slipEsc, 'a', slipEnd}},
+		{name: "slip too long", framing: FramingSLIP, stream: []byte("\xc0abcde\xc0")},
+		{name: "length too long", framing: FramingLengthPrefix, stream: []byte("\x00\x00\x00\x05abcde")},
+		{name: "negative length", framing: FramingLengthPrefix, stream: []byte("\xff\xff\xff\xfc")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewStreamDecoder(bytes.NewReader(tt.stream), tt.framing)
+			d.MaxPacketSize = 4
+			if frame, err := d.ReadFrame(); err == nil || err == io.EOF {
+				t.Errorf("ReadFrame = %q, %v; want an error", frame, err)
+			}
+		})
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	packets := []Packet{eosWheelMessage(), eosBundle(), NewMessage("/eos/ping")}
+	for _, framing := range []Framing{FramingSLIP, FramingLengthPrefix} {
+		t.Run(framing.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewStreamEncoder(&buf, framing)
+			for _, p := range packets {
+				if err := e.Encode(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d := NewStreamDecoder(&buf, framing)
+			for _, want := range packets {
+				got, err := d.Decode()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(mustMarshal(t, got), mustMarshal(t, want)) {
+					t.Errorf("Decode = %v; want %v", got, want)
+				}
+			}
+			if _, err := d.Decode(); err != io.EOF {
+				t.Errorf("Decode at the end: %v; want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+// mustMarshal returns the encoding of `p`.
+func mustMarshal(t testing.TB, p Packet) []byte {
+	t.Helper()
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// timeoutError is the net.Error of a read deadline.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// chunkReader returns its chunks one read at a time, with a timeout after
+// each.
+type chunkReader struct {
+	chunks  [][]byte
+	timeout bool
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.timeout {
+		r.timeout = false
+		return 0, timeoutError{}
+	}
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	if r.chunks[0] = r.chunks[0][n:]; len(r.chunks[0]) == 0 {
+		r.chunks = r.chunks[1:]
+	}
+	r.timeout = true
+	return n, nil
+}
+
+func TestReadFrameResumes(t *testing.T) {
+	data := mustMarshal(t, NewMessage("/eos/out/test", []byte{slipEnd, slipEsc}))
+	for _, framing := range []Framing{FramingSLIP, FramingLengthPrefix} {
+		t.Run(framing.String(), func(t *testing.T) {
+			frame, err := encodeFrame(data, framing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Split the frame everywhere: within the size, within an
+			// escape, and within the payload
+			var chunks [][]byte
+			for _, b := range append(frame, frame...) {
+				chunks = append(chunks, []byte{b})
+			}
+			d := NewStreamDecoder(&chunkReader{chunks: chunks}, framing)
+
+			for i := 0; i < 2; i++ {
+				var got []byte
+				timeouts := 0
+				for got == nil {
+					got, err = d.ReadFrame()
+					if err != nil {
+						if _, ok := err.(timeoutError); !ok {
+							t.Fatalf("ReadFrame: %v", err)
+						}
+						timeouts++
+					}
+				}
+				if timeouts == 0 {
+					t.Error("no timeout within the frame")
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("frame %d = %q; want %q", i, got, data)
+				}
+			}
+		})
+	}
+}
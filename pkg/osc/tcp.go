@@ -0,0 +1,339 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TCPConnection is an OSC client/server that exchanges OSC messages and bundles
+// with a single remote peer over a TCP stream. Packets are delimited using the
+// configured Framing.
+type TCPConnection struct {
+	raddr      *net.TCPAddr
+	framing    Framing
+	conn       net.Conn
+	encoder    *StreamEncoder
+	writeMu    sync.Mutex
+	Dispatcher Dispatcher
+	// ReadTimeout, if set, bounds every read of the stream. A read that times
+	// out is not an error: Serve keeps waiting for the peer, which may stay
+	// silent for long.
+	ReadTimeout time.Duration
+	DialTimeout time.Duration
+	// ErrorHandler, if set, is called for every received packet that cannot
+	// be decoded. Such packets are dropped and the connection keeps serving.
+	ErrorHandler ErrorHandlerFunc
+	// DispatchOptions controls how received packets are handed to the
+	// Dispatcher. It is read when Serve starts.
+	DispatchOptions DispatchOptions
+	// DecodeOptions controls how received packets are decoded.
+	DecodeOptions DecodeOptions
+	// Recorder, if set, records every packet sent and received.
+	Recorder *Recorder
+	state    serveState
+}
+
+// NewTCPConnection creates a new OSC client/server that connects to `raddr`
+// over TCP. The `framing` argument selects SLIP (OSC 1.1) or length-prefixed
+// (OSC 1.0) packet framing and must match the remote peer.
+func NewTCPConnection(raddr string, framing Framing) (*TCPConnection, error) {
+	var err error
+	conn := &TCPConnection{framing: framing}
+	if conn.raddr, err = net.ResolveTCPAddr("tcp", raddr); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// RemoteAddress returns the IP address of the remote peer.
+func (c *TCPConnection) RemoteAddress() string {
+	return c.raddr.IP.String()
+}
+
+// RemotePort returns the TCP port of the remote peer.
+func (c *TCPConnection) RemotePort() int {
+	return c.raddr.Port
+}
+
+// Framing returns the packet framing used on the stream.
+func (c *TCPConnection) Framing() Framing {
+	return c.framing
+}
+
+// Open connects to the remote peer.
+func (c *TCPConnection) Open() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn != nil {
+		return fmt.Errorf("connection already opened")
+	}
+	return c.open()
+}
+
+// open connects to the remote peer. It must be called with writeMu held, so
+// that concurrent Sends dial only once.
+func (c *TCPConnection) open() error {
+	if c.Dispatcher == nil {
+		c.Dispatcher = NewStandardDispatcher()
+	}
+	conn, err := net.DialTimeout("tcp", c.raddr.String(), c.DialTimeout)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.encoder = NewStreamEncoder(conn, c.framing)
+	return nil
+}
+
+// Reopen replaces a failed stream with a new connection to the remote peer,
+// e.g. after Serve returned because the peer went away. It must not be called
+// while Serve is running.
+func (c *TCPConnection) Reopen() error {
+	if c.state.isClosed() {
+		return ErrConnectionClosed
+	}
+	conn, err := net.DialTimeout("tcp", c.raddr.String(), c.DialTimeout)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = conn
+	c.encoder = NewStreamEncoder(conn, c.framing)
+	return nil
+}
+
+// Send sends an OSC Bundle or an OSC Message. It connects to the remote peer
+// first if the connection is not open yet.
+func (c *TCPConnection) Send(packet Packet) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn == nil {
+		if err := c.open(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.encoder.Encode(packet); err != nil {
+		return err
+	}
+	if c.Recorder != nil {
+		c.Recorder.RecordPacket(DirectionOut, c.conn.RemoteAddr(), packet)
+	}
+	return nil
+}
+
+// Close closes the TCP stream immediately. A running Serve returns
+// ErrConnectionClosed, dispatches in progress are not waited for, and bundles
+// queued by the scheduler of the Dispatcher are dropped.
+func (c *TCPConnection) Close() error {
+	c.state.stop()
+	return c.closeConn()
+}
+
+// Shutdown gracefully stops the connection. It stops reading packets, waits
+// for Serve to return and for all dispatches in progress to finish, and then
+// closes the stream. If `ctx` expires first, the stream is closed anyway and
+// the context's error is returned. Bundles queued by the scheduler of the
+// Dispatcher are dropped.
+func (c *TCPConnection) Shutdown(ctx context.Context) error {
+	return c.state.shutdown(ctx, c.closeConn)
+}
+
+// DispatchStats reports how the packets received so far were dispatched.
+func (c *TCPConnection) DispatchStats() DispatchStats {
+	return c.state.counters.stats()
+}
+
+func (c *TCPConnection) closeConn() error {
+	if c.conn == nil {
+		return fmt.Errorf("connection not open")
+	}
+	err := c.conn.Close()
+	// If we get "use of closed network connection", it's not a problem because
+	// closing the network connection is exactly what we wanted to do!
+	if err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+		return err
+	}
+	return nil
+}
+
+// Serve retrieves incoming OSC packets from the stream and dispatches retrieved
+// OSC packets until `ctx` is cancelled, the connection is closed, or the
+// stream fails. Packets that cannot be decoded are reported to ErrorHandler
+// and skipped; a corrupt frame ends Serve, as the stream cannot be
+// resynchronized.
+func (c *TCPConnection) Serve(ctx context.Context) error {
+	if c.conn == nil {
+		return fmt.Errorf("connection not open")
+	}
+	ctx, end, err := c.state.begin(ctx, c.Dispatcher, c.DispatchOptions)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	// Unblock a pending read when the context is cancelled
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	cfg := streamConfig{
+		framing:  c.framing,
+		timeout:  c.ReadTimeout,
+		onError:  c.ErrorHandler,
+		recorder: c.Recorder,
+		decode:   c.DecodeOptions,
+	}
+	err = serveStream(ctx, c.conn, cfg, func(p Packet, addr net.Addr) {
+		c.state.dispatch(p, addr)
+	})
+	switch {
+	case c.state.isClosed():
+		return ErrConnectionClosed
+	case ctx.Err() != nil:
+		return ctx.Err()
+	}
+	return err
+}
+
+// ListenAndServeTCP accepts TCP connections on Addr and dispatches the OSC
+// packets received on each of them. Every connection must use the given
+// framing.
+func (s *Server) ListenAndServeTCP(framing Framing) error {
+	defer s.CloseConnection()
+
+	if s.Dispatcher == nil {
+		s.Dispatcher = NewStandardDispatcher()
+	}
+
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.close = ln.Close
+
+	return s.ServeTCP(ln, framing)
+}
+
+// ServeTCP accepts TCP connections from the given listener and dispatches the
+// OSC packets received on each of them, until the listener is closed. Every
+// connection must use the given framing. Packets that cannot be decoded are
+// reported to ErrorHandler and skipped. A connection that sends nothing for
+// ReadTimeout, if set, is closed. When ServeTCP returns, all its connections
+// are closed.
+func (s *Server) ServeTCP(ln net.Listener, framing Framing) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	conns := map[net.Conn]struct{}{}
+
+	// Packets from all connections share one pool, so ordering holds across
+	// connections as well. The pool is closed once no connection can submit
+	// to it.
+	pool := s.newDispatchPool()
+	defer func() {
+		cancel()
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+		pool.close()
+	}()
+
+	cfg := streamConfig{
+		framing:  framing,
+		timeout:  s.ReadTimeout,
+		idle:     true,
+		onError:  s.ErrorHandler,
+		recorder: s.Recorder,
+		decode:   s.DecodeOptions,
+	}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		conns[conn] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+				conn.Close()
+			}()
+			serveStream(ctx, conn, cfg, pool.submit)
+		}()
+	}
+}
+
+// streamConfig holds the settings of serveStream.
+type streamConfig struct {
+	framing Framing
+	timeout time.Duration
+	// idle makes a read timeout end the stream, instead of waiting on
+	idle     bool
+	onError  ErrorHandlerFunc
+	recorder *Recorder
+	decode   DecodeOptions
+}
+
+// serveStream decodes framed packets from `conn` and passes them to
+// `dispatch` until reading a frame fails. Every frame is recorded, if a
+// recorder is set, and undecodable packets are reported to onError, if set.
+func serveStream(ctx context.Context, conn net.Conn, cfg streamConfig, dispatch func(Packet, net.Addr)) error {
+	stream := NewStreamDecoder(conn, cfg.framing)
+	addresses := addressCache{}
+	addr := conn.RemoteAddr()
+	for {
+		if cfg.timeout != 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(cfg.timeout)); err != nil {
+				return err
+			}
+			// The deadline may have overwritten the one set on cancellation
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		// A timeout leaves a partly read frame in the decoder, which the
+		// next read resumes
+		frame, err := stream.ReadFrame()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() && ctx.Err() == nil && !cfg.idle {
+				continue
+			}
+			return err
+		}
+
+		cfg.recorder.Record(DirectionIn, addr, frame)
+
+		p, err := readPacket(&decoder{data: frame, addresses: addresses, opts: cfg.decode})
+		if err != nil {
+			if cfg.onError != nil {
+				cfg.onError(err, addr)
+			}
+			continue
+		}
+		dispatch(p, addr)
+	}
+}
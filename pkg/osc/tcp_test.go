@@ -0,0 +1,177 @@
+package osc
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTCPConnectionSendDialsOnce(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 8)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	c, err := NewTCPConnection(ln.Addr().String(), FramingSLIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Send(NewMessage("/eos/ping")); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	conn := <-accepted
+	defer conn.Close()
+	d := NewStreamDecoder(conn, FramingSLIP)
+	for range 8 {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case conn := <-accepted:
+		conn.Close()
+		t.Error("Send dialed more than once")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// serveTCP serves `s` on a TCP listener on the loopback interface. ServeTCP's
+// result is sent on the returned channel.
+func serveTCP(t *testing.T, s *Server, framing Framing) (net.Listener, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	served := make(chan error, 1)
+	go func() { served <- s.ServeTCP(ln, framing) }()
+	return ln, served
+}
+
+// writeFrame writes `data` to `conn` as a single frame.
+func writeFrame(t *testing.T, conn net.Conn, data []byte, framing Framing) {
+	t.Helper()
+	frame, err := encodeFrame(data, framing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// closedByPeer tests that the other end of `conn` was closed.
+func closedByPeer(t *testing.T, conn net.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read = %v; want io.EOF", err)
+	}
+}
+
+func TestServerServeTCP(t *testing.T) {
+	for _, framing := range []Framing{FramingSLIP, FramingLengthPrefix} {
+		t.Run(framing.String(), func(t *testing.T) {
+			d := newBlockingDispatcher(&dispatchCounters{})
+			close(d.release)
+			errs := make(chan net.Addr, 1)
+			s := &Server{
+				Dispatcher: d,
+				ErrorHandler: func(err error, addr net.Addr) {
+					errs <- addr
+				},
+			}
+			ln, served := serveTCP(t, s, framing)
+
+			var conns []net.Conn
+			for range 2 {
+				conn, err := net.Dial("tcp", ln.Addr().String())
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+				conns = append(conns, conn)
+			}
+			writeFrame(t, conns[0], mustMarshal(t, NewMessage("/a")), framing)
+			d.wait(t, "/a")
+			writeFrame(t, conns[1], mustMarshal(t, NewMessage("/b")), framing)
+			d.wait(t, "/b")
+
+			// A bad packet is reported, and the connection keeps serving
+			writeFrame(t, conns[0], []byte("not OSC"), framing)
+			select {
+			case addr := <-errs:
+				if addr.String() != conns[0].LocalAddr().String() {
+					t.Errorf("error from %v; want %v", addr, conns[0].LocalAddr())
+				}
+			case <-time.After(time.Second):
+				t.Fatal("ErrorHandler not called")
+			}
+			writeFrame(t, conns[0], mustMarshal(t, NewMessage("/c")), framing)
+			d.wait(t, "/c")
+
+			// Closing the listener closes the connections
+			ln.Close()
+			select {
+			case err := <-served:
+				if !errors.Is(err, net.ErrClosed) {
+					t.Errorf("ServeTCP = %v; want net.ErrClosed", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("ServeTCP did not return")
+			}
+			for _, conn := range conns {
+				closedByPeer(t, conn)
+			}
+			if stats := s.DispatchStats(); stats.Dispatched != 3 {
+				t.Errorf("stats %+v", stats)
+			}
+		})
+	}
+}
+
+func TestServerServeTCPReadTimeout(t *testing.T) {
+	d := newBlockingDispatcher(&dispatchCounters{})
+	close(d.release)
+	s := &Server{Dispatcher: d, ReadTimeout: 50 * time.Millisecond}
+	ln, _ := serveTCP(t, s, FramingSLIP)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	writeFrame(t, conn, mustMarshal(t, NewMessage("/a")), FramingSLIP)
+	d.wait(t, "/a")
+
+	// The idle peer is dropped
+	closedByPeer(t, conn)
+}
@@ -0,0 +1,387 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Template is an OSC address template with named path parameters, such as
+// "/eos/fader/{bank}/{fader}", together with the type tags of the message
+// arguments. It builds messages for the template and extracts the parameters
+// from received addresses.
+type Template struct {
+	template  string
+	parts     []templatePart
+	params    []string
+	signature string
+	variadic  bool
+}
+
+// templatePart is a part of the address between slashes. It is either
+// literal text or a parameter.
+type templatePart struct {
+	literal string
+	param   string
+}
+
+// Params holds the path parameters of an address matched by a Template.
+type Params map[string]string
+
+////
+// Template
+////
+
+// NewTemplate parses an address template and a signature. A parameter is a
+// whole address part of the form "{name}". The signature lists the type tags
+// of the arguments, with or without the leading ',', such as ",if". A trailing
+// '*' allows any further arguments, and an empty signature does not check the
+// arguments at all. Arrays are not supported in signatures.
+func NewTemplate(template, signature string) (*Template, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("OSC address template must start with '/': %q", template)
+	}
+
+	t := &Template{template: template}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(template[1:], "/") {
+		if len(part) > 2 && part[0] == '{' && part[len(part)-1] == '}' {
+			name := part[1 : len(part)-1]
+			if strings.ContainsAny(name, patternChars+",") {
+				return nil, fmt.Errorf("invalid parameter name %q in template %q", name, template)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("repeated parameter %q in template %q", name, template)
+			}
+			seen[name] = true
+			t.parts = append(t.parts, templatePart{param: name})
+			t.params = append(t.params, name)
+			continue
+		}
+		if part == "" || strings.ContainsAny(part, patternChars) {
+			return nil, fmt.Errorf("invalid part %q in template %q", part, template)
+		}
+		t.parts = append(t.parts, templatePart{literal: part})
+	}
+
+	signature = strings.TrimPrefix(signature, ",")
+	if strings.HasSuffix(signature, "*") {
+		signature = signature[:len(signature)-1]
+		t.variadic = true
+	}
+	for _, c := range []byte(signature) {
+		if strings.IndexByte("ihfdsSbcrmtNITF", c) < 0 {
+			return nil, fmt.Errorf("unsupported type tag %q in signature of %q", c, template)
+		}
+	}
+	t.signature = signature
+	if signature == "" && !t.variadic {
+		// An empty signature checks nothing
+		t.variadic = true
+	}
+	return t, nil
+}
+
+// MustTemplate is like NewTemplate but panics if the template cannot be
+// parsed.
+func MustTemplate(template, signature string) *Template {
+	t, err := NewTemplate(template, signature)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// String returns the source text of the template.
+func (t *Template) String() string {
+	return t.template
+}
+
+// Params returns the names of the path parameters in order.
+func (t *Template) Params() []string {
+	return t.params
+}
+
+// Pattern returns an OSC address pattern matching every address of the
+// template, with a '*' for each parameter, e.g. to register a handler.
+func (t *Template) Pattern() string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		b.WriteByte('/')
+		if p.param != "" {
+			b.WriteByte('*')
+		} else {
+			b.WriteString(p.literal)
+		}
+	}
+	return b.String()
+}
+
+// Address returns the address with the parameters replaced by `values`, in
+// template order. Values must be integers or strings.
+func (t *Template) Address(values ...interface{}) (string, error) {
+	if len(values) != len(t.params) {
+		return "", fmt.Errorf("template %q takes %d parameters, got %d", t.template, len(t.params), len(values))
+	}
+	var b strings.Builder
+	i := 0
+	for _, p := range t.parts {
+		b.WriteByte('/')
+		if p.param == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		s, err := formatParam(values[i])
+		if err != nil {
+			return "", fmt.Errorf("parameter %q of template %q: %v", p.param, t.template, err)
+		}
+		b.WriteString(s)
+		i++
+	}
+	return b.String(), nil
+}
+
+// Message returns a message for the template. The first values fill the path
+// parameters in template order, and the remaining values are the arguments,
+// which are checked against the signature.
+func (t *Template) Message(values ...interface{}) (*Message, error) {
+	if len(values) < len(t.params) {
+		return nil, fmt.Errorf("template %q takes %d parameters, got %d", t.template, len(t.params), len(values))
+	}
+	addr, err := t.Address(values[:len(t.params)]...)
+	if err != nil {
+		return nil, err
+	}
+	args, err := t.arguments(values[len(t.params):])
+	if err != nil {
+		return nil, err
+	}
+	return NewMessage(addr, args...), nil
+}
+
+// Build returns a message for the template with the named parameters filled
+// in from `params`. The arguments are checked against the signature.
+func (t *Template) Build(params map[string]interface{}, args ...interface{}) (*Message, error) {
+	values := make([]interface{}, 0, len(t.params)+len(args))
+	for _, name := range t.params {
+		v, ok := params[name]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter %q of template %q", name, t.template)
+		}
+		values = append(values, v)
+	}
+	if len(params) > len(t.params) {
+		for name := range params {
+			if !t.hasParam(name) {
+				return nil, fmt.Errorf("unknown parameter %q of template %q", name, t.template)
+			}
+		}
+	}
+	return t.Message(append(values, args...)...)
+}
+
+// MustMessage is like Message but panics on error, for messages built from
+// constants.
+func (t *Template) MustMessage(values ...interface{}) *Message {
+	msg, err := t.Message(values...)
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
+
+// Match reports whether `addr` is an address of the template and returns its
+// path parameters.
+func (t *Template) Match(addr string) (Params, bool) {
+	if !strings.HasPrefix(addr, "/") {
+		return nil, false
+	}
+	parts := strings.Split(addr[1:], "/")
+	if len(parts) != len(t.parts) {
+		return nil, false
+	}
+	params := make(Params, len(t.params))
+	for i, p := range t.parts {
+		switch {
+		case p.param != "":
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[p.param] = parts[i]
+		case p.literal != parts[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Check reports whether the arguments of `msg` match the signature.
+func (t *Template) Check(msg *Message) error {
+	if len(msg.Arguments) < len(t.signature) {
+		return &ArgumentError{Address: msg.Address, Index: len(msg.Arguments), Err: ErrMissingArgument}
+	}
+	if !t.variadic && len(msg.Arguments) > len(t.signature) {
+		return fmt.Errorf("%s: want arguments ,%s, got %d arguments", msg.Address, t.signature, len(msg.Arguments))
+	}
+	for i := range t.signature {
+		tag, err := typeTag(msg.Arguments[i])
+		if err != nil || tag != t.signature[i] {
+			want := fmt.Sprintf("type tag %q", t.signature[i])
+			return &ArgumentError{Address: msg.Address, Index: i, Want: want, Value: msg.Arguments[i], Err: ErrArgumentType}
+		}
+	}
+	return nil
+}
+
+// Handler returns a HandlerFunc calling `handler` with the path parameters
+// of messages for the template. Messages with other addresses are ignored,
+// so the returned handler can be registered under Pattern.
+func (t *Template) Handler(handler func(msg *Message, params Params, addr net.Addr)) HandlerFunc {
+	return func(msg *Message, addr net.Addr) {
+		if params, ok := t.Match(msg.Address); ok {
+			handler(msg, params, addr)
+		}
+	}
+}
+
+func (t *Template) hasParam(name string) bool {
+	for _, p := range t.params {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// arguments checks `args` against the signature and converts Go numbers to
+// the declared OSC types where no precision is lost.
+func (t *Template) arguments(args []interface{}) ([]interface{}, error) {
+	if len(args) < len(t.signature) || (!t.variadic && len(args) > len(t.signature)) {
+		return nil, fmt.Errorf("template %q wants arguments ,%v, got %d arguments", t.template, t.signature, len(args))
+	}
+	out := make([]interface{}, len(args))
+	copy(out, args)
+	for i := range t.signature {
+		v, ok := convertArgument(t.signature[i], args[i])
+		if !ok {
+			return nil, fmt.Errorf("template %q: argument %d is %T, want type tag %q", t.template, i, args[i], t.signature[i])
+		}
+		out[i] = v
+	}
+	// Further arguments keep their type, except for int, which has no OSC type
+	for i := len(t.signature); i < len(out); i++ {
+		if v, ok := out[i].(int); ok {
+			if out[i], ok = convertArgument('i', v); !ok {
+				out[i], _ = convertArgument('h', v)
+			}
+		}
+		if _, err := appendTypeTags(nil, out[i]); err != nil {
+			return nil, fmt.Errorf("template %q: argument %d: %v", t.template, i, err)
+		}
+	}
+	return out, nil
+}
+
+// convertArgument returns `arg` as the Go type of the OSC type tag `tag`.
+func convertArgument(tag byte, arg interface{}) (interface{}, bool) {
+	switch tag {
+	case 'i':
+		switch v := arg.(type) {
+		case int32:
+			return v, true
+		case int:
+			if v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int32(v), true
+			}
+		case int64:
+			if v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int32(v), true
+			}
+		}
+	case 'h':
+		switch v := arg.(type) {
+		case int64:
+			return v, true
+		case int:
+			return int64(v), true
+		case int32:
+			return int64(v), true
+		}
+	case 'f':
+		switch v := arg.(type) {
+		case float32:
+			return v, true
+		case float64:
+			return float32(v), true
+		}
+	case 'd':
+		switch v := arg.(type) {
+		case float64:
+			return v, true
+		case float32:
+			return float64(v), true
+		}
+	case 'S':
+		switch v := arg.(type) {
+		case Symbol:
+			return v, true
+		case string:
+			return Symbol(v), true
+		}
+	case 'T', 'F':
+		if v, ok := arg.(bool); ok && v == (tag == 'T') {
+			return v, true
+		}
+	default:
+		if got, err := typeTag(arg); err == nil && got == tag {
+			return arg, true
+		}
+	}
+	return nil, false
+}
+
+// formatParam formats a path parameter value.
+func formatParam(v interface{}) (string, error) {
+	var s string
+	switch v := v.(type) {
+	case int:
+		s = strconv.Itoa(v)
+	case int32:
+		s = strconv.FormatInt(int64(v), 10)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case uint8:
+		s = strconv.FormatUint(uint64(v), 10)
+	case uint:
+		s = strconv.FormatUint(uint64(v), 10)
+	case string:
+		s = v
+	default:
+		return "", fmt.Errorf("unsupported parameter type %T", v)
+	}
+	if s == "" || strings.ContainsAny(s, "/ #,"+patternChars) {
+		return "", fmt.Errorf("invalid parameter value %q", s)
+	}
+	return s, nil
+}
+
+////
+// Params
+////
+
+// Int returns the named parameter as an integer.
+func (p Params) Int(name string) (int, error) {
+	s, ok := p[name]
+	if !ok {
+		return 0, fmt.Errorf("missing parameter %q", name)
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %q: %q is not an integer", name, s)
+	}
+	return v, nil
+}
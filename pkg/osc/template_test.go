@@ -0,0 +1,197 @@
+package osc
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestTemplateMessage(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		template  string
+		signature string
+		values    []interface{}
+		want      *Message
+	}{
+		{
+			name:      "converted arguments",
+			template:  "/eos/fader/{bank}/{fader}",
+			signature: ",f",
+			values:    []interface{}{1, int32(2), 0.5},
+			want:      NewMessage("/eos/fader/1/2", float32(0.5)),
+		},
+		{
+			name:      "string parameter",
+			template:  "/eos/wheel/{mode}/{wheel}",
+			signature: "f",
+			values:    []interface{}{"coarse", uint8(3), float32(-1)},
+			want:      NewMessage("/eos/wheel/coarse/3", float32(-1)),
+		},
+		{
+			name:      "variadic",
+			template:  "/eos/cmd",
+			signature: ",s*",
+			values:    []interface{}{"Chan %1", 5, int64(1) << 40, "x"},
+			want:      NewMessage("/eos/cmd", "Chan %1", int32(5), int64(1)<<40, "x"),
+		},
+		{
+			name:      "no signature",
+			template:  "/eos/ping",
+			signature: "",
+			values:    []interface{}{"tag", int32(1)},
+			want:      NewMessage("/eos/ping", "tag", int32(1)),
+		},
+		{
+			name:      "symbol and int64",
+			template:  "/t",
+			signature: ",Sh",
+			values:    []interface{}{"s", 7},
+			want:      NewMessage("/t", Symbol("s"), int64(7)),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MustTemplate(tt.template, tt.signature).Message(tt.values...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equals(tt.want) {
+				t.Errorf("Message = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateMessageInvalid(t *testing.T) {
+	tmpl := MustTemplate("/eos/fader/{bank}/{fader}", ",f")
+	for _, tt := range []struct {
+		name   string
+		values []interface{}
+	}{
+		{name: "missing parameter", values: []interface{}{1}},
+		{name: "missing argument", values: []interface{}{1, 2}},
+		{name: "extra argument", values: []interface{}{1, 2, 0.5, 0.5}},
+		{name: "wrong type", values: []interface{}{1, 2, "full"}},
+		{name: "parameter with slash", values: []interface{}{"1/2", 2, 0.5}},
+		{name: "parameter with wildcard", values: []interface{}{"*", 2, 0.5}},
+		{name: "empty parameter", values: []interface{}{"", 2, 0.5}},
+		{name: "unsupported parameter", values: []interface{}{1.5, 2, 0.5}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if msg, err := tmpl.Message(tt.values...); err == nil {
+				t.Errorf("Message = %v; want an error", msg)
+			}
+		})
+	}
+
+	if _, err := MustTemplate("/t", ",i").Message(1 << 40); err == nil {
+		t.Error("Message with an int32 out of range: no error")
+	}
+}
+
+func TestNewTemplateInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		template  string
+		signature string
+	}{
+		{template: "eos/ping"},
+		{template: "/eos/{a}/{a}"},
+		{template: "/eos/{a*}"},
+		{template: "/eos/*"},
+		{template: "/eos//ping"},
+		{template: "/eos/ping", signature: ",[i]"},
+		{template: "/eos/ping", signature: ",x"},
+	} {
+		if _, err := NewTemplate(tt.template, tt.signature); err == nil {
+			t.Errorf("NewTemplate(%q, %q): no error", tt.template, tt.signature)
+		}
+	}
+}
+
+func TestTemplateBuild(t *testing.T) {
+	tmpl := MustTemplate("/eos/fader/{bank}/{fader}/{action}", "")
+	got, err := tmpl.Build(map[string]interface{}{"bank": 1, "fader": 2, "action": "fire"}, float32(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := NewMessage("/eos/fader/1/2/fire", float32(1)); !got.Equals(want) {
+		t.Errorf("Build = %v; want %v", got, want)
+	}
+
+	if _, err := tmpl.Build(map[string]interface{}{"bank": 1, "fader": 2}); err == nil {
+		t.Error("Build with a missing parameter: no error")
+	}
+	if _, err := tmpl.Build(map[string]interface{}{"bank": 1, "fader": 2, "action": "fire", "x": 1}); err == nil {
+		t.Error("Build with an unknown parameter: no error")
+	}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	tmpl := MustTemplate("/eos/out/active/cue/{list}/{cue}", "")
+	if got, want := tmpl.Pattern(), "/eos/out/active/cue/*/*"; got != want {
+		t.Errorf("Pattern = %q; want %q", got, want)
+	}
+
+	for _, tt := range []struct {
+		addr string
+		want Params
+	}{
+		{addr: "/eos/out/active/cue/1/2.5", want: Params{"list": "1", "cue": "2.5"}},
+		{addr: "/eos/out/active/cue/1"},
+		{addr: "/eos/out/active/cue/1/2/3"},
+		{addr: "/eos/out/active/cue//2"},
+		{addr: "/eos/out/pending/cue/1/2"},
+		{addr: "eos/out/active/cue/1/2"},
+	} {
+		got, ok := tmpl.Match(tt.addr)
+		if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Match(%q) = %v, %v; want %v", tt.addr, got, ok, tt.want)
+		}
+	}
+
+	params, _ := tmpl.Match("/eos/out/active/cue/3/2.5")
+	if n, err := params.Int("list"); err != nil || n != 3 {
+		t.Errorf("Int(list) = %d, %v; want 3", n, err)
+	}
+	if _, err := params.Int("cue"); err == nil {
+		t.Error("Int(cue) of 2.5: no error")
+	}
+	if _, err := params.Int("missing"); err == nil {
+		t.Error("Int of a missing parameter: no error")
+	}
+}
+
+func TestTemplateCheck(t *testing.T) {
+	tmpl := MustTemplate("/eos/out/active/wheel/{wheel}", ",si*")
+	for _, tt := range []struct {
+		msg *Message
+		err error
+	}{
+		{msg: NewMessage("/w", "Pan", int32(1))},
+		{msg: NewMessage("/w", "Pan", int32(1), float32(127))},
+		{msg: NewMessage("/w", "Pan"), err: ErrMissingArgument},
+		{msg: NewMessage("/w", "Pan", float32(1)), err: ErrArgumentType},
+	} {
+		if err := tmpl.Check(tt.msg); !errors.Is(err, tt.err) {
+			t.Errorf("Check(%v) = %v; want %v", tt.msg, err, tt.err)
+		}
+	}
+
+	if err := MustTemplate("/w", ",s").Check(NewMessage("/w", "a", "b")); err == nil {
+		t.Error("Check with an extra argument: no error")
+	}
+}
+
+func TestTemplateHandler(t *testing.T) {
+	tmpl := MustTemplate("/eos/out/fader/{bank}/{fader}", "")
+	var got []Params
+	h := tmpl.Handler(func(_ *Message, params Params, _ net.Addr) {
+		got = append(got, params)
+	})
+	h(NewMessage("/eos/out/fader/1/2"), nil)
+	h(NewMessage("/eos/out/fader/1/2/name"), nil)
+	if want := []Params{{"bank": "1", "fader": "2"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("handler called with %v; want %v", got, want)
+	}
+}
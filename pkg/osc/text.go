@@ -0,0 +1,605 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+////
+// Text notation
+////
+//
+// A message is written as its address, an optional type tag string and the
+// arguments, separated by spaces:
+//
+//	/eos/cmd ,si "Chan 1" 5
+//
+// Each type tag takes one argument, except T, F, N and I, which carry their
+// value in the tag, and the array brackets. Strings and symbols are quoted
+// with Go escapes; a string without spaces may be left unquoted. Chars are Go
+// rune literals ('a'), colors are #rrggbbaa, blobs and MIDI messages are hex
+// with a 0x prefix, and time tags are "immediate" or an RFC 3339 time.
+//
+// Without a type tag string, the types are inferred: quoted strings, chars,
+// integers (i, or h if too large), floats (f), true, false, nil, colors and
+// arrays in separate [ and ] words. Other words are strings.
+//
+// A bundle is "#bundle", its time tag and its elements in braces, separated
+// by newlines or semicolons:
+//
+//	#bundle immediate { /eos/key/go; /eos/cmd ,s "Chan 1" }
+
+// ParseText parses a single OSC message or bundle in text notation.
+func ParseText(text string) (Packet, error) {
+	p := &textParser{text: text}
+	p.skipSeparators()
+	packet, err := p.parsePacket()
+	if err == nil {
+		p.skipSeparators()
+		if tok := p.next(); tok.kind != textEOF {
+			err = p.errorf(tok, "unexpected %q after packet", tok.text)
+		}
+	}
+	// A scanning error explains an unexpected end of the input best
+	if p.err != nil {
+		return nil, p.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// FormatText returns the text notation of an OSC message or bundle. The type
+// tag string is always included, so ParseText returns an equal packet.
+func FormatText(packet Packet) (string, error) {
+	var b strings.Builder
+	if err := formatPacket(&b, packet); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func formatPacket(b *strings.Builder, packet Packet) error {
+	switch p := packet.(type) {
+	case *Message:
+		return formatMessage(b, p)
+
+	case *Bundle:
+		b.WriteString(bundleTagString)
+		b.WriteByte(' ')
+		b.WriteString(formatTimetag(p.Timetag))
+		b.WriteString(" {")
+		n := 0
+		for _, m := range p.Messages {
+			if n > 0 {
+				b.WriteByte(';')
+			}
+			b.WriteByte(' ')
+			if err := formatMessage(b, m); err != nil {
+				return err
+			}
+			n++
+		}
+		for _, e := range p.Bundles {
+			if n > 0 {
+				b.WriteByte(';')
+			}
+			b.WriteByte(' ')
+			if err := formatPacket(b, e); err != nil {
+				return err
+			}
+			n++
+		}
+		b.WriteString(" }")
+		return nil
+	}
+	return fmt.Errorf("unsupported OSC packet type: %T", packet)
+}
+
+func formatMessage(b *strings.Builder, msg *Message) error {
+	if msg.Address == "" || strings.ContainsAny(msg.Address, " \t\r\n;\"'") ||
+		msg.Address[0] == ',' || msg.Address == "{" || msg.Address == "}" {
+		return fmt.Errorf("address cannot be written as text: %q", msg.Address)
+	}
+	b.WriteString(msg.Address)
+	if len(msg.Arguments) == 0 {
+		return nil
+	}
+
+	tags := []byte{' ', ','}
+	for _, arg := range msg.Arguments {
+		var err error
+		if tags, err = appendTypeTags(tags, arg); err != nil {
+			return err
+		}
+	}
+	b.Write(tags)
+	for _, arg := range msg.Arguments {
+		formatTextArgument(b, arg)
+	}
+	return nil
+}
+
+// formatTextArgument writes the value of `arg`, preceded by a space. The type
+// of `arg` must have been checked by appendTypeTags.
+func formatTextArgument(b *strings.Builder, arg interface{}) {
+	switch t := arg.(type) {
+	case bool, nil, Infinitum:
+		// The type tag is the value
+		return
+
+	case []interface{}:
+		for _, a := range t {
+			formatTextArgument(b, a)
+		}
+		return
+	}
+
+	b.WriteByte(' ')
+	switch t := arg.(type) {
+	case int32:
+		b.WriteString(strconv.FormatInt(int64(t), 10))
+	case int64:
+		b.WriteString(strconv.FormatInt(t, 10))
+	case float32:
+		b.WriteString(formatTextFloat(float64(t), 32))
+	case float64:
+		b.WriteString(formatTextFloat(t, 64))
+	case string:
+		b.WriteString(strconv.Quote(t))
+	case Symbol:
+		b.WriteString(strconv.Quote(string(t)))
+	case Char:
+		b.WriteString(strconv.QuoteRune(rune(t)))
+	case RGBA:
+		b.WriteString(t.String())
+	case MIDIMessage:
+		fmt.Fprintf(b, "0x%02x%02x%02x%02x", t.Port, t.Status, t.Data1, t.Data2)
+	case []byte:
+		b.WriteString("0x")
+		b.WriteString(hex.EncodeToString(t))
+	case Timetag:
+		b.WriteString(formatTimetag(t))
+	}
+}
+
+// formatTextFloat formats a float so that it is not mistaken for an integer.
+func formatTextFloat(f float64, bitSize int) string {
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+func formatTimetag(t Timetag) string {
+	if t.TimeTag() == 1 {
+		return "immediate"
+	}
+	return t.Time().UTC().Format(time.RFC3339Nano)
+}
+
+////
+// Parser
+////
+
+type textTokenKind int
+
+const (
+	textEOF textTokenKind = iota
+	textSeparator
+	textWord
+	textString
+	textChar
+)
+
+type textToken struct {
+	kind textTokenKind
+	text string // The word, or the unquoted string or char
+	pos  int
+}
+
+// textParser parses the text notation. It reads one token ahead.
+type textParser struct {
+	text   string
+	pos    int
+	peeked *textToken
+	err    error
+}
+
+func (p *textParser) errorf(tok textToken, format string, args ...interface{}) error {
+	return fmt.Errorf("osc text at offset %d: %s", tok.pos, fmt.Sprintf(format, args...))
+}
+
+// peek returns the next token without consuming it.
+func (p *textParser) peek() textToken {
+	if p.peeked == nil {
+		tok := p.scan()
+		p.peeked = &tok
+	}
+	return *p.peeked
+}
+
+// next consumes and returns the next token.
+func (p *textParser) next() textToken {
+	tok := p.peek()
+	p.peeked = nil
+	return tok
+}
+
+// scan reads the next token from the text. Scanning errors are stored in
+// p.err and end the input.
+func (p *textParser) scan() textToken {
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+	tok := textToken{pos: p.pos}
+	if p.err != nil || p.pos == len(p.text) {
+		return tok
+	}
+
+	switch c := p.text[p.pos]; c {
+	case '\n', ';':
+		p.pos++
+		tok.kind = textSeparator
+		tok.text = string(c)
+
+	case '"', '\'':
+		end := p.pos + 1
+		for end < len(p.text) && p.text[end] != c && p.text[end] != '\n' {
+			if p.text[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(p.text) || p.text[end] != c {
+			p.err = p.errorf(tok, "unterminated quote")
+			return textToken{pos: p.pos}
+		}
+		quoted := p.text[p.pos : end+1]
+		p.pos = end + 1
+		if c == '\'' {
+			r, _, tail, err := strconv.UnquoteChar(quoted[1:len(quoted)-1], '\'')
+			if err != nil || tail != "" {
+				p.err = p.errorf(tok, "invalid char %s", quoted)
+				return textToken{pos: p.pos}
+			}
+			tok.kind = textChar
+			tok.text = string(r)
+		} else {
+			s, err := strconv.Unquote(quoted)
+			if err != nil {
+				p.err = p.errorf(tok, "invalid string %s", quoted)
+				return textToken{pos: p.pos}
+			}
+			tok.kind = textString
+			tok.text = s
+		}
+
+	default:
+		end := p.pos
+		for end < len(p.text) && strings.IndexByte(" \t\r\n;", p.text[end]) < 0 {
+			end++
+		}
+		tok.kind = textWord
+		tok.text = p.text[p.pos:end]
+		p.pos = end
+	}
+	return tok
+}
+
+// skipSeparators consumes newlines and semicolons.
+func (p *textParser) skipSeparators() {
+	for p.peek().kind == textSeparator {
+		p.next()
+	}
+}
+
+// atElementEnd reports whether the next token ends a message.
+func (p *textParser) atElementEnd() bool {
+	tok := p.peek()
+	return tok.kind == textEOF || tok.kind == textSeparator || (tok.kind == textWord && tok.text == "}")
+}
+
+func (p *textParser) parsePacket() (Packet, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == textWord && tok.text == bundleTagString:
+		return p.parseBundle()
+	case tok.kind == textWord && strings.HasPrefix(tok.text, "/"):
+		return p.parseMessage(tok.text)
+	case tok.kind == textEOF:
+		return nil, p.errorf(tok, "missing packet")
+	}
+	return nil, p.errorf(tok, "packet must start with an address or %s, not %q", bundleTagString, tok.text)
+}
+
+func (p *textParser) parseBundle() (*Bundle, error) {
+	tok := p.next()
+	if tok.kind != textWord {
+		return nil, p.errorf(tok, "missing bundle time tag")
+	}
+	tt, err := parseTimetag(tok.text)
+	if err != nil {
+		return nil, p.errorf(tok, "%v", err)
+	}
+	bundle := &Bundle{Timetag: tt}
+
+	if tok = p.next(); tok.kind != textWord || tok.text != "{" {
+		return nil, p.errorf(tok, "expected { after bundle time tag")
+	}
+	for {
+		p.skipSeparators()
+		if tok = p.peek(); tok.kind == textWord && tok.text == "}" {
+			p.next()
+			return bundle, nil
+		}
+		if tok.kind == textEOF {
+			return nil, p.errorf(tok, "missing } at end of bundle")
+		}
+		elem, err := p.parsePacket()
+		if err != nil {
+			return nil, err
+		}
+		bundle.Append(elem)
+		if !p.atElementEnd() {
+			tok = p.peek()
+			return nil, p.errorf(tok, "unexpected %q after bundle element", tok.text)
+		}
+	}
+}
+
+func (p *textParser) parseMessage(addr string) (*Message, error) {
+	msg := NewMessage(addr)
+	if tok := p.peek(); tok.kind == textWord && strings.HasPrefix(tok.text, ",") {
+		p.next()
+		args, err := p.parseTaggedArguments(tok)
+		if err != nil {
+			return nil, err
+		}
+		msg.Arguments = args
+	} else {
+		args, err := p.parseInferredArguments()
+		if err != nil {
+			return nil, err
+		}
+		msg.Arguments = args
+	}
+	return msg, nil
+}
+
+// parseTaggedArguments parses the arguments described by the type tag string
+// in `tags`.
+func (p *textParser) parseTaggedArguments(tags textToken) ([]interface{}, error) {
+	var args []interface{}
+	var stack [][]interface{}
+	for i := 1; i < len(tags.text); i++ {
+		tag := tags.text[i]
+		var arg interface{}
+		switch tag {
+		case 'T':
+			arg = true
+		case 'F':
+			arg = false
+		case 'N':
+			arg = nil
+		case 'I':
+			arg = Infinitum{}
+
+		case '[':
+			stack = append(stack, args)
+			args = nil
+			continue
+
+		case ']':
+			if len(stack) == 0 {
+				return nil, p.errorf(tags, "unbalanced type tag string %q", tags.text)
+			}
+			array := args
+			if array == nil {
+				array = []interface{}{}
+			}
+			args = append(stack[len(stack)-1], array)
+			stack = stack[:len(stack)-1]
+			continue
+
+		default:
+			if p.atElementEnd() {
+				return nil, p.errorf(p.peek(), "missing argument for type tag %q", tag)
+			}
+			tok := p.next()
+			var err error
+			if arg, err = parseTaggedValue(tag, tok); err != nil {
+				return nil, p.errorf(tok, "%v", err)
+			}
+		}
+		args = append(args, arg)
+	}
+	if len(stack) > 0 {
+		return nil, p.errorf(tags, "unbalanced type tag string %q", tags.text)
+	}
+	if !p.atElementEnd() {
+		tok := p.peek()
+		return nil, p.errorf(tok, "too many arguments for type tag string %q", tags.text)
+	}
+	return args, nil
+}
+
+// parseTaggedValue parses the argument for a type tag that takes a value.
+func parseTaggedValue(tag byte, tok textToken) (interface{}, error) {
+	word := tok.kind == textWord
+	switch tag {
+	case 'i':
+		if word {
+			if v, err := strconv.ParseInt(tok.text, 0, 32); err == nil {
+				return int32(v), nil
+			}
+		}
+	case 'h':
+		if word {
+			if v, err := strconv.ParseInt(tok.text, 0, 64); err == nil {
+				return v, nil
+			}
+		}
+	case 'f':
+		if word {
+			if v, err := strconv.ParseFloat(tok.text, 32); err == nil {
+				return float32(v), nil
+			}
+		}
+	case 'd':
+		if word {
+			if v, err := strconv.ParseFloat(tok.text, 64); err == nil {
+				return v, nil
+			}
+		}
+	case 's':
+		if tok.kind != textChar {
+			return tok.text, nil
+		}
+	case 'S':
+		if tok.kind != textChar {
+			return Symbol(tok.text), nil
+		}
+	case 'c':
+		if tok.kind == textChar {
+			r, _ := utf8.DecodeRuneInString(tok.text)
+			return Char(r), nil
+		}
+	case 'r':
+		if word {
+			if c, ok := parseRGBA(tok.text); ok {
+				return c, nil
+			}
+		}
+	case 'm':
+		if b, ok := parseHex(tok); ok && len(b) == 4 {
+			return MIDIMessage{b[0], b[1], b[2], b[3]}, nil
+		}
+	case 'b':
+		if b, ok := parseHex(tok); ok {
+			return b, nil
+		}
+	case 't':
+		if word {
+			return parseTimetag(tok.text)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type tag %q", tag)
+	}
+	return nil, fmt.Errorf("invalid argument %q for type tag %q", tok.text, tag)
+}
+
+// parseInferredArguments parses arguments without a type tag string.
+func (p *textParser) parseInferredArguments() ([]interface{}, error) {
+	var args []interface{}
+	var stack [][]interface{}
+	for !p.atElementEnd() {
+		tok := p.next()
+		var arg interface{}
+		switch tok.kind {
+		case textString:
+			arg = tok.text
+		case textChar:
+			r, _ := utf8.DecodeRuneInString(tok.text)
+			arg = Char(r)
+		case textWord:
+			switch tok.text {
+			case "[":
+				stack = append(stack, args)
+				args = nil
+				continue
+			case "]":
+				if len(stack) == 0 {
+					return nil, p.errorf(tok, "unbalanced ]")
+				}
+				array := args
+				if array == nil {
+					array = []interface{}{}
+				}
+				args = append(stack[len(stack)-1], array)
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			arg = inferValue(tok.text)
+		}
+		args = append(args, arg)
+	}
+	if len(stack) > 0 {
+		return nil, p.errorf(p.peek(), "missing ]")
+	}
+	return args, nil
+}
+
+// inferValue returns the argument an unquoted word stands for.
+func inferValue(word string) interface{} {
+	switch word {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "nil":
+		return nil
+	}
+	if v, err := strconv.ParseInt(word, 10, 64); err == nil {
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int32(v)
+		}
+		return v
+	}
+	if strings.ContainsAny(word, "0123456789") {
+		if v, err := strconv.ParseFloat(word, 32); err == nil {
+			return float32(v)
+		}
+	}
+	if c, ok := parseRGBA(word); ok {
+		return c
+	}
+	return word
+}
+
+// parseRGBA parses a #rrggbbaa color.
+func parseRGBA(word string) (RGBA, bool) {
+	if len(word) != 9 || word[0] != '#' {
+		return RGBA{}, false
+	}
+	b, err := hex.DecodeString(word[1:])
+	if err != nil {
+		return RGBA{}, false
+	}
+	return RGBA{b[0], b[1], b[2], b[3]}, true
+}
+
+// parseHex parses 0x-prefixed hex bytes.
+func parseHex(tok textToken) ([]byte, bool) {
+	if tok.kind != textWord || !strings.HasPrefix(tok.text, "0x") {
+		return nil, false
+	}
+	b, err := hex.DecodeString(tok.text[2:])
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// parseTimetag parses "immediate", an RFC 3339 time or a raw 64-bit time tag.
+func parseTimetag(word string) (Timetag, error) {
+	if word == "immediate" {
+		return *NewTimetag(time.Time{}), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, word); err == nil {
+		return *NewTimetag(t), nil
+	}
+	if v, err := strconv.ParseUint(word, 0, 64); err == nil {
+		return *NewTimetagFromTimetag(v), nil
+	}
+	return Timetag{}, fmt.Errorf("invalid time tag %q", word)
+}
@@ -0,0 +1,102 @@
+package osc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTextRoundTrip(t *testing.T) {
+	nested := NewBundle(time.Date(2024, 1, 1, 20, 0, 0, 500, time.UTC))
+	nested.Append(NewMessage("/eos/key/go_0"))
+	nested.Append(eosBundle())
+	immediate := &Bundle{Timetag: *NewTimetagFromTimetag(1)}
+	immediate.Append(NewMessage("/eos/cmd", "Chan 1 Full#"))
+
+	for _, tt := range []struct {
+		name   string
+		packet Packet
+		text   string
+	}{
+		{name: "no arguments", packet: NewMessage("/eos/ping"), text: "/eos/ping"},
+		{
+			name:   "eos wheel",
+			packet: eosWheelMessage(),
+			text:   `/eos/out/active/wheel/1 ,sif "Pan  [127]" 1 127.0`,
+		},
+		{
+			name: "all types",
+			packet: NewMessage("/t", int32(-1), int64(1)<<40, float32(0.5), 2.25, "a \"b\"", Symbol("s"),
+				[]byte{0xde, 0xad}, true, false, nil, Infinitum{}, Char('x'), RGBA{1, 2, 3, 4},
+				MIDIMessage{0, 0x90, 60, 127}, []interface{}{int32(1), []interface{}{"z"}}),
+			text: `/t ,ihfdsSbTFNIcrm[i[s]] -1 1099511627776 0.5 2.25 "a \"b\"" "s" 0xdead 'x' #01020304 0x00903c7f 1 "z"`,
+		},
+		{name: "immediate bundle", packet: immediate, text: `#bundle immediate { /eos/cmd ,s "Chan 1 Full#" }`},
+		{name: "nested bundle", packet: nested},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := FormatText(tt.packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.text != "" && text != tt.text {
+				t.Errorf("FormatText = %s; want %s", text, tt.text)
+			}
+
+			got, err := ParseText(text)
+			if err != nil {
+				t.Fatalf("ParseText(%s): %v", text, err)
+			}
+			if !bytes.Equal(mustMarshal(t, got), mustMarshal(t, tt.packet)) {
+				t.Errorf("ParseText(%s) = %v; want %v", text, got, tt.packet)
+			}
+		})
+	}
+}
+
+func TestParseTextInferred(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want *Message
+	}{
+		{text: "/eos/cmd Chan", want: NewMessage("/eos/cmd", "Chan")},
+		{text: `/eos/cmd "Chan 1" 5`, want: NewMessage("/eos/cmd", "Chan 1", int32(5))},
+		{text: "/eos/fader/1/1 0.5", want: NewMessage("/eos/fader/1/1", float32(0.5))},
+		{text: "/t 5000000000", want: NewMessage("/t", int64(5000000000))},
+		{text: "/t true false nil", want: NewMessage("/t", true, false, nil)},
+		{text: "/t 'c' #ff000080", want: NewMessage("/t", Char('c'), RGBA{R: 0xff, A: 0x80})},
+		{text: "/t [ 1 [ a ] ]", want: NewMessage("/t", []interface{}{int32(1), []interface{}{"a"}})},
+	} {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseText(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg, ok := got.(*Message); !ok || !msg.Equals(tt.want) {
+				t.Errorf("ParseText = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTextInvalid(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"eos/ping",
+		`/eos/cmd "unterminated`,
+		"/t ,i",
+		"/t ,i x",
+		"/t ,ii 1",
+		"/t ,x 1",
+		"/t ,[i 1",
+		"/t [ 1",
+		"/t ,b 0xz",
+		"#bundle soon { /a }",
+		"#bundle immediate { /a",
+		"/a; /b",
+	} {
+		if p, err := ParseText(text); err == nil {
+			t.Errorf("ParseText(%q) = %v; want an error", text, p)
+		}
+	}
+}
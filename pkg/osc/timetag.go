@@ -37,6 +37,12 @@ func NewTimetag(ts time.Time) *Timetag {
 		MinValue: uint64(1)}
 }
 
+// NewTimetagNow returns a new OSC time tag set to the current time of
+// `clock`.
+func NewTimetagNow(clock Clock) *Timetag {
+	return NewTimetag(clock.Now())
+}
+
 // NewTimetagFromTimetag creates a new Timetag from the given `timetag`.
 func NewTimetagFromTimetag(timetag uint64) *Timetag {
 	time := timetagToTime(timetag)
@@ -84,6 +90,11 @@ func (t *Timetag) SetTime(time time.Time) {
 // same as the value of the time tag. It returns zero if the value of the
 // time tag is in the past.
 func (t *Timetag) ExpiresIn() time.Duration {
+	return t.ExpiresInClock(SystemClock())
+}
+
+// ExpiresInClock is like ExpiresIn, but takes the current time from `clock`.
+func (t *Timetag) ExpiresInClock(clock Clock) time.Duration {
 	// If the timetag is one the OSC method must be invoke immediately.
 	// See https://ccrma.stanford.edu/groups/osc/spec-1_0.html#timetags.
 	if t.timeTag <= 1 {
@@ -91,7 +102,7 @@ func (t *Timetag) ExpiresIn() time.Duration {
 	}
 
 	tt := timetagToTime(t.timeTag)
-	seconds := time.Until(tt)
+	seconds := tt.Sub(clock.Now())
 
 	// Invoke the OSC method immediately if the timetag is before or equal to the current time
 	if seconds <= 0 {
@@ -0,0 +1,406 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+////
+// TrieDispatcher
+////
+
+// TrieDispatcher is a dispatcher for OSC packets that indexes its handlers by
+// address part, so the cost of dispatching a message depends on the length of
+// its address rather than on the number of handlers. Addresses are matched
+// using OSC address pattern semantics (see Pattern).
+//
+// Handlers can be added, replaced and removed at any time, including from
+// within a handler. All handlers matching a message are called one after
+// another in a stable order:
+//  1. the handler registered for the exact address,
+//  2. handlers whose address contains wildcards, in registration order,
+//  3. handlers whose address contains the '//' wildcard, in registration order,
+//  4. prefix handlers, from the longest to the shortest prefix,
+//  5. the default handler.
+//
+// Replacing a handler keeps its position in that order.
+type TrieDispatcher struct {
+	mu             sync.RWMutex
+	root           *trieNode
+	handlers       map[string]*trieEntry
+	prefixes       map[string]*trieEntry
+	traversal      []*trieEntry
+	defaultHandler Handler
+	seq            uint64
+	scheduler      *Scheduler
+}
+
+// Verify that TrieDispatcher implements the Dispatcher interface.
+var _ Dispatcher = (*TrieDispatcher)(nil)
+
+type entryClass int
+
+const (
+	classExact entryClass = iota
+	classWildcard
+	classTraversal
+	classPrefix
+)
+
+// trieEntry is a registered handler.
+type trieEntry struct {
+	addr    string
+	handler Handler
+	class   entryClass
+	depth   int // number of address parts of a prefix
+	seq     uint64
+	pattern *Pattern
+}
+
+// trieNode holds the handlers for one address part.
+type trieNode struct {
+	children map[string]*trieNode
+	wild     map[string][]patternToken // compiled address parts of wildcard children
+	handler  *trieEntry
+	prefix   *trieEntry
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: map[string]*trieNode{}, wild: map[string][]patternToken{}}
+}
+
+func (n *trieNode) empty() bool {
+	return n.handler == nil && n.prefix == nil && len(n.children) == 0
+}
+
+// NewTrieDispatcher returns an empty TrieDispatcher.
+func NewTrieDispatcher() *TrieDispatcher {
+	d := &TrieDispatcher{
+		root:     newTrieNode(),
+		handlers: map[string]*trieEntry{},
+		prefixes: map[string]*trieEntry{},
+	}
+	d.scheduler = NewScheduler(d.dispatchMessage)
+	return d
+}
+
+// Scheduler returns the scheduler that dispatches received bundles, e.g. to
+// change its late policy.
+func (d *TrieDispatcher) Scheduler() *Scheduler {
+	return d.scheduler
+}
+
+// AddMsgHandler adds a new message handler for the given OSC address. It is an
+// error to add a second handler for the same address; use SetMsgHandler to
+// replace a handler. The address "*" installs the default handler, which
+// receives every message.
+func (d *TrieDispatcher) AddMsgHandler(oscAddr string, handler HandlerFunc) error {
+	return d.addMsgHandler(oscAddr, handler, false)
+}
+
+// SetMsgHandler adds a message handler for the given OSC address, replacing
+// any handler already registered for exactly that address.
+func (d *TrieDispatcher) SetMsgHandler(oscAddr string, handler HandlerFunc) error {
+	return d.addMsgHandler(oscAddr, handler, true)
+}
+
+// RemoveMsgHandler removes the message handler registered for the given OSC
+// address. It returns false if there was none.
+func (d *TrieDispatcher) RemoveMsgHandler(oscAddr string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if oscAddr == "*" {
+		found := d.defaultHandler != nil
+		d.defaultHandler = nil
+		return found
+	}
+
+	e, ok := d.handlers[oscAddr]
+	if !ok {
+		return false
+	}
+	delete(d.handlers, oscAddr)
+
+	if e.class == classTraversal {
+		for i, t := range d.traversal {
+			if t == e {
+				d.traversal = append(d.traversal[:i], d.traversal[i+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	d.removeNode(d.root, splitAddress(oscAddr), func(n *trieNode) { n.handler = nil })
+	return true
+}
+
+// Addresses returns the addresses of the message handlers, sorted. Prefix
+// handlers and the default handler are not included.
+func (d *TrieDispatcher) Addresses() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	addrs := make([]string, 0, len(d.handlers))
+	for addr := range d.handlers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// AddPrefixHandler adds a handler that receives every message whose address
+// is `prefix` or lies below it, e.g. "/eos/out" receives "/eos/out/ping" and
+// "/eos/out/active/wheel/1", as well as incoming patterns that address it or
+// anything below it, such as "/eos/out/*". Prefix parts may contain OSC
+// wildcards, but not '//'. An existing handler for the same prefix is
+// replaced.
+func (d *TrieDispatcher) AddPrefixHandler(prefix string, handler HandlerFunc) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		prefix = "/"
+	}
+	if _, err := CompilePattern(prefix); err != nil {
+		return err
+	}
+	if strings.Contains(prefix, "//") {
+		return errors.New("OSC prefix must not contain '//'")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	parts := splitAddress(prefix)
+	n := d.insertNode(parts)
+	e := &trieEntry{addr: prefix, handler: handler, class: classPrefix, depth: len(parts)}
+	if n.prefix != nil {
+		e.seq = n.prefix.seq
+	} else {
+		d.seq++
+		e.seq = d.seq
+	}
+	n.prefix = e
+	d.prefixes[prefix] = e
+	return nil
+}
+
+// RemovePrefixHandler removes the prefix handler registered for `prefix`. It
+// returns false if there was none.
+func (d *TrieDispatcher) RemovePrefixHandler(prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		prefix = "/"
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.prefixes[prefix]; !ok {
+		return false
+	}
+	delete(d.prefixes, prefix)
+	d.removeNode(d.root, splitAddress(prefix), func(n *trieNode) { n.prefix = nil })
+	return true
+}
+
+// Dispatch dispatches OSC packets. Implements the Dispatcher interface.
+func (d *TrieDispatcher) Dispatch(packet Packet, addr net.Addr) {
+	switch p := packet.(type) {
+	default:
+		return
+
+	case *Message:
+		d.dispatchMessage(p, addr)
+
+	case *Bundle:
+		d.scheduler.Schedule(p, addr)
+	}
+}
+
+// dispatchMessage calls every handler matching the message in priority order.
+// The handlers are collected under the read lock and called without it, so
+// they may modify the dispatcher.
+func (d *TrieDispatcher) dispatchMessage(msg *Message, addr net.Addr) {
+	d.mu.RLock()
+	entries := d.match(msg.Address)
+	defaultHandler := d.defaultHandler
+	d.mu.RUnlock()
+
+	for _, e := range entries {
+		e.handler.HandleMessage(msg, addr)
+	}
+	if defaultHandler != nil {
+		defaultHandler.HandleMessage(msg, addr)
+	}
+}
+
+// match returns the entries for `oscAddr` in priority order.
+func (d *TrieDispatcher) match(oscAddr string) []*trieEntry {
+	var entries []*trieEntry
+
+	if IsPattern(oscAddr) {
+		// An incoming address pattern is matched against the literal handler
+		// addresses. A prefix handler receives it if the pattern addresses the
+		// prefix or anything below it.
+		incoming, err := CompilePattern(oscAddr)
+		if err != nil {
+			return nil
+		}
+		for a, e := range d.handlers {
+			if e.class == classExact && incoming.Match(a) {
+				entries = append(entries, e)
+			}
+		}
+		for a, e := range d.prefixes {
+			if !IsPattern(a) && incoming.matchPrefix(splitAddress(a)) {
+				entries = append(entries, e)
+			}
+		}
+	} else {
+		entries = d.root.collect(splitAddress(oscAddr), entries)
+		for _, e := range d.traversal {
+			if e.pattern.Match(oscAddr) {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.class != b.class {
+			return a.class < b.class
+		}
+		if a.depth != b.depth {
+			return a.depth > b.depth
+		}
+		return a.seq < b.seq
+	})
+	return entries
+}
+
+// collect appends the entries below `n` that match the address parts.
+func (n *trieNode) collect(parts []string, entries []*trieEntry) []*trieEntry {
+	if n.prefix != nil {
+		entries = append(entries, n.prefix)
+	}
+	if len(parts) == 0 {
+		if n.handler != nil {
+			entries = append(entries, n.handler)
+		}
+		return entries
+	}
+
+	if child, ok := n.children[parts[0]]; ok {
+		entries = child.collect(parts[1:], entries)
+	}
+	for key, tokens := range n.wild {
+		if matchTokens(tokens, parts[0]) {
+			entries = n.children[key].collect(parts[1:], entries)
+		}
+	}
+	return entries
+}
+
+func (d *TrieDispatcher) addMsgHandler(oscAddr string, handler HandlerFunc, replace bool) error {
+	if oscAddr == "*" {
+		d.mu.Lock()
+		d.defaultHandler = handler
+		d.mu.Unlock()
+		return nil
+	}
+
+	pattern, err := CompilePattern(oscAddr)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Entries are never modified once added, as Dispatch calls them without
+	// holding the lock. A replacement takes over the sequence number of the
+	// entry it replaces.
+	e := &trieEntry{addr: oscAddr, handler: handler, class: classExact}
+	old, ok := d.handlers[oscAddr]
+	switch {
+	case ok && !replace:
+		return errors.New("OSC address exists already")
+	case ok:
+		e.seq = old.seq
+	default:
+		d.seq++
+		e.seq = d.seq
+	}
+
+	switch {
+	case strings.Contains(oscAddr, "//"):
+		e.class = classTraversal
+		e.pattern = pattern
+		if ok {
+			for i, t := range d.traversal {
+				if t == old {
+					d.traversal[i] = e
+				}
+			}
+		} else {
+			d.traversal = append(d.traversal, e)
+		}
+	case !pattern.literal:
+		e.class = classWildcard
+		fallthrough
+	default:
+		d.insertNode(splitAddress(oscAddr)).handler = e
+	}
+	d.handlers[oscAddr] = e
+	return nil
+}
+
+// insertNode returns the node for the address parts, creating it if needed.
+func (d *TrieDispatcher) insertNode(parts []string) *trieNode {
+	n := d.root
+	for _, part := range parts {
+		child, ok := n.children[part]
+		if !ok {
+			child = newTrieNode()
+			n.children[part] = child
+			if IsPattern(part) {
+				// The part was validated by CompilePattern
+				n.wild[part], _ = compilePart(part)
+			}
+		}
+		n = child
+	}
+	return n
+}
+
+// removeNode applies `clear` to the node for the address parts and prunes
+// nodes that are left empty.
+func (d *TrieDispatcher) removeNode(n *trieNode, parts []string, clear func(*trieNode)) {
+	if len(parts) == 0 {
+		clear(n)
+		return
+	}
+	child, ok := n.children[parts[0]]
+	if !ok {
+		return
+	}
+	d.removeNode(child, parts[1:], clear)
+	if child.empty() {
+		delete(n.children, parts[0])
+		delete(n.wild, parts[0])
+	}
+}
+
+// splitAddress splits an OSC address into its parts. The root address "/" has
+// no parts.
+func splitAddress(oscAddr string) []string {
+	oscAddr = strings.TrimPrefix(oscAddr, "/")
+	if oscAddr == "" {
+		return nil
+	}
+	return strings.Split(oscAddr, "/")
+}
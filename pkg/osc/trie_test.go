@@ -0,0 +1,242 @@
+package osc
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+// trieRecorder registers handlers on a TrieDispatcher that record their
+// names when called.
+type trieRecorder struct {
+	d      *TrieDispatcher
+	called []string
+}
+
+func newTrieRecorder() *trieRecorder {
+	return &trieRecorder{d: NewTrieDispatcher()}
+}
+
+func (r *trieRecorder) handler(name string) HandlerFunc {
+	return func(*Message, net.Addr) {
+		r.called = append(r.called, name)
+	}
+}
+
+// dispatch dispatches a message to `addr` and returns the handlers called.
+func (r *trieRecorder) dispatch(addr string) []string {
+	r.called = nil
+	r.d.Dispatch(NewMessage(addr), nil)
+	return r.called
+}
+
+func TestTrieDispatcherOrder(t *testing.T) {
+	r := newTrieRecorder()
+	add := func(addr, name string) {
+		t.Helper()
+		if err := r.d.AddMsgHandler(addr, r.handler(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addPrefix := func(prefix, name string) {
+		t.Helper()
+		if err := r.d.AddPrefixHandler(prefix, r.handler(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Registered out of order on purpose
+	add("*", "default")
+	addPrefix("/eos", "prefix /eos")
+	add("//1", "traversal")
+	add("/eos/out/fader/*/1", "wildcard 2")
+	add("/eos/out/fader/1/1", "exact")
+	addPrefix("/eos/out/fader", "prefix /eos/out/fader")
+	add("/eos/out/*/1/?", "wildcard 1")
+	add("/eos/out/fader/1/[0-9]", "wildcard 3")
+
+	for _, tt := range []struct {
+		addr string
+		want []string
+	}{
+		{
+			addr: "/eos/out/fader/1/1",
+			want: []string{
+				"exact",
+				"wildcard 2", "wildcard 1", "wildcard 3",
+				"traversal",
+				"prefix /eos/out/fader", "prefix /eos",
+				"default",
+			},
+		},
+		{
+			addr: "/eos/out/fader/2/1",
+			want: []string{"wildcard 2", "traversal", "prefix /eos/out/fader", "prefix /eos", "default"},
+		},
+		{
+			addr: "/eos/ping",
+			want: []string{"prefix /eos", "default"},
+		},
+		{
+			addr: "/qlab/1",
+			want: []string{"traversal", "default"},
+		},
+		{
+			// Incoming patterns match literal handler addresses, and the
+			// prefixes of the addresses they match
+			addr: "/eos/out/fader/[1-2]/1",
+			want: []string{"exact", "prefix /eos/out/fader", "prefix /eos", "default"},
+		},
+		{
+			addr: "/eos/out/{fader,ping}",
+			want: []string{"prefix /eos/out/fader", "prefix /eos", "default"},
+		},
+		{
+			addr: "/eos/out/*",
+			want: []string{"prefix /eos/out/fader", "prefix /eos", "default"},
+		},
+		{
+			addr: "/eos/*/ping",
+			want: []string{"prefix /eos", "default"},
+		},
+		{
+			addr: "//fader/2",
+			want: []string{"prefix /eos/out/fader", "prefix /eos", "default"},
+		},
+		{
+			addr: "/qlab/*",
+			want: []string{"default"},
+		},
+	} {
+		t.Run(tt.addr, func(t *testing.T) {
+			for range 10 {
+				if got := r.dispatch(tt.addr); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("called %q; want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrieDispatcherPrefixPattern(t *testing.T) {
+	r := newTrieRecorder()
+	if err := r.d.AddPrefixHandler("/eos/out", r.handler("prefix")); err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]bool{
+		"/eos/out/*":         true,
+		"/eos/out/fader/?/1": true,
+		"/eos/o?t":           true,
+		"/eos/*":             true,
+		"/eos//wheel/1":      true,
+		"/eos/{out,in}/ping": true,
+		"/*":                 false,
+		"/eos/in/*":          false,
+		"/eos/output/*":      false,
+		"/eos/[!o]*/ping":    false,
+	} {
+		if got := len(r.dispatch(addr)) == 1; got != want {
+			t.Errorf("%s reached the prefix: %v; want %v", addr, got, want)
+		}
+	}
+}
+
+func TestTrieDispatcherReplace(t *testing.T) {
+	r := newTrieRecorder()
+	for _, addr := range []string{"/a/*", "/a/[ab]", "/?/b"} {
+		if err := r.d.AddMsgHandler(addr, r.handler(addr)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.d.AddMsgHandler("/a/*", r.handler("again")); err == nil {
+		t.Error("AddMsgHandler of an existing address: no error")
+	}
+
+	// Replacing keeps the position
+	if err := r.d.SetMsgHandler("/a/*", r.handler("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.dispatch("/a/b"), []string{"replaced", "/a/[ab]", "/?/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("called %q; want %q", got, want)
+	}
+}
+
+func TestTrieDispatcherRemove(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		remove string
+		want   []string
+	}{
+		{name: "exact", remove: "/eos/key/go_0", want: []string{"/eos/key/*", "//go_0"}},
+		{name: "wildcard", remove: "/eos/key/*", want: []string{"/eos/key/go_0", "//go_0"}},
+		{name: "traversal", remove: "//go_0", want: []string{"/eos/key/go_0", "/eos/key/*"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTrieRecorder()
+			for _, addr := range []string{"/eos/key/go_0", "/eos/key/*", "//go_0"} {
+				if err := r.d.AddMsgHandler(addr, r.handler(addr)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !r.d.RemoveMsgHandler(tt.remove) {
+				t.Fatalf("RemoveMsgHandler(%q) = false", tt.remove)
+			}
+			if r.d.RemoveMsgHandler(tt.remove) {
+				t.Errorf("RemoveMsgHandler(%q) twice = true", tt.remove)
+			}
+			if got := r.dispatch("/eos/key/go_0"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("called %q; want %q", got, tt.want)
+			}
+			for _, addr := range r.d.Addresses() {
+				if addr == tt.remove {
+					t.Errorf("Addresses still lists %q", addr)
+				}
+			}
+
+			// The address can be added again
+			if err := r.d.AddMsgHandler(tt.remove, r.handler("again")); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestTrieDispatcherRemovePrefix(t *testing.T) {
+	r := newTrieRecorder()
+	if err := r.d.AddPrefixHandler("/eos/out/", r.handler("prefix")); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.dispatch("/eos/out/ping"); !reflect.DeepEqual(got, []string{"prefix"}) {
+		t.Errorf("called %q; want [prefix]", got)
+	}
+	if !r.d.RemovePrefixHandler("/eos/out") {
+		t.Fatal("RemovePrefixHandler = false")
+	}
+	if r.d.RemovePrefixHandler("/eos/out") {
+		t.Error("RemovePrefixHandler twice = true")
+	}
+	if got := r.dispatch("/eos/out/ping"); got != nil {
+		t.Errorf("called %q after removal", got)
+	}
+}
+
+func TestTrieDispatcherModifyFromHandler(t *testing.T) {
+	d := NewTrieDispatcher()
+	calls := 0
+	if err := d.AddMsgHandler("/once", func(*Message, net.Addr) {
+		calls++
+		d.RemoveMsgHandler("/once")
+		d.AddMsgHandler("/other", func(*Message, net.Addr) {})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	d.Dispatch(NewMessage("/once"), nil)
+	d.Dispatch(NewMessage("/once"), nil)
+	if calls != 1 {
+		t.Errorf("handler called %d times; want 1", calls)
+	}
+	if got, want := d.Addresses(), []string{"/other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Addresses = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import "fmt"
+
+// Char is an OSC ASCII character argument (type tag 'c'). It is sent as a
+// 32-bit value.
+type Char rune
+
+// String implements the fmt.Stringer interface.
+func (c Char) String() string {
+	return string(rune(c))
+}
+
+// Symbol is an OSC symbol argument (type tag 'S'). It is encoded like a
+// string, but tells the receiver to treat the value as an identifier.
+type Symbol string
+
+// RGBA is an OSC 32-bit RGBA color argument (type tag 'r').
+type RGBA struct {
+	R, G, B, A uint8
+}
+
+// String implements the fmt.Stringer interface.
+func (c RGBA) String() string {
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// MIDIMessage is an OSC 4-byte MIDI message argument (type tag 'm'). Port is
+// the MIDI port id, and Status, Data1 and Data2 form the MIDI message itself.
+type MIDIMessage struct {
+	Port, Status, Data1, Data2 byte
+}
+
+// String implements the fmt.Stringer interface.
+func (m MIDIMessage) String() string {
+	return fmt.Sprintf("%02X %02X %02X %02X", m.Port, m.Status, m.Data1, m.Data2)
+}
+
+// Infinitum is the OSC impulse argument (type tag 'I'), also known as
+// "bang" or infinitum. It carries no data.
+type Infinitum struct{}
+
+// String implements the fmt.Stringer interface.
+func (Infinitum) String() string {
+	return "Infinitum"
+}
@@ -0,0 +1,110 @@
+package osc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTypeTags(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		arg  interface{}
+		tags string
+		data []byte
+	}{
+		{name: "char", arg: Char('A'), tags: ",c", data: []byte{0, 0, 0, 'A'}},
+		{name: "rgba", arg: RGBA{R: 1, G: 2, B: 3, A: 4}, tags: ",r", data: []byte{1, 2, 3, 4}},
+		{name: "midi", arg: MIDIMessage{Port: 0, Status: 0x90, Data1: 60, Data2: 127}, tags: ",m", data: []byte{0, 0x90, 60, 127}},
+		{name: "symbol", arg: Symbol("go"), tags: ",S", data: []byte("go\x00\x00")},
+		{name: "infinitum", arg: Infinitum{}, tags: ",I"},
+		{name: "true", arg: true, tags: ",T"},
+		{name: "nil", arg: nil, tags: ",N"},
+		{name: "int64", arg: int64(-2), tags: ",h", data: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}},
+		{name: "empty array", arg: []interface{}{}, tags: ",[]"},
+		{
+			name: "array",
+			arg:  []interface{}{int32(1), "a", Infinitum{}},
+			tags: ",[isI]",
+			data: []byte{0, 0, 0, 1, 'a', 0, 0, 0},
+		},
+		{
+			name: "nested array",
+			arg:  []interface{}{[]interface{}{Char('x')}, float32(0)},
+			tags: ",[[c]f]",
+			data: []byte{0, 0, 0, 'x', 0, 0, 0, 0},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := NewMessage("/t", tt.arg)
+			tags, err := msg.TypeTags()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tags != tt.tags {
+				t.Errorf("TypeTags = %q; want %q", tags, tt.tags)
+			}
+
+			data, err := msg.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append([]byte("/t\x00\x00"), appendPaddedString(nil, tt.tags)...)
+			want = append(want, tt.data...)
+			if !bytes.Equal(data, want) {
+				t.Errorf("MarshalBinary = %q; want %q", data, want)
+			}
+
+			for _, strict := range []bool{false, true} {
+				p, err := DecodeOptions{Strict: strict}.ParsePacket(data)
+				if err != nil {
+					t.Fatalf("ParsePacket (strict %v): %v", strict, err)
+				}
+				if got := p.(*Message); !got.Equals(msg) {
+					t.Errorf("ParsePacket (strict %v) = %v; want %v", strict, got, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestTypeTagsTimetag(t *testing.T) {
+	tag := *NewTimetag(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	data, err := NewMessage("/t", tag).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePacketBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := p.(*Message).Arguments[0].(Timetag)
+	if !ok || got.TimeTag() != tag.TimeTag() {
+		t.Errorf("decoded %v; want time tag %d", p, tag.TimeTag())
+	}
+}
+
+func TestTypeTagsUnsupported(t *testing.T) {
+	for _, arg := range []interface{}{int(1), uint32(1), []interface{}{struct{}{}}} {
+		if _, err := NewMessage("/t", arg).MarshalBinary(); err == nil {
+			t.Errorf("MarshalBinary of %T: no error", arg)
+		}
+	}
+}
+
+func TestDecodeArrayInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+	}{
+		{name: "unclosed", data: "/t\x00\x00,[i\x00\x00\x00\x00\x01"},
+		{name: "unopened", data: "/t\x00\x00,i]\x00\x00\x00\x00\x01"},
+		{name: "unknown tag", data: "/t\x00\x00,x\x00\x00"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if p, err := ParsePacket(tt.data); err == nil {
+				t.Errorf("ParsePacket = %v; want an error", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Package osc provides a package for sending and receiving OpenSoundControl
+// messages. The package is implemented in pure Go.
+package osc
+
+import (
+	"hash/fnv"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// DispatchMode selects how received packets are handed to the Dispatcher.
+type DispatchMode int
+
+const (
+	// DispatchUnordered dispatches every packet on its own goroutine. Packets
+	// may be handled in any order, and a burst of packets creates as many
+	// goroutines.
+	DispatchUnordered DispatchMode = iota
+	// DispatchSerial dispatches packets one at a time, in the order they
+	// arrived.
+	DispatchSerial
+	// DispatchPerAddress dispatches packets on a fixed pool of workers.
+	// Packets with the same address are always handled by the same worker,
+	// so they are handled in the order they arrived.
+	DispatchPerAddress
+)
+
+const (
+	defaultDispatchQueueSize = 256
+)
+
+// DispatchOptions configures how a connection or server dispatches received
+// packets. The zero value selects DispatchUnordered.
+type DispatchOptions struct {
+	Mode DispatchMode
+	// Workers is the number of workers for DispatchPerAddress. It defaults to
+	// the number of CPUs.
+	Workers int
+	// QueueSize is the number of packets each worker can hold before the
+	// queue is full. It defaults to 256.
+	QueueSize int
+	// DropWhenFull drops packets that arrive while the queue of their worker
+	// is full, instead of making the reader wait for space.
+	DropWhenFull bool
+	// OnDrop, if set, is called with every packet that is dropped.
+	OnDrop func(packet Packet, addr net.Addr)
+}
+
+// DispatchStats reports how received packets were dispatched.
+type DispatchStats struct {
+	// Dispatched is the number of packets handed to the Dispatcher.
+	Dispatched uint64
+	// Dropped is the number of packets dropped because their queue was full.
+	Dropped uint64
+	// Stalled is the number of packets the reader had to wait for because
+	// their queue was full.
+	Stalled uint64
+	// Queued is the number of packets currently waiting for a worker.
+	Queued int64
+}
+
+// dispatchCounters holds the live values of DispatchStats.
+type dispatchCounters struct {
+	dispatched atomic.Uint64
+	dropped    atomic.Uint64
+	stalled    atomic.Uint64
+	queued     atomic.Int64
+}
+
+func (c *dispatchCounters) stats() DispatchStats {
+	return DispatchStats{
+		Dispatched: c.dispatched.Load(),
+		Dropped:    c.dropped.Load(),
+		Stalled:    c.stalled.Load(),
+		Queued:     c.queued.Load(),
+	}
+}
+
+type queuedPacket struct {
+	packet Packet
+	addr   net.Addr
+}
+
+// dispatchPool hands packets to a Dispatcher according to DispatchOptions.
+// Every goroutine it starts is tracked by `wg`.
+type dispatchPool struct {
+	dispatcher Dispatcher
+	opts       DispatchOptions
+	counters   *dispatchCounters
+	wg         *sync.WaitGroup
+	queues     []chan queuedPacket
+}
+
+// newDispatchPool starts the workers needed for the given options.
+func newDispatchPool(d Dispatcher, opts DispatchOptions, counters *dispatchCounters, wg *sync.WaitGroup) *dispatchPool {
+	p := &dispatchPool{dispatcher: d, opts: opts, counters: counters, wg: wg}
+
+	workers := 0
+	switch opts.Mode {
+	case DispatchSerial:
+		workers = 1
+	case DispatchPerAddress:
+		workers = opts.Workers
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+	}
+	size := opts.QueueSize
+	if size <= 0 {
+		size = defaultDispatchQueueSize
+	}
+
+	for i := 0; i < workers; i++ {
+		q := make(chan queuedPacket, size)
+		p.queues = append(p.queues, q)
+		wg.Add(1)
+		go p.work(q)
+	}
+	return p
+}
+
+// submit queues a packet for dispatching.
+func (p *dispatchPool) submit(packet Packet, addr net.Addr) {
+	if len(p.queues) == 0 {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.counters.dispatched.Add(1)
+			p.dispatcher.Dispatch(packet, addr)
+		}()
+		return
+	}
+
+	q := p.queues[0]
+	if len(p.queues) > 1 {
+		h := fnv.New32a()
+		h.Write([]byte(packetAddress(packet)))
+		q = p.queues[h.Sum32()%uint32(len(p.queues))]
+	}
+
+	// Count the packet before a worker can take it, so Queued never goes
+	// negative
+	item := queuedPacket{packet: packet, addr: addr}
+	p.counters.queued.Add(1)
+	select {
+	case q <- item:
+		return
+	default:
+	}
+
+	if p.opts.DropWhenFull {
+		p.counters.queued.Add(-1)
+		p.counters.dropped.Add(1)
+		if p.opts.OnDrop != nil {
+			p.opts.OnDrop(packet, addr)
+		}
+		return
+	}
+	p.counters.stalled.Add(1)
+	q <- item
+}
+
+// close stops accepting packets. The workers finish the packets already
+// queued and exit.
+func (p *dispatchPool) close() {
+	for _, q := range p.queues {
+		close(q)
+	}
+}
+
+func (p *dispatchPool) work(q chan queuedPacket) {
+	defer p.wg.Done()
+	for item := range q {
+		p.counters.queued.Add(-1)
+		p.counters.dispatched.Add(1)
+		p.dispatcher.Dispatch(item.packet, item.addr)
+	}
+}
+
+// packetAddress returns the address used to order a packet: the address of a
+// message, or the address of the first message in a bundle.
+func packetAddress(packet Packet) string {
+	switch p := packet.(type) {
+	case *Message:
+		return p.Address
+	case *Bundle:
+		if len(p.Messages) > 0 {
+			return p.Messages[0].Address
+		}
+		if len(p.Bundles) > 0 {
+			return packetAddress(p.Bundles[0])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,226 @@
+package osc
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingDispatcher records the dispatched messages, and holds every
+// dispatch until it is released.
+type blockingDispatcher struct {
+	counters *dispatchCounters
+	started  chan string
+	release  chan struct{}
+
+	mu       sync.Mutex
+	addrs    []string
+	negative bool
+}
+
+func newBlockingDispatcher(counters *dispatchCounters) *blockingDispatcher {
+	return &blockingDispatcher{
+		counters: counters,
+		started:  make(chan string, 1024),
+		release:  make(chan struct{}),
+	}
+}
+
+func (d *blockingDispatcher) Dispatch(packet Packet, _ net.Addr) {
+	msg := packet.(*Message)
+	d.mu.Lock()
+	d.addrs = append(d.addrs, fmt.Sprint(msg.Address, msg.Arguments))
+	d.negative = d.negative || d.counters.queued.Load() < 0
+	d.mu.Unlock()
+	d.started <- msg.Address
+	<-d.release
+}
+
+// wait waits for a dispatch of `addr` to start.
+func (d *blockingDispatcher) wait(t *testing.T, addr string) {
+	t.Helper()
+	select {
+	case got := <-d.started:
+		if got != addr {
+			t.Fatalf("dispatching %s; want %s", got, addr)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%s not dispatched", addr)
+	}
+}
+
+// newTestPool returns a pool dispatching to a blockingDispatcher.
+func newTestPool(opts DispatchOptions) (*dispatchPool, *blockingDispatcher, *sync.WaitGroup) {
+	counters := &dispatchCounters{}
+	d := newBlockingDispatcher(counters)
+	wg := &sync.WaitGroup{}
+	return newDispatchPool(d, opts, counters, wg), d, wg
+}
+
+func TestDispatchOrder(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts DispatchOptions
+	}{
+		{name: "serial", opts: DispatchOptions{Mode: DispatchSerial, QueueSize: 4}},
+		{name: "per address", opts: DispatchOptions{Mode: DispatchPerAddress, Workers: 4, QueueSize: 4}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p, d, wg := newTestPool(tt.opts)
+			close(d.release)
+
+			var want []string
+			for i := 0; i < 100; i++ {
+				addr := fmt.Sprintf("/fader/%d", i%5)
+				p.submit(NewMessage(addr, int32(i)), nil)
+				want = append(want, fmt.Sprint(addr, []interface{}{int32(i)}))
+			}
+			p.close()
+			wg.Wait()
+
+			got := d.addrs
+			if tt.opts.Mode == DispatchPerAddress {
+				// Only the packets of each address are in order
+				got, want = byAddress(got), byAddress(want)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("dispatched %v; want %v", got, want)
+			}
+			if d.negative {
+				t.Error("Queued went negative")
+			}
+			if stats := p.counters.stats(); stats.Dispatched != 100 || stats.Queued != 0 {
+				t.Errorf("stats %+v", stats)
+			}
+		})
+	}
+}
+
+// byAddress groups dispatched messages by address, keeping their order.
+func byAddress(dispatched []string) []string {
+	groups := map[string][]string{}
+	for _, s := range dispatched {
+		addr := s[:len("/fader/0")]
+		groups[addr] = append(groups[addr], s)
+	}
+	var out []string
+	for i := 0; i < 5; i++ {
+		out = append(out, groups[fmt.Sprintf("/fader/%d", i)]...)
+	}
+	return out
+}
+
+func TestDispatchDropWhenFull(t *testing.T) {
+	var mu sync.Mutex
+	var dropped []string
+	p, d, wg := newTestPool(DispatchOptions{
+		Mode:         DispatchSerial,
+		QueueSize:    1,
+		DropWhenFull: true,
+		OnDrop: func(packet Packet, _ net.Addr) {
+			mu.Lock()
+			defer mu.Unlock()
+			dropped = append(dropped, packet.(*Message).Address)
+		},
+	})
+
+	// One packet is dispatched, one waits in the queue, the rest are dropped
+	p.submit(NewMessage("/1"), nil)
+	d.wait(t, "/1")
+	p.submit(NewMessage("/2"), nil)
+	p.submit(NewMessage("/3"), nil)
+	p.submit(NewMessage("/4"), nil)
+	if want := (DispatchStats{Dispatched: 1, Dropped: 2, Queued: 1}); p.counters.stats() != want {
+		t.Errorf("stats %+v; want %+v", p.counters.stats(), want)
+	}
+	mu.Lock()
+	if want := []string{"/3", "/4"}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("dropped %v; want %v", dropped, want)
+	}
+	mu.Unlock()
+
+	close(d.release)
+	p.close()
+	wg.Wait()
+	if want := (DispatchStats{Dispatched: 2, Dropped: 2}); p.counters.stats() != want {
+		t.Errorf("stats %+v; want %+v", p.counters.stats(), want)
+	}
+}
+
+func TestDispatchStalled(t *testing.T) {
+	p, d, wg := newTestPool(DispatchOptions{Mode: DispatchSerial, QueueSize: 1})
+
+	p.submit(NewMessage("/1"), nil)
+	d.wait(t, "/1")
+	p.submit(NewMessage("/2"), nil)
+
+	// The queue is full, so the reader waits
+	submitted := make(chan struct{})
+	go func() {
+		defer close(submitted)
+		p.submit(NewMessage("/3"), nil)
+	}()
+	for deadline := time.Now().Add(time.Second); p.counters.stalled.Load() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("submit did not stall")
+		}
+	}
+	select {
+	case <-submitted:
+		t.Fatal("submit returned while the queue is full")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	d.release <- struct{}{}
+	d.wait(t, "/2")
+	<-submitted
+	if want := (DispatchStats{Dispatched: 2, Stalled: 1, Queued: 1}); p.counters.stats() != want {
+		t.Errorf("stats %+v; want %+v", p.counters.stats(), want)
+	}
+
+	close(d.release)
+	p.close()
+	wg.Wait()
+	if want := (DispatchStats{Dispatched: 3, Stalled: 1}); p.counters.stats() != want {
+		t.Errorf("stats %+v; want %+v", p.counters.stats(), want)
+	}
+}
+
+func TestDispatchUnordered(t *testing.T) {
+	p, d, wg := newTestPool(DispatchOptions{})
+
+	// Every packet is dispatched at once, without a queue
+	for i := 0; i < 3; i++ {
+		p.submit(NewMessage("/fader"), nil)
+		d.wait(t, "/fader")
+	}
+	if want := (DispatchStats{Dispatched: 3}); p.counters.stats() != want {
+		t.Errorf("stats %+v; want %+v", p.counters.stats(), want)
+	}
+	close(d.release)
+	p.close()
+	wg.Wait()
+}
+
+func TestPacketAddress(t *testing.T) {
+	nested := NewBundle(time.Time{})
+	nested.Append(NewBundle(time.Time{}))
+	nested.Bundles[0].Append(NewMessage("/inner"))
+
+	for _, tt := range []struct {
+		packet Packet
+		want   string
+	}{
+		{packet: NewMessage("/fader/1"), want: "/fader/1"},
+		{packet: bundleAt(time.Time{}, "/first", "/second"), want: "/first"},
+		{packet: nested, want: "/inner"},
+		{packet: NewBundle(time.Time{}), want: ""},
+	} {
+		if got := packetAddress(tt.packet); got != tt.want {
+			t.Errorf("packetAddress(%v) = %q; want %q", tt.packet, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package oscquery publishes the OSC address space of an application over
+// HTTP as described by the OSCQuery proposal
+// (https://github.com/Vidvox/OSCQueryProposal), so that tools can discover
+// the addresses, their types, ranges and current values.
+//
+// The WebSocket extension for listening to value changes is not supported;
+// clients poll the VALUE attribute instead.
+package oscquery
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+// Access tells whether the value of an address can be read, written or both.
+type Access int
+
+const (
+	AccessNone Access = iota
+	AccessRead
+	AccessWrite
+	AccessReadWrite
+)
+
+// Range limits one argument of an address. Min and Max, or Values listing
+// the allowed values, may be nil.
+type Range struct {
+	Min    interface{}   `json:"MIN,omitempty"`
+	Max    interface{}   `json:"MAX,omitempty"`
+	Values []interface{} `json:"VALS,omitempty"`
+}
+
+// Endpoint describes an OSC address.
+type Endpoint struct {
+	Address     string
+	Description string
+	// Type is the OSC type tag string of the arguments, without ','.
+	Type   string
+	Access Access
+	// Range holds one entry per argument, or is empty.
+	Range []Range
+	// Value, if set, returns the current arguments of the address.
+	Value func() []interface{}
+}
+
+// AddressLister is implemented by the dispatchers of the osc package. Its
+// addresses are published even if they have not been described.
+type AddressLister interface {
+	Addresses() []string
+}
+
+// Server serves the OSCQuery description of an address space. It implements
+// http.Handler; serve it on its own port, e.g. with http.ListenAndServe.
+type Server struct {
+	name      string
+	oscPort   int
+	transport string
+	lister    AddressLister
+
+	mu        sync.RWMutex
+	endpoints map[string]*Endpoint
+}
+
+// NewServer returns a Server for an application called `name` that receives
+// OSC over UDP on `oscPort`. The handler addresses of `lister`, if not nil,
+// are published as write-only endpoints unless described by Describe.
+func NewServer(name string, oscPort int, lister AddressLister) *Server {
+	return &Server{
+		name:      name,
+		oscPort:   oscPort,
+		transport: "UDP",
+		lister:    lister,
+		endpoints: map[string]*Endpoint{},
+	}
+}
+
+// SetTransport sets the OSC transport published in the host info, "UDP" or
+// "TCP".
+func (s *Server) SetTransport(transport string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transport = transport
+}
+
+// Describe adds the description of an endpoint, replacing any earlier one
+// for the same address.
+func (s *Server) Describe(ep Endpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endpoints[ep.Address] = &ep
+}
+
+// Remove removes the description of an address. It returns false if there
+// was none.
+func (s *Server) Remove(addr string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.endpoints[addr]
+	delete(s.endpoints, addr)
+	return ok
+}
+
+// node is a container or an address in the JSON description.
+type node struct {
+	FullPath    string           `json:"FULL_PATH"`
+	Description string           `json:"DESCRIPTION,omitempty"`
+	Access      *Access          `json:"ACCESS,omitempty"`
+	Type        string           `json:"TYPE,omitempty"`
+	Range       []Range          `json:"RANGE,omitempty"`
+	Value       []interface{}    `json:"VALUE,omitempty"`
+	Contents    map[string]*node `json:"CONTENTS,omitempty"`
+}
+
+// attributes lists the attributes that can be queried with "?NAME".
+var attributes = map[string]func(n *node) interface{}{
+	"FULL_PATH":   func(n *node) interface{} { return n.FullPath },
+	"CONTENTS":    func(n *node) interface{} { return nilIfEmpty(n.Contents) },
+	"DESCRIPTION": func(n *node) interface{} { return nilIfEmpty(n.Description) },
+	"ACCESS":      func(n *node) interface{} { return nilIfEmpty(n.Access) },
+	"TYPE":        func(n *node) interface{} { return nilIfEmpty(n.Type) },
+	"RANGE":       func(n *node) interface{} { return nilIfEmpty(n.Range) },
+	"VALUE":       func(n *node) interface{} { return nilIfEmpty(n.Value) },
+}
+
+// nilIfEmpty returns nil for empty attributes, so they are reported as
+// missing.
+func nilIfEmpty(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]*node:
+		if len(v) == 0 {
+			return nil
+		}
+	case string:
+		if v == "" {
+			return nil
+		}
+	case *Access:
+		if v == nil {
+			return nil
+		}
+	case []Range:
+		if len(v) == 0 {
+			return nil
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+	}
+	return v
+}
+
+// ServeHTTP answers OSCQuery requests: GET on an address returns its
+// description and that of everything below it, "?HOST_INFO" returns the
+// server information, and "?ATTRIBUTE" returns a single attribute.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.RawQuery
+	if query == "HOST_INFO" {
+		writeJSON(w, s.hostInfo())
+		return
+	}
+
+	n := s.tree().find(r.URL.Path)
+	if n == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if query == "" {
+		writeJSON(w, n)
+		return
+	}
+
+	attr, ok := attributes[query]
+	if !ok {
+		http.Error(w, "unknown attribute "+query, http.StatusBadRequest)
+		return
+	}
+	v := attr(n)
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, map[string]interface{}{query: v})
+}
+
+func (s *Server) hostInfo() interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return map[string]interface{}{
+		"NAME":          s.name,
+		"OSC_PORT":      s.oscPort,
+		"OSC_TRANSPORT": s.transport,
+		"EXTENSIONS": map[string]bool{
+			"ACCESS":       true,
+			"VALUE":        true,
+			"RANGE":        true,
+			"DESCRIPTION":  true,
+			"TAGS":         false,
+			"CLIPMODE":     false,
+			"UNIT":         false,
+			"CRITICAL":     false,
+			"LISTEN":       false,
+			"PATH_CHANGED": false,
+		},
+	}
+}
+
+// tree builds the description of the whole address space. The current
+// values are read while building it.
+func (s *Server) tree() *node {
+	var addrs []string
+	if s.lister != nil {
+		addrs = s.lister.Addresses()
+	}
+
+	s.mu.RLock()
+	endpoints := make([]*Endpoint, 0, len(s.endpoints))
+	for _, ep := range s.endpoints {
+		endpoints = append(endpoints, ep)
+	}
+	s.mu.RUnlock()
+
+	root := &node{FullPath: "/"}
+	for _, addr := range addrs {
+		// Patterns do not name a single address
+		if !strings.HasPrefix(addr, "/") || osc.IsPattern(addr) {
+			continue
+		}
+		n := root.insert(addr)
+		if n.Access == nil {
+			access := AccessWrite
+			n.Access = &access
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Address < endpoints[j].Address })
+	for _, ep := range endpoints {
+		n := root.insert(ep.Address)
+		access := ep.Access
+		n.Access = &access
+		n.Description = ep.Description
+		n.Type = ep.Type
+		n.Range = ep.Range
+		if ep.Value != nil && access&AccessRead != 0 {
+			n.Value = jsonValues(ep.Value())
+		}
+	}
+	return root
+}
+
+// insert returns the node for `addr`, creating it and its containers.
+func (n *node) insert(addr string) *node {
+	for _, part := range strings.Split(strings.Trim(addr, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if n.Contents == nil {
+			n.Contents = map[string]*node{}
+		}
+		child, ok := n.Contents[part]
+		if !ok {
+			child = &node{FullPath: strings.TrimSuffix(n.FullPath, "/") + "/" + part}
+			n.Contents[part] = child
+		}
+		n = child
+	}
+	return n
+}
+
+// find returns the node for the URL path `path`, or nil.
+func (n *node) find(path string) *node {
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if n = n.Contents[part]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// jsonValues converts OSC arguments to the JSON representation of OSCQuery.
+func jsonValues(args []interface{}) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case osc.RGBA:
+			values[i] = v.String()
+		case osc.Char:
+			values[i] = v.String()
+		case osc.Symbol:
+			values[i] = string(v)
+		case osc.Infinitum:
+			values[i] = nil
+		case osc.MIDIMessage:
+			values[i] = []int{int(v.Port), int(v.Status), int(v.Data1), int(v.Data2)}
+		case osc.Timetag:
+			values[i] = v.TimeTag()
+		case []interface{}:
+			values[i] = jsonValues(v)
+		default:
+			values[i] = v
+		}
+	}
+	return values
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,231 @@
+package oscquery
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+)
+
+// addressList is an AddressLister of fixed addresses.
+type addressList []string
+
+func (l addressList) Addresses() []string {
+	return l
+}
+
+// newTestServer serves a Server describing a small address space.
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	s := NewServer("test", 9000, addressList{"/xtouch/fader/1", "/xtouch/fader/2", "/xtouch/lcd/*", "relative"})
+	s.Describe(Endpoint{
+		Address:     "/xtouch/fader/1",
+		Description: "Motor fader 1",
+		Type:        "f",
+		Access:      AccessReadWrite,
+		Range:       []Range{{Min: 0, Max: 1}},
+		Value:       func() []interface{} { return []interface{}{float32(0.5)} },
+	})
+	s.Describe(Endpoint{
+		Address: "/xtouch/color",
+		Type:    "rc",
+		Access:  AccessRead,
+		Value:   func() []interface{} { return []interface{}{osc.RGBA{R: 0xff, A: 0x80}, osc.Char('x')} },
+	})
+	s.Describe(Endpoint{
+		Address: "/xtouch/encoder/1/mode",
+		Type:    "s",
+		Access:  AccessWrite,
+		Range:   []Range{{Values: []interface{}{"single", "fill"}}},
+		Value:   func() []interface{} { return []interface{}{"single"} },
+	})
+	hs := httptest.NewServer(s)
+	t.Cleanup(hs.Close)
+	return s, hs
+}
+
+// get requests `path` and returns the status and the decoded JSON body.
+func get(t *testing.T, hs *httptest.Server, path string) (int, map[string]interface{}) {
+	t.Helper()
+	resp, err := http.Get(hs.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("GET %s: Content-Type %q", path, ct)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("GET %s: %v: %s", path, err, body)
+	}
+	return resp.StatusCode, v
+}
+
+func TestServerTree(t *testing.T) {
+	_, hs := newTestServer(t)
+	status, root := get(t, hs, "/")
+	if status != http.StatusOK {
+		t.Fatalf("GET /: status %d", status)
+	}
+
+	// Patterns and relative addresses do not name a single address
+	xt := root["CONTENTS"].(map[string]interface{})
+	if len(xt) != 1 {
+		t.Fatalf("root contents %v; want only xtouch", xt)
+	}
+	contents := xt["xtouch"].(map[string]interface{})["CONTENTS"].(map[string]interface{})
+	var names []string
+	for name := range contents {
+		names = append(names, name)
+	}
+	if len(names) != 3 || contents["lcd"] != nil {
+		t.Errorf("/xtouch contents %q; want color, encoder and fader", names)
+	}
+
+	faders := contents["fader"].(map[string]interface{})["CONTENTS"].(map[string]interface{})
+	want := map[string]interface{}{
+		"FULL_PATH":   "/xtouch/fader/1",
+		"DESCRIPTION": "Motor fader 1",
+		"ACCESS":      float64(AccessReadWrite),
+		"TYPE":        "f",
+		"RANGE":       []interface{}{map[string]interface{}{"MIN": float64(0), "MAX": float64(1)}},
+		"VALUE":       []interface{}{0.5},
+	}
+	if got := faders["1"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("/xtouch/fader/1 = %v; want %v", got, want)
+	}
+
+	// Undescribed handlers are write-only
+	want = map[string]interface{}{"FULL_PATH": "/xtouch/fader/2", "ACCESS": float64(AccessWrite)}
+	if got := faders["2"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("/xtouch/fader/2 = %v; want %v", got, want)
+	}
+
+	// A sub-tree is served on its own path
+	status, sub := get(t, hs, "/xtouch/fader")
+	if status != http.StatusOK || !reflect.DeepEqual(sub, contents["fader"]) {
+		t.Errorf("GET /xtouch/fader = %d %v; want %v", status, sub, contents["fader"])
+	}
+}
+
+func TestServerAttributes(t *testing.T) {
+	s, hs := newTestServer(t)
+	s.SetTransport("TCP")
+
+	for _, tt := range []struct {
+		path   string
+		status int
+		want   map[string]interface{}
+	}{
+		{
+			path:   "/?HOST_INFO",
+			status: http.StatusOK,
+			want: map[string]interface{}{
+				"NAME":          "test",
+				"OSC_PORT":      float64(9000),
+				"OSC_TRANSPORT": "TCP",
+			},
+		},
+		{
+			path:   "/xtouch/fader/1?VALUE",
+			status: http.StatusOK,
+			want:   map[string]interface{}{"VALUE": []interface{}{0.5}},
+		},
+		{
+			path:   "/xtouch/color?VALUE",
+			status: http.StatusOK,
+			want:   map[string]interface{}{"VALUE": []interface{}{"#ff000080", "x"}},
+		},
+		{
+			path:   "/xtouch/encoder/1/mode?RANGE",
+			status: http.StatusOK,
+			want:   map[string]interface{}{"RANGE": []interface{}{map[string]interface{}{"VALS": []interface{}{"single", "fill"}}}},
+		},
+		{
+			path:   "/xtouch?FULL_PATH",
+			status: http.StatusOK,
+			want:   map[string]interface{}{"FULL_PATH": "/xtouch"},
+		},
+		// Write-only values are not published
+		{path: "/xtouch/encoder/1/mode?VALUE", status: http.StatusNoContent},
+		{path: "/xtouch?DESCRIPTION", status: http.StatusNoContent},
+		{path: "/xtouch/fader/2?TYPE", status: http.StatusNoContent},
+		{path: "/xtouch/fader/1?COLOR", status: http.StatusBadRequest},
+		{path: "/xtouch/fader/3", status: http.StatusNotFound},
+		{path: "/xtouch/lcd/1?VALUE", status: http.StatusNotFound},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			status, got := get(t, hs, tt.path)
+			if status != tt.status {
+				t.Fatalf("status %d; want %d", status, tt.status)
+			}
+			for k, v := range tt.want {
+				if !reflect.DeepEqual(got[k], v) {
+					t.Errorf("%s = %v; want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestServerMethods(t *testing.T) {
+	_, hs := newTestServer(t)
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		req, err := http.NewRequest(method, hs.URL+"/xtouch/fader/1", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("%s: status %d; want %d", method, resp.StatusCode, http.StatusMethodNotAllowed)
+		}
+		if allow := resp.Header.Get("Allow"); allow != "GET, HEAD" {
+			t.Errorf("%s: Allow %q", method, allow)
+		}
+	}
+
+	resp, err := http.Head(hs.URL + "/xtouch/fader/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("HEAD: status %d", resp.StatusCode)
+	}
+}
+
+func TestServerRemove(t *testing.T) {
+	s, hs := newTestServer(t)
+	if !s.Remove("/xtouch/color") {
+		t.Fatal("Remove = false")
+	}
+	if s.Remove("/xtouch/color") {
+		t.Error("Remove twice = true")
+	}
+	if status, _ := get(t, hs, "/xtouch/color"); status != http.StatusNotFound {
+		t.Errorf("GET of a removed address: status %d", status)
+	}
+
+	// A listed address stays, without its description
+	s.Remove("/xtouch/fader/1")
+	if status, _ := get(t, hs, "/xtouch/fader/1?DESCRIPTION"); status != http.StatusNoContent {
+		t.Errorf("DESCRIPTION of a removed description: status %d", status)
+	}
+}
@@ -158,6 +158,24 @@ func (b *Button) PressBehavior() *Button {
 	return b
 }
 
+// ReportBehavior reports presses and releases to the handler like
+// PressBehavior, but leaves the LED to the handler.
+func (b *Button) ReportBehavior() *Button {
+	b.behavior = reportButtonBehavior
+	return b
+}
+
+// Note returns the MIDI note of the button.
+func (b *Button) Note() byte {
+	return b.note
+}
+
+// Value returns whether the button is held down with PressBehavior or
+// ReportBehavior, or toggled on with ToggleBehavior.
+func (b *Button) Value() bool {
+	return b.value
+}
+
 func (b *Button) On() error {
 	return b.base.send(midi.NoteOn(b.base.channel, b.note, 127))
 }
@@ -201,8 +219,26 @@ func pressButtonBehavior(b *Button, msg midi.Message) error {
 	if key != b.note {
 		return fmt.Errorf("notes do not match")
 	}
+	b.value = v > 0
 	if b.handler != nil {
-		b.handler(b.name, b.note, v > 0)
+		b.handler(b.name, b.note, b.value)
 	}
 	return b.base.send(msg)
 }
+
+// reportButtonBehavior reports presses and releases to the handler, and
+// leaves the LED alone.
+func reportButtonBehavior(b *Button, msg midi.Message) error {
+	var key, v uint8
+	if !msg.GetNoteOn(nil, &key, &v) {
+		return fmt.Errorf("not a note on message")
+	}
+	if key != b.note {
+		return fmt.Errorf("notes do not match")
+	}
+	b.value = v > 0
+	if b.handler != nil {
+		b.handler(b.name, b.note, b.value)
+	}
+	return nil
+}
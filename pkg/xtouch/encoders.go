@@ -100,13 +100,13 @@ func (e *Encoder) Set(value byte) error {
 	if e.mode == modeContinuous {
 		return fmt.Errorf("can't set value in continuous mode")
 	}
-	e.value = value & 0x0F
 	if value > 11 {
 		value = 11
 	}
 	if e.mode == modeWide && value > 6 {
 		value = 6
 	}
+	e.value = value
 	return e.updateLeds(e.value | e.mode)
 }
 
@@ -1,6 +1,8 @@
 package xtouch
 
 import (
+	"sync"
+
 	"gitlab.com/gomidi/midi/v2"
 	_ "gitlab.com/gomidi/midi/v2/drivers/rtmididrv"
 )
@@ -12,7 +14,8 @@ const (
 type Fader struct {
 	index   byte // 0-based, even though the public index is 1-based
 	limit   int32
-	value   int32 // kept as int32 for now, holds the absolute value 0 ~ 16383
+	mu      sync.Mutex // guards value, which the MIDI input and SetLevel both move
+	value   int32      // kept as int32 for now, holds the absolute value 0 ~ 16383
 	handler func(byte, uint16)
 	base    *XTouch
 }
@@ -30,11 +33,14 @@ func (f *Fader) callHandler(abs uint16) error {
 	return nil
 }
 func (f *Fader) Set(level uint16) error {
-	f.value = (int32(level) * pitchLimit * 2) / f.limit
-	return f.base.send(midi.Pitchbend(f.index, int16(f.value-pitchLimit)))
+	return f.setAbsolute(uint16((int32(level) * pitchLimit * 2) / f.limit))
 }
 
+// setAbsolute moves the fader. The value and the motor are changed together,
+// so that the motor ends up where the value says.
 func (f *Fader) setAbsolute(level uint16) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.value = int32(level)
 	return f.base.send(midi.Pitchbend(f.index, int16(f.value-pitchLimit)))
 }
@@ -44,5 +50,24 @@ func (f *Fader) SetLimit(limit uint16) {
 }
 
 func (f *Fader) Get() uint16 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return uint16((f.limit * f.value) / (pitchLimit * 2))
 }
+
+// Level returns the position of the fader from 0 to 1.
+func (f *Fader) Level() float32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return float32(f.value) / (pitchLimit*2 - 1)
+}
+
+// SetLevel moves the fader to a position from 0 to 1.
+func (f *Fader) SetLevel(level float64) error {
+	if level < 0 {
+		level = 0
+	} else if level > 1 {
+		level = 1
+	}
+	return f.setAbsolute(uint16(level*(pitchLimit*2-1) + 0.5))
+}
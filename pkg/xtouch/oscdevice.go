@@ -0,0 +1,401 @@
+package xtouch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+	"github.com/tmshort/xtouch-eos/pkg/oscquery"
+)
+
+// OSCDevice exposes every control of an XTouch as an OSC address, so the
+// surface can be used from any OSC-capable software:
+//
+//	/xtouch/fader/N          ,f  motor fader N (1-9, 9 is the main fader), 0-1
+//	/xtouch/button/NAME      ,i  button NAME, 1 pressed or lit, 0 released or dark
+//	/xtouch/encoder/N        ,i  sent: encoder N (1-9, 9 is the jog wheel) turned
+//	                             by the delta; received: LED ring value, 0-11
+//	/xtouch/encoder/N/mode   ,s  LED ring mode: single, fill, wide, balance or
+//	                             continuous (no LEDs)
+//	/xtouch/lcd/N            ,ss both lines of LCD N (1-8)
+//	/xtouch/lcd/N/top        ,s  top line of LCD N
+//	/xtouch/lcd/N/bottom     ,s  bottom line of LCD N
+//	/xtouch/led/DISPLAY      ,s  seven-segment display: all, assignment, bars,
+//	                             beats, subdivision or ticks
+//
+// Button names are lower case with spaces replaced by '_', e.g.
+// "/xtouch/button/fader_bank/left". Physical input is sent to the remote
+// address of the connection, and received messages set the motors, LEDs and
+// displays. Received addresses may be OSC patterns, e.g. "/xtouch/lcd/*/top".
+//
+// The device installs its own handlers on all controls, replacing any set
+// before.
+type OSCDevice struct {
+	x          *XTouch
+	conn       *osc.Connection
+	dispatcher *osc.StandardDispatcher
+
+	mu  sync.Mutex
+	lcd [8][2]string
+	led map[string]string
+}
+
+// ledDisplays maps the OSC names of the seven-segment displays to their
+// setters.
+var ledDisplays = map[string]func(LedDisplay, string) error{
+	"all":         LedDisplay.SetAll,
+	"assignment":  LedDisplay.SetAssignment,
+	"bars":        LedDisplay.SetBars,
+	"beats":       LedDisplay.SetBeats,
+	"subdivision": LedDisplay.SetSubdivision,
+	"ticks":       LedDisplay.SetTicks,
+}
+
+// encoderModes maps the OSC names of the encoder modes to their setters.
+var encoderModes = map[string]func(*Encoder) *Encoder{
+	"single":     (*Encoder).ModeSingle,
+	"fill":       (*Encoder).ModeFill,
+	"wide":       (*Encoder).ModeWide,
+	"balance":    (*Encoder).ModeBalance,
+	"continuous": (*Encoder).ModeContinuous,
+}
+
+// ButtonAddress returns the OSC address of the named button.
+func ButtonAddress(name string) string {
+	return "/xtouch/button/" + strings.ReplaceAll(strings.ToLower(name), " ", "_")
+}
+
+// NewOSCDevice returns an OSCDevice for `x` listening on the local UDP port
+// `lport` and sending physical input to `raddr`.
+func NewOSCDevice(x *XTouch, lport int, raddr string) (*OSCDevice, error) {
+	conn, err := osc.NewConnection(lport, raddr)
+	if err != nil {
+		return nil, err
+	}
+	d := &OSCDevice{
+		x:          x,
+		conn:       conn,
+		dispatcher: osc.NewStandardDispatcher(osc.WithMatchMode(osc.MatchOSCPattern)),
+		led:        map[string]string{},
+	}
+	// Keep the order of updates to the same control
+	conn.DispatchOptions = osc.DispatchOptions{Mode: osc.DispatchSerial}
+	conn.Dispatcher = d.dispatcher
+
+	if err := d.addHandlers(); err != nil {
+		return nil, err
+	}
+	d.installControls()
+	return d, nil
+}
+
+// Connection returns the OSC connection, e.g. to add destinations.
+func (d *OSCDevice) Connection() *osc.Connection {
+	return d.conn
+}
+
+// Dispatcher returns the dispatcher of the received messages, e.g. to add
+// handlers or to publish its addresses with OSCQuery.
+func (d *OSCDevice) Dispatcher() *osc.StandardDispatcher {
+	return d.dispatcher
+}
+
+// Start opens the connection and serves received messages in the background.
+func (d *OSCDevice) Start() error {
+	if err := d.conn.Open(); err != nil {
+		return err
+	}
+	go d.conn.Serve(context.Background())
+	return nil
+}
+
+// Close closes the connection.
+func (d *OSCDevice) Close() error {
+	return d.conn.Close()
+}
+
+// send sends physical input to the remote address.
+func (d *OSCDevice) send(addr string, args ...interface{}) {
+	if err := d.conn.Send(osc.NewMessage(addr, args...)); err != nil {
+		fmt.Printf("error sending %v: %v\n", addr, err)
+	}
+}
+
+// installControls sets the handlers of the controls to send OSC.
+func (d *OSCDevice) installControls() {
+	for _, b := range d.x.noteToButton {
+		addr := ButtonAddress(b.name)
+		b.behavior = reportButtonBehavior
+		b.handler = func(_ string, _ byte, pressed bool) {
+			d.send(addr, boolToInt32(pressed))
+		}
+	}
+	for i, f := range d.x.faders {
+		addr := fmt.Sprintf("/xtouch/fader/%d", i)
+		f.handler = func(byte, uint16) {
+			d.send(addr, f.Level())
+		}
+	}
+	for i, e := range d.x.encoders {
+		addr := fmt.Sprintf("/xtouch/encoder/%d", i)
+		e.handler = func(_ byte, _ byte, delta int8) {
+			d.send(addr, int32(delta))
+		}
+	}
+}
+
+// addHandlers installs the handlers for received messages.
+func (d *OSCDevice) addHandlers() error {
+	handlers := map[string]osc.HandlerFunc{}
+	for i, f := range d.x.faders {
+		handlers[fmt.Sprintf("/xtouch/fader/%d", i)] = d.locked(func(msg *osc.Message) error {
+			v, err := msg.Float(0)
+			if err != nil {
+				return err
+			}
+			return f.SetLevel(v)
+		})
+	}
+	for _, b := range d.x.noteToButton {
+		handlers[ButtonAddress(b.name)] = d.locked(func(msg *osc.Message) error {
+			on, err := msg.Bool(0)
+			if err != nil {
+				return err
+			}
+			b.value = on
+			if on {
+				return b.On()
+			}
+			return b.Off()
+		})
+	}
+	for i, e := range d.x.encoders {
+		handlers[fmt.Sprintf("/xtouch/encoder/%d", i)] = d.locked(func(msg *osc.Message) error {
+			v, err := msg.Int32(0)
+			if err != nil {
+				return err
+			}
+			// The ring has 11 LEDs, 0 turns them off
+			if v < 0 {
+				v = 0
+			} else if v > 11 {
+				v = 11
+			}
+			return e.Set(byte(v))
+		})
+		handlers[fmt.Sprintf("/xtouch/encoder/%d/mode", i)] = d.locked(func(msg *osc.Message) error {
+			name, err := msg.StringArg(0)
+			if err != nil {
+				return err
+			}
+			mode, ok := encoderModes[name]
+			if !ok {
+				return fmt.Errorf("unknown encoder mode %q", name)
+			}
+			if mode(e) == nil {
+				return fmt.Errorf("encoder %d does not support mode %q", e.index, name)
+			}
+			if e.mode == modeContinuous {
+				return nil
+			}
+			return e.updateLeds(e.value | e.mode)
+		})
+	}
+	for i := 1; i <= 8; i++ {
+		handlers[fmt.Sprintf("/xtouch/lcd/%d", i)] = d.locked(func(msg *osc.Message) error {
+			top, err := msg.StringArg(0)
+			if err != nil {
+				return err
+			}
+			bottom, err := msg.StringArg(1)
+			if err != nil {
+				return err
+			}
+			d.setLcd(i, &top, &bottom)
+			return nil
+		})
+		handlers[fmt.Sprintf("/xtouch/lcd/%d/top", i)] = d.locked(func(msg *osc.Message) error {
+			top, err := msg.StringArg(0)
+			if err != nil {
+				return err
+			}
+			d.setLcd(i, &top, nil)
+			return nil
+		})
+		handlers[fmt.Sprintf("/xtouch/lcd/%d/bottom", i)] = d.locked(func(msg *osc.Message) error {
+			bottom, err := msg.StringArg(0)
+			if err != nil {
+				return err
+			}
+			d.setLcd(i, nil, &bottom)
+			return nil
+		})
+	}
+	for name, set := range ledDisplays {
+		handlers["/xtouch/led/"+name] = d.locked(func(msg *osc.Message) error {
+			text, err := msg.StringArg(0)
+			if err != nil {
+				return err
+			}
+			if err := set(d.x.LedDisplay, text); err != nil {
+				return err
+			}
+			if name == "all" {
+				d.led = map[string]string{}
+			}
+			d.led[name] = text
+			return nil
+		})
+	}
+
+	for addr, h := range handlers {
+		if err := d.dispatcher.AddMsgHandler(addr, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// locked adapts `f` to a HandlerFunc that runs under the device lock and
+// reports errors.
+func (d *OSCDevice) locked(f func(msg *osc.Message) error) osc.HandlerFunc {
+	return func(msg *osc.Message, addr net.Addr) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if err := f(msg); err != nil {
+			fmt.Printf("error handling %v from %v: %v\n", msg.Address, addr, err)
+		}
+	}
+}
+
+// setLcd updates the given lines of an LCD. The caller holds d.mu.
+func (d *OSCDevice) setLcd(i int, top, bottom *string) {
+	if top != nil {
+		d.lcd[i-1][0] = *top
+	}
+	if bottom != nil {
+		d.lcd[i-1][1] = *bottom
+	}
+	d.x.LcdDisplay(byte(i)).SetPanel(d.lcd[i-1][0], d.lcd[i-1][1])
+}
+
+// Describe publishes the addresses of the device with their types, ranges
+// and current values.
+func (d *OSCDevice) Describe(s *oscquery.Server) {
+	unit := []oscquery.Range{{Min: 0, Max: 1}}
+	for i, f := range d.x.faders {
+		s.Describe(oscquery.Endpoint{
+			Address:     fmt.Sprintf("/xtouch/fader/%d", i),
+			Description: fmt.Sprintf("Motor fader %d", i),
+			Type:        "f",
+			Access:      oscquery.AccessReadWrite,
+			Range:       unit,
+			Value:       func() []interface{} { return []interface{}{f.Level()} },
+		})
+	}
+
+	names := make([]string, 0, len(d.x.nameToNote))
+	for name := range d.x.nameToNote {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b := d.x.Button(name)
+		s.Describe(oscquery.Endpoint{
+			Address:     ButtonAddress(name),
+			Description: "Button " + name,
+			Type:        "i",
+			Access:      oscquery.AccessReadWrite,
+			Range:       []oscquery.Range{{Values: []interface{}{0, 1}}},
+			Value: func() []interface{} {
+				d.mu.Lock()
+				defer d.mu.Unlock()
+				return []interface{}{boolToInt32(b.value)}
+			},
+		})
+	}
+
+	for i, e := range d.x.encoders {
+		s.Describe(oscquery.Endpoint{
+			Address:     fmt.Sprintf("/xtouch/encoder/%d", i),
+			Description: fmt.Sprintf("Encoder %d: sends the delta turned, receives the LED ring value", i),
+			Type:        "i",
+			Access:      oscquery.AccessReadWrite,
+			Range:       []oscquery.Range{{Min: 0, Max: 11}},
+			Value: func() []interface{} {
+				d.mu.Lock()
+				defer d.mu.Unlock()
+				return []interface{}{int32(e.Get())}
+			},
+		})
+		modes := make([]interface{}, 0, len(encoderModes))
+		for _, m := range []string{"single", "fill", "wide", "balance", "continuous"} {
+			modes = append(modes, m)
+		}
+		s.Describe(oscquery.Endpoint{
+			Address:     fmt.Sprintf("/xtouch/encoder/%d/mode", i),
+			Description: fmt.Sprintf("LED ring mode of encoder %d", i),
+			Type:        "s",
+			Access:      oscquery.AccessWrite,
+			Range:       []oscquery.Range{{Values: modes}},
+		})
+	}
+
+	for i := 1; i <= 8; i++ {
+		line := func(n int) func() []interface{} {
+			return func() []interface{} {
+				d.mu.Lock()
+				defer d.mu.Unlock()
+				return []interface{}{d.lcd[i-1][n]}
+			}
+		}
+		s.Describe(oscquery.Endpoint{
+			Address:     fmt.Sprintf("/xtouch/lcd/%d", i),
+			Description: fmt.Sprintf("Both lines of LCD %d", i),
+			Type:        "ss",
+			Access:      oscquery.AccessReadWrite,
+			Value: func() []interface{} {
+				return append(line(0)(), line(1)()...)
+			},
+		})
+		s.Describe(oscquery.Endpoint{
+			Address:     fmt.Sprintf("/xtouch/lcd/%d/top", i),
+			Description: fmt.Sprintf("Top line of LCD %d", i),
+			Type:        "s",
+			Access:      oscquery.AccessReadWrite,
+			Value:       line(0),
+		})
+		s.Describe(oscquery.Endpoint{
+			Address:     fmt.Sprintf("/xtouch/lcd/%d/bottom", i),
+			Description: fmt.Sprintf("Bottom line of LCD %d", i),
+			Type:        "s",
+			Access:      oscquery.AccessReadWrite,
+			Value:       line(1),
+		})
+	}
+
+	for name := range ledDisplays {
+		s.Describe(oscquery.Endpoint{
+			Address:     "/xtouch/led/" + name,
+			Description: fmt.Sprintf("Seven-segment display %q", name),
+			Type:        "s",
+			Access:      oscquery.AccessReadWrite,
+			Value: func() []interface{} {
+				d.mu.Lock()
+				defer d.mu.Unlock()
+				return []interface{}{d.led[name]}
+			},
+		})
+	}
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
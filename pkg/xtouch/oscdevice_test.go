@@ -0,0 +1,302 @@
+package xtouch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmshort/xtouch-eos/pkg/osc"
+	"github.com/tmshort/xtouch-eos/pkg/oscquery"
+	"gitlab.com/gomidi/midi/v2"
+)
+
+// midiRecorder records the MIDI messages sent to an X-Touch.
+type midiRecorder struct {
+	mu   sync.Mutex
+	sent []midi.Message
+}
+
+// newRecordingXTouch returns a fake X-Touch whose output is recorded.
+func newRecordingXTouch() (*XTouch, *midiRecorder) {
+	r := &midiRecorder{}
+	x := &XTouch{stop: func() {}}
+	x.send = func(msg midi.Message) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.sent = append(r.sent, msg)
+		return nil
+	}
+	x.init()
+	return x, r
+}
+
+// take returns and forgets the recorded messages.
+func (r *midiRecorder) take() []midi.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sent := r.sent
+	r.sent = nil
+	return sent
+}
+
+// newTestOSCDevice returns an OSCDevice sending to a UDP socket on the
+// loopback interface.
+func newTestOSCDevice(t *testing.T) (*OSCDevice, *midiRecorder, *net.UDPConn) {
+	t.Helper()
+	remote, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { remote.Close() })
+
+	x, r := newRecordingXTouch()
+	d, err := NewOSCDevice(x, 0, remote.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d, r, remote
+}
+
+// receive returns the next message sent to `remote`, and where it came from.
+func receive(t *testing.T, remote *net.UDPConn) (*osc.Message, net.Addr) {
+	t.Helper()
+	buf := make([]byte, 65536)
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	n, addr, err := remote.ReadFrom(buf)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("nothing sent")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := osc.ParsePacketBytes(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*osc.Message), addr
+}
+
+func TestOSCDeviceReceive(t *testing.T) {
+	d, r, _ := newTestOSCDevice(t)
+	x := d.x
+
+	for _, tt := range []struct {
+		name string
+		msg  *osc.Message
+		want []midi.Message
+	}{
+		{
+			name: "fader",
+			msg:  osc.NewMessage("/xtouch/fader/9", float32(1)),
+			want: []midi.Message{midi.Pitchbend(8, 8191)},
+		},
+		{
+			name: "button on",
+			msg:  osc.NewMessage("/xtouch/button/fader_bank/left", int32(1)),
+			want: []midi.Message{midi.NoteOn(0, 46, 127)},
+		},
+		{
+			name: "button off",
+			msg:  osc.NewMessage("/xtouch/button/play", false),
+			want: []midi.Message{midi.NoteOn(0, 94, 0)},
+		},
+		{
+			name: "encoder mode",
+			msg:  osc.NewMessage("/xtouch/encoder/1/mode", "fill"),
+			want: []midi.Message{midi.ControlChange(0, 48, modeFill)},
+		},
+		{
+			name: "encoder",
+			msg:  osc.NewMessage("/xtouch/encoder/1", int32(5)),
+			want: []midi.Message{midi.ControlChange(0, 48, modeFill|5)},
+		},
+		{
+			name: "encoder past the last LED",
+			msg:  osc.NewMessage("/xtouch/encoder/1", int32(256)),
+			want: []midi.Message{midi.ControlChange(0, 48, modeFill|11)},
+		},
+		{
+			name: "encoder below zero",
+			msg:  osc.NewMessage("/xtouch/encoder/1", int32(-3)),
+			want: []midi.Message{midi.ControlChange(0, 48, modeFill)},
+		},
+		{
+			name: "wide encoder pattern",
+			msg:  osc.NewMessage("/xtouch/encoder/[2]/mode", "wide"),
+			want: []midi.Message{midi.ControlChange(0, 49, modeWide)},
+		},
+		{
+			name: "wide encoder",
+			msg:  osc.NewMessage("/xtouch/encoder/2", int32(9)),
+			want: []midi.Message{midi.ControlChange(0, 49, modeWide|6)},
+		},
+		{name: "continuous encoder", msg: osc.NewMessage("/xtouch/encoder/4", int32(5))},
+		{name: "jog mode", msg: osc.NewMessage("/xtouch/encoder/9/mode", "single")},
+		{name: "unknown mode", msg: osc.NewMessage("/xtouch/encoder/1/mode", "spiral")},
+		{name: "wrong type", msg: osc.NewMessage("/xtouch/fader/1", "full")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d.Dispatcher().Dispatch(tt.msg, nil)
+			got := r.take()
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("sent %v; want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := x.Fader(9).Level(); got != 1 {
+		t.Errorf("main fader at %v; want 1", got)
+	}
+	if !x.Button("FADER BANK/LEFT").Value() || x.Button("PLAY").Value() {
+		t.Error("button values not set")
+	}
+	if got := x.Encoder(1).Get(); got != 0 {
+		t.Errorf("encoder 1 at %d; want 0", got)
+	}
+}
+
+func TestOSCDeviceDisplays(t *testing.T) {
+	d, r, _ := newTestOSCDevice(t)
+
+	for _, msg := range []*osc.Message{
+		osc.NewMessage("/xtouch/lcd/1", "Front", "50%"),
+		osc.NewMessage("/xtouch/lcd/2/top", "Back"),
+		osc.NewMessage("/xtouch/lcd/*/bottom", "-"),
+		osc.NewMessage("/xtouch/lcd/1/bottom", "75%"),
+		osc.NewMessage("/xtouch/led/all", "12345"),
+		osc.NewMessage("/xtouch/led/bars", "001"),
+	} {
+		d.Dispatcher().Dispatch(msg, nil)
+	}
+	if len(r.take()) == 0 {
+		t.Error("nothing sent to the displays")
+	}
+
+	want := [8][2]string{{"Front", "75%"}, {"Back", "-"}}
+	for i := 2; i < 8; i++ {
+		want[i][1] = "-"
+	}
+	if d.lcd != want {
+		t.Errorf("LCDs show %q; want %q", d.lcd, want)
+	}
+	if want := map[string]string{"all": "12345", "bars": "001"}; fmt.Sprint(d.led) != fmt.Sprint(want) {
+		t.Errorf("seven-segment displays show %v; want %v", d.led, want)
+	}
+
+	// Setting all displays forgets the parts
+	d.Dispatcher().Dispatch(osc.NewMessage("/xtouch/led/all", ""), nil)
+	if want := map[string]string{"all": ""}; fmt.Sprint(d.led) != fmt.Sprint(want) {
+		t.Errorf("seven-segment displays show %v; want %v", d.led, want)
+	}
+}
+
+func TestOSCDeviceSend(t *testing.T) {
+	d, _, remote := newTestOSCDevice(t)
+	x := d.x
+
+	for _, tt := range []struct {
+		name string
+		in   midi.Message
+		want *osc.Message
+	}{
+		{name: "press", in: midi.NoteOn(0, 94, 127), want: osc.NewMessage("/xtouch/button/play", int32(1))},
+		{name: "release", in: midi.NoteOn(0, 94, 0), want: osc.NewMessage("/xtouch/button/play", int32(0))},
+		{name: "fader", in: midi.Pitchbend(2, 8191), want: osc.NewMessage("/xtouch/fader/3", float32(1))},
+		{name: "encoder right", in: midi.ControlChange(0, 16, 2), want: osc.NewMessage("/xtouch/encoder/1", int32(2))},
+		{name: "encoder left", in: midi.ControlChange(0, 17, 65), want: osc.NewMessage("/xtouch/encoder/2", int32(-1))},
+		{name: "jog", in: midi.ControlChange(0, 60, 1), want: osc.NewMessage("/xtouch/encoder/9", int32(1))},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := x.Receive(tt.in); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := receive(t, remote)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("sent %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOSCDeviceServe(t *testing.T) {
+	d, _, remote := newTestOSCDevice(t)
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Answer the device where its input came from
+	if err := d.x.Receive(midi.NoteOn(0, 94, 127)); err != nil {
+		t.Fatal(err)
+	}
+	_, addr := receive(t, remote)
+	data, err := osc.NewMessage("/xtouch/lcd/3/top", "Hello").MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.WriteTo(data, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		d.mu.Lock()
+		top := d.lcd[2][0]
+		d.mu.Unlock()
+		if top == "Hello" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("LCD 3 not set")
+		}
+	}
+}
+
+func TestOSCDeviceDescribe(t *testing.T) {
+	d, _, _ := newTestOSCDevice(t)
+	d.Dispatcher().Dispatch(osc.NewMessage("/xtouch/fader/2", float32(0.5)), nil)
+	d.Dispatcher().Dispatch(osc.NewMessage("/xtouch/lcd/1", "Front", "50%"), nil)
+
+	s := oscquery.NewServer("xtouch", 9000, d.Dispatcher())
+	d.Describe(s)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Every handler is described with its type
+	for _, addr := range d.Dispatcher().Addresses() {
+		if w := get(addr + "?TYPE"); w.Code != http.StatusOK {
+			t.Errorf("TYPE of %s: status %d", addr, w.Code)
+		}
+	}
+
+	for _, tt := range []struct {
+		path string
+		want string
+	}{
+		{path: "/xtouch/lcd/1?VALUE", want: `{"VALUE":["Front","50%"]}`},
+		{path: "/xtouch/lcd/1/top?VALUE", want: `{"VALUE":["Front"]}`},
+		{path: "/xtouch/encoder/1?RANGE", want: `{"RANGE":[{"MIN":0,"MAX":11}]}`},
+		{path: "/xtouch/button/play?VALUE", want: `{"VALUE":[0]}`},
+	} {
+		w := get(tt.path)
+		if got := string(bytes.Join(bytes.Fields(w.Body.Bytes()), nil)); got != tt.want {
+			t.Errorf("GET %s = %s; want %s", tt.path, got, tt.want)
+		}
+	}
+
+	w := get("/xtouch/fader/2?VALUE")
+	var level float64
+	if _, err := fmt.Sscanf(string(bytes.Join(bytes.Fields(w.Body.Bytes()), nil)), `{"VALUE":[%g]}`, &level); err != nil || math.Abs(level-0.5) > 0.001 {
+		t.Errorf("GET /xtouch/fader/2?VALUE = %s; want 0.5", w.Body)
+	}
+}
@@ -3,6 +3,7 @@ package xtouch
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"gitlab.com/gomidi/midi/v2"
 	_ "gitlab.com/gomidi/midi/v2/drivers/rtmididrv"
@@ -16,6 +17,7 @@ type XTouch struct {
 	LedDisplay   LedDisplay
 	channel      byte
 	send         func(midi.Message) error
+	sendMu       sync.Mutex
 	stop         func()
 	nameToNote   map[string]byte
 	noteToButton map[byte]*Button
@@ -24,6 +26,15 @@ type XTouch struct {
 }
 
 func (x *XTouch) init() {
+	// The controls are driven from the MIDI input and from other goroutines,
+	// e.g. OSC handlers, but the MIDI output takes one message at a time
+	send := x.send
+	x.send = func(msg midi.Message) error {
+		x.sendMu.Lock()
+		defer x.sendMu.Unlock()
+		return send(msg)
+	}
+
 	x.nameToNote = buttonNameToNote
 	// This tests for duplicates
 	x.noteToButton = map[byte]*Button{}
@@ -83,21 +94,7 @@ func NewXTouchByName(name string) (*XTouch, error) {
 	x.init()
 
 	x.stop, err = midi.ListenTo(in, func(msg midi.Message, timestampms int32) {
-		var ch, key, v uint8
-		var u16 uint16
-		var err error
-		switch {
-		case msg.GetNoteOn(&ch, &key, &v):
-			err = x.ButtonByNote(key).callBehavior(msg)
-		case msg.GetPitchBend(&ch, nil, &u16):
-			err = x.Fader(ch + 1).callHandler(u16)
-		case msg.GetControlChange(&ch, &key, &v):
-			err = x.encoderFromController(key).callHandler(v)
-		default:
-			fmt.Printf("Message %v\n", msg)
-			err = x.send(msg)
-		}
-		if err != nil {
+		if err := x.Receive(msg); err != nil {
 			fmt.Printf("error handling msg: %v\n", msg)
 		}
 	}, midi.UseSysEx())
@@ -108,6 +105,31 @@ func NewXTouchByName(name string) (*XTouch, error) {
 	return x, nil
 }
 
+// Receive handles `msg` as if the X-Touch had sent it, which drives the
+// controls of a fake X-Touch.
+func (x *XTouch) Receive(msg midi.Message) error {
+	var ch, key, v uint8
+	var u16 uint16
+	switch {
+	case msg.GetNoteOn(&ch, &key, &v):
+		if b := x.ButtonByNote(key); b != nil {
+			return b.callBehavior(msg)
+		}
+	case msg.GetPitchBend(&ch, nil, &u16):
+		if f := x.Fader(ch + 1); f != nil {
+			return f.callHandler(u16)
+		}
+	case msg.GetControlChange(&ch, &key, &v):
+		if e := x.encoderFromController(key); e != nil {
+			return e.callHandler(v)
+		}
+	default:
+		fmt.Printf("Message %v\n", msg)
+		return x.send(msg)
+	}
+	return fmt.Errorf("no control for %v", msg)
+}
+
 func (x *XTouch) LcdDisplay(i byte) LcdDisplay {
 	return LcdDisplay{base: x, index: i}
 }